@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/claude"
+	"github.com/truong-nautilus/smart-home-ai/core"
+	"github.com/truong-nautilus/smart-home-ai/tts"
+)
+
+// assistant answers requests with Claude, or with the local intent parser
+// for common commands and when Claude is unavailable
+type assistant struct {
+	claude   *claude.Client // nil without an API key
+	intents  *core.IntentParser
+	executor *core.Executor
+	speaker  *tts.Speaker
+	fastPath bool
+	fallback bool
+
+	// pending is a batch waiting for the user to confirm it or pick a device.
+	// mu also guards the settings Reload replaces.
+	pending        *core.PendingAction
+	pendingUntil   time.Time
+	pendingTimeout time.Duration
+	mu             sync.Mutex
+}
+
+// defaultPendingTimeout is how long a question waits for an answer
+const defaultPendingTimeout = 30 * time.Second
+
+// newAssistant creates the Claude client and intent parser, executing
+// commands through the security manager and router
+func newAssistant(config *core.Config, security *core.SecurityManager, router *core.CommandRouter) *assistant {
+	a := &assistant{
+		intents:  core.NewIntentParser(config),
+		executor: core.NewExecutor(security, router),
+		fastPath: config.Intent.FastPath,
+		fallback: config.Intent.Fallback,
+
+		pendingTimeout: time.Duration(config.Confirm.TimeoutSeconds) * time.Second,
+	}
+	if a.pendingTimeout <= 0 {
+		a.pendingTimeout = defaultPendingTimeout
+	}
+
+	apiKey := configOrEnv(config.Claude.APIKey, "CLAUDE_API_KEY")
+	if apiKey == "" {
+		log.Println("Warning: No Claude API key (claude.api_key or CLAUDE_API_KEY), only simple device commands will be understood")
+		return a
+	}
+
+	a.claude = claude.NewClient(claude.ClaudeConfig{
+		APIKey:       apiKey,
+		Model:        config.Claude.Model,
+		APIURL:       config.Claude.APIURL,
+		SystemPrompt: config.Claude.SystemPrompt,
+		MaxTokens:    config.Claude.MaxTokens,
+		Temperature:  config.Claude.Temperature,
+		Session: claude.SessionConfig{
+			IdleTimeout: time.Duration(config.Claude.IdleResetSeconds) * time.Second,
+			MaxTurns:    config.Claude.MaxTurns,
+		},
+	})
+
+	// Generate tools and the device list from the configuration
+	a.claude.UpdateCatalog(config, security)
+	a.claude.SetCommandHandler(a.execute)
+
+	return a
+}
+
+// Reload applies a new configuration: the device catalogue given to Claude,
+// the intent parser and its settings
+func (a *assistant) Reload(config *core.Config, security *core.SecurityManager) {
+	intents := core.NewIntentParser(config)
+
+	a.mu.Lock()
+	a.intents = intents
+	a.fastPath = config.Intent.FastPath
+	a.fallback = config.Intent.Fallback
+	a.pendingTimeout = time.Duration(config.Confirm.TimeoutSeconds) * time.Second
+	if a.pendingTimeout <= 0 {
+		a.pendingTimeout = defaultPendingTimeout
+	}
+	a.mu.Unlock()
+
+	if a.claude != nil {
+		a.claude.UpdateCatalog(config, security)
+	}
+}
+
+// SetContext passes extra context, such as device availability, to Claude
+func (a *assistant) SetContext(context string) {
+	if a.claude != nil {
+		a.claude.SetContext(context)
+	}
+}
+
+// execute runs a batch of commands
+func (a *assistant) execute(batch *core.Batch) *core.BatchResult {
+	log.Printf("Received %d command(s)", len(batch.Commands))
+	result := a.executor.Execute(batch)
+	log.Printf("Batch result: %s", result.Summary())
+	return result
+}
+
+// ask answers a user request, logs the reply and speaks it if a speaker is set
+func (a *assistant) ask(text string) (*claude.Reply, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	reply, err := a.answer(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	a.hold(reply)
+
+	if reply.Text != "" {
+		log.Printf("Jarvis: %s", reply.Text)
+	}
+
+	if a.speaker != nil {
+		if err := a.speaker.Say(ctx, spokenReply(reply)); err != nil {
+			log.Printf("Error speaking reply: %v", err)
+		}
+	}
+
+	return reply, nil
+}
+
+// answer handles simple commands locally when the fast path is enabled and
+// sends everything else to Claude, falling back to the intent parser if
+// Claude can't be reached
+func (a *assistant) answer(ctx context.Context, text string) (*claude.Reply, error) {
+	if reply := a.resume(text); reply != nil {
+		return reply, nil
+	}
+
+	a.mu.Lock()
+	fastPath, fallback := a.fastPath, a.fallback
+	a.mu.Unlock()
+
+	if fastPath || a.claude == nil {
+		reply, err := a.local(text)
+		if err == nil {
+			return reply, nil
+		}
+		if a.claude == nil {
+			log.Printf("Error handling request without Claude: %v", err)
+			return clarify(err)
+		}
+	}
+
+	reply, err := a.claude.Send(ctx, text)
+	if err == nil {
+		return reply, nil
+	}
+	log.Printf("Error talking to Claude: %v", err)
+
+	// Commands Claude already ran would run twice through the intent parser
+	if reply != nil && len(reply.Results) > 0 {
+		return reply, nil
+	}
+
+	if fallback {
+		reply, localErr := a.local(text)
+		if localErr == nil {
+			return reply, nil
+		}
+		if reply, clarifyErr := clarify(localErr); clarifyErr == nil {
+			return reply, nil
+		}
+	}
+	return nil, err
+}
+
+// resume answers a reply to a pending question, returning nil if there is
+// none or the reply is a new request
+func (a *assistant) resume(text string) *claude.Reply {
+	a.mu.Lock()
+	pending, expired := a.pending, time.Now().After(a.pendingUntil)
+	a.pending = nil
+	a.mu.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	if expired {
+		log.Println("Pending action expired")
+		return nil
+	}
+
+	var reply *claude.Reply
+	switch pending.Answer(text) {
+	case core.AnswerYes:
+		log.Println("Pending action confirmed")
+		result := a.executor.Resume(pending)
+		log.Printf("Batch result: %s", result.Summary())
+		reply = &claude.Reply{Results: []*core.BatchResult{result}}
+		if result.Pending != nil {
+			reply.Text = result.Pending.Question()
+		}
+	case core.AnswerNo:
+		log.Println("Pending action cancelled")
+		reply = &claude.Reply{Text: "Đã hủy."}
+	default:
+		log.Println("Request doesn't answer the pending question, dropping the pending action")
+		return nil
+	}
+
+	if a.claude != nil {
+		a.claude.Remember(text, reply)
+	}
+	return reply
+}
+
+// hold keeps the action a reply asks about, binding the next request to it
+func (a *assistant) hold(reply *claude.Reply) {
+	var pending *core.PendingAction
+	for _, result := range reply.Results {
+		if result.Pending != nil {
+			pending = result.Pending
+		}
+	}
+	if pending == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = pending
+	a.pendingUntil = time.Now().Add(a.pendingTimeout)
+}
+
+// clarify asks which device was meant when a request was ambiguous, and
+// returns the error otherwise. The request is held until the user answers.
+func clarify(err error) (*claude.Reply, error) {
+	var ambiguous *core.AmbiguousDeviceError
+	if !errors.As(err, &ambiguous) {
+		return nil, err
+	}
+
+	reply := &claude.Reply{Text: ambiguous.Question()}
+	if ambiguous.Batch != nil {
+		reply.Results = []*core.BatchResult{{Pending: &core.PendingAction{Batch: ambiguous.Batch, Ambiguous: ambiguous}}}
+	}
+	return reply, nil
+}
+
+// local executes a request understood by the intent parser
+func (a *assistant) local(text string) (*claude.Reply, error) {
+	a.mu.Lock()
+	intents := a.intents
+	a.mu.Unlock()
+
+	batch, err := intents.Parse(text)
+	if err != nil {
+		if !errors.Is(err, core.ErrNotUnderstood) {
+			log.Printf("Error parsing request: %v", err)
+		}
+		return nil, err
+	}
+
+	log.Println("Handling request locally")
+	result := a.execute(batch)
+	reply := &claude.Reply{Results: []*core.BatchResult{result}}
+	if result.Pending != nil {
+		reply.Text = result.Pending.Question()
+	}
+
+	// Keep Claude's conversation in step, so "make it dimmer" still works
+	if a.claude != nil {
+		a.claude.Remember(text, reply)
+	}
+	return reply, nil
+}
+
+// spokenReply returns the text to speak for a reply, confirming actions
+// when Claude didn't answer with text
+func spokenReply(reply *claude.Reply) string {
+	if reply.Text != "" {
+		return reply.Text
+	}
+	if len(reply.Results) == 0 {
+		return ""
+	}
+
+	for _, result := range reply.Results {
+		if result.Pending != nil {
+			return result.Pending.Question()
+		}
+	}
+	for _, result := range reply.Results {
+		if !result.Success {
+			return "Có lỗi khi thực hiện lệnh."
+		}
+	}
+	return "Đã thực hiện."
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/truong-nautilus/smart-home-ai/claude"
+	"github.com/truong-nautilus/smart-home-ai/core"
+	"github.com/truong-nautilus/smart-home-ai/devices"
+)
+
+func TestAnswerDoesNotRepeatToolCalls(t *testing.T) {
+	// The first round toggles the fan, the second one fails
+	rounds := []string{
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"switch_toggle\",\"input\":{}}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"device\\\": \\\"quat\\\"}\"}}\n\n" +
+			"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n",
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > len(rounds) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, rounds[requests-1])
+	}))
+	defer server.Close()
+
+	config := &core.Config{
+		Devices: core.DevicesConfig{
+			Switches: map[string]core.DeviceInfo{
+				"quat": {Type: "mqtt", Topic: "home/fan", Name: "Quạt"},
+			},
+		},
+		Intent: core.IntentConfig{Fallback: true},
+	}
+	router := core.NewCommandRouter(config)
+	router.SetDryRun(true)
+	if err := router.Initialize(devices.TapoConfig{}, devices.MQTTConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAssistant(config, core.NewSecurityManager(), router)
+	a.claude = claude.NewClient(claude.ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+	var toggled *core.BatchResult
+	a.claude.SetCommandHandler(func(batch *core.Batch) *core.BatchResult {
+		toggled = a.execute(batch)
+		return toggled
+	})
+
+	// The intent parser understands the request too, but must not run it again
+	reply, err := a.answer(context.Background(), "tắt quạt")
+	if err != nil {
+		t.Fatalf("answer() error = %v", err)
+	}
+	if toggled == nil || len(reply.Results) != 1 || reply.Results[0] != toggled {
+		t.Errorf("Expected only the toggle Claude ran, got %+v", reply.Results)
+	}
+}
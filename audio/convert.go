@@ -0,0 +1,220 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gen2brain/malgo"
+)
+
+// Format describes interleaved PCM audio
+type Format struct {
+	SampleRate int
+	Channels   int
+	Encoding   malgo.FormatType
+}
+
+// PipelineFormat is the format expected by the speech pipeline: 16 kHz mono PCM16
+var PipelineFormat = Format{SampleRate: 16000, Channels: 1, Encoding: malgo.FormatS16}
+
+// String returns a short description such as "48000 Hz/2 ch/f32"
+func (f Format) String() string {
+	return fmt.Sprintf("%d Hz/%d ch/%s", f.SampleRate, f.Channels, encodingName(f.Encoding))
+}
+
+// SampleFormat returns the encoding for a bit depth, e.g. 32-bit float
+func SampleFormat(bitDepth int, float bool) (malgo.FormatType, error) {
+	if float {
+		if bitDepth != 0 && bitDepth != 32 {
+			return malgo.FormatUnknown, fmt.Errorf("float samples must be 32-bit, got %d", bitDepth)
+		}
+		return malgo.FormatF32, nil
+	}
+
+	switch bitDepth {
+	case 8:
+		return malgo.FormatU8, nil
+	case 0, 16:
+		return malgo.FormatS16, nil
+	case 24:
+		return malgo.FormatS24, nil
+	case 32:
+		return malgo.FormatS32, nil
+	default:
+		return malgo.FormatUnknown, fmt.Errorf("unsupported bit depth: %d", bitDepth)
+	}
+}
+
+// BytesPerSample returns the size of one sample of an encoding
+func BytesPerSample(encoding malgo.FormatType) int {
+	switch encoding {
+	case malgo.FormatU8:
+		return 1
+	case malgo.FormatS16:
+		return 2
+	case malgo.FormatS24:
+		return 3
+	case malgo.FormatS32, malgo.FormatF32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// encodingName returns a short name for an encoding
+func encodingName(encoding malgo.FormatType) string {
+	switch encoding {
+	case malgo.FormatU8:
+		return "u8"
+	case malgo.FormatS16:
+		return "s16"
+	case malgo.FormatS24:
+		return "s24"
+	case malgo.FormatS32:
+		return "s32"
+	case malgo.FormatF32:
+		return "f32"
+	default:
+		return "unknown"
+	}
+}
+
+// Decode converts little endian samples to floats in [-1, 1)
+func Decode(data []byte, encoding malgo.FormatType) []float32 {
+	size := BytesPerSample(encoding)
+	if size == 0 {
+		return nil
+	}
+
+	out := make([]float32, len(data)/size)
+	for i := range out {
+		b := data[i*size:]
+		switch encoding {
+		case malgo.FormatU8:
+			out[i] = (float32(b[0]) - 128) / 128
+		case malgo.FormatS16:
+			out[i] = float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+		case malgo.FormatS24:
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			out[i] = float32(v) / 8388608
+		case malgo.FormatS32:
+			out[i] = float32(float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648)
+		case malgo.FormatF32:
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		}
+	}
+	return out
+}
+
+// Encode converts floats in [-1, 1) to little endian samples, clipping out of range values
+func Encode(samples []float32, encoding malgo.FormatType) []byte {
+	size := BytesPerSample(encoding)
+	out := make([]byte, len(samples)*size)
+
+	for i, sample := range samples {
+		b := out[i*size:]
+		if encoding == malgo.FormatF32 {
+			binary.LittleEndian.PutUint32(b, math.Float32bits(sample))
+			continue
+		}
+
+		s := math.Max(-1, math.Min(float64(sample), 1))
+		switch encoding {
+		case malgo.FormatU8:
+			b[0] = uint8(math.Min(s*128+128, 255))
+		case malgo.FormatS16:
+			binary.LittleEndian.PutUint16(b, uint16(int16(math.Min(s*32768, math.MaxInt16))))
+		case malgo.FormatS24:
+			v := int32(math.Min(s*8388608, 8388607))
+			b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+		case malgo.FormatS32:
+			binary.LittleEndian.PutUint32(b, uint32(int32(math.Min(s*2147483648, math.MaxInt32))))
+		}
+	}
+	return out
+}
+
+// MixChannels converts interleaved samples between channel counts. Downmixing
+// averages all input channels; upmixing copies the mono mix to every channel.
+func MixChannels(samples []float32, from, to int) []float32 {
+	if from == to || from <= 0 || to <= 0 {
+		return samples
+	}
+
+	frames := len(samples) / from
+	out := make([]float32, frames*to)
+	for i := 0; i < frames; i++ {
+		var sum float32
+		for c := 0; c < from; c++ {
+			sum += samples[i*from+c]
+		}
+		mix := sum / float32(from)
+		for c := 0; c < to; c++ {
+			out[i*to+c] = mix
+		}
+	}
+	return out
+}
+
+// Converter converts a stream of audio between formats
+type Converter struct {
+	from       Format
+	to         Format
+	resamplers []*Resampler
+	pending    []byte
+}
+
+// NewConverter creates a converter between two formats
+func NewConverter(from, to Format) (*Converter, error) {
+	for _, f := range []Format{from, to} {
+		if f.SampleRate <= 0 || f.Channels <= 0 || BytesPerSample(f.Encoding) == 0 {
+			return nil, fmt.Errorf("invalid audio format: %s", f)
+		}
+	}
+
+	c := &Converter{from: from, to: to}
+	if from.SampleRate != to.SampleRate {
+		for i := 0; i < to.Channels; i++ {
+			c.resamplers = append(c.resamplers, NewResampler(from.SampleRate, to.SampleRate))
+		}
+	}
+	return c, nil
+}
+
+// Convert converts a chunk of audio. Incomplete frames are kept for the next call.
+func (c *Converter) Convert(data []byte) []byte {
+	if c.from == c.to {
+		return data
+	}
+
+	frameBytes := BytesPerSample(c.from.Encoding) * c.from.Channels
+	c.pending = append(c.pending, data...)
+	usable := len(c.pending) - len(c.pending)%frameBytes
+	samples := Decode(c.pending[:usable], c.from.Encoding)
+	c.pending = append([]byte(nil), c.pending[usable:]...)
+
+	samples = MixChannels(samples, c.from.Channels, c.to.Channels)
+
+	if len(c.resamplers) > 0 {
+		channels := c.to.Channels
+		frames := len(samples) / channels
+		var out []float32
+		for ch, resampler := range c.resamplers {
+			in := make([]float32, frames)
+			for i := range in {
+				in[i] = samples[i*channels+ch]
+			}
+			resampled := resampler.Process(in)
+			if out == nil {
+				out = make([]float32, len(resampled)*channels)
+			}
+			for i, sample := range resampled {
+				out[i*channels+ch] = sample
+			}
+		}
+		samples = out
+	}
+
+	return Encode(samples, c.to.Encoding)
+}
@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gen2brain/malgo"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	samples := []float32{0, 0.5, -0.5, -1, 0.999}
+
+	for _, encoding := range []malgo.FormatType{malgo.FormatU8, malgo.FormatS16, malgo.FormatS24, malgo.FormatS32, malgo.FormatF32} {
+		data := Encode(samples, encoding)
+		if len(data) != len(samples)*BytesPerSample(encoding) {
+			t.Errorf("%s: encoded %d bytes", encodingName(encoding), len(data))
+			continue
+		}
+
+		decoded := Decode(data, encoding)
+		tolerance := 1.0 / 128
+		if encoding != malgo.FormatU8 {
+			tolerance = 1.0 / 32768
+		}
+		for i := range samples {
+			if math.Abs(float64(decoded[i]-samples[i])) > tolerance {
+				t.Errorf("%s: sample %d = %f, expected %f", encodingName(encoding), i, decoded[i], samples[i])
+			}
+		}
+	}
+}
+
+func TestEncodeClips(t *testing.T) {
+	decoded := Decode(Encode([]float32{1.5, -2}, malgo.FormatS16), malgo.FormatS16)
+	if decoded[0] < 0.999 || decoded[1] != -1 {
+		t.Errorf("Clipped samples = %v", decoded)
+	}
+}
+
+func TestMixChannels(t *testing.T) {
+	mono := MixChannels([]float32{0.2, 0.4, -1, 1}, 2, 1)
+	if len(mono) != 2 || math.Abs(float64(mono[0])-0.3) > 1e-6 || mono[1] != 0 {
+		t.Errorf("Downmix = %v", mono)
+	}
+
+	stereo := MixChannels([]float32{0.1, 0.2}, 1, 2)
+	if len(stereo) != 4 || stereo[2] != 0.2 || stereo[3] != 0.2 {
+		t.Errorf("Upmix = %v", stereo)
+	}
+}
+
+// sine returns n samples of a sine wave
+func sine(frequency float64, rate, n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(0.5 * math.Sin(2*math.Pi*frequency*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+// toneLevel returns the amplitude of a frequency in a signal
+func toneLevel(x []float32, frequency float64, rate int) float64 {
+	var re, im float64
+	for i, sample := range x {
+		angle := 2 * math.Pi * frequency * float64(i) / float64(rate)
+		re += float64(sample) * math.Cos(angle)
+		im += float64(sample) * math.Sin(angle)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(x))
+}
+
+func TestResamplerPreservesTone(t *testing.T) {
+	for _, inRate := range []int{8000, 22050, 44100, 48000} {
+		resampler := NewResampler(inRate, 16000)
+		out := resampler.Process(sine(1000, inRate, inRate))
+
+		if diff := len(out) - 16000; diff < -1 || diff > 1 {
+			t.Errorf("%d Hz: %d output samples, expected 16000", inRate, len(out))
+		}
+
+		// Skip the filter's start-up transient
+		level := toneLevel(out[800:], 1000, 16000)
+		if math.Abs(level-0.5) > 0.02 {
+			t.Errorf("%d Hz: 1 kHz level = %f, expected 0.5", inRate, level)
+		}
+	}
+}
+
+func TestResamplerRejectsAliases(t *testing.T) {
+	// 12 kHz is above the 8 kHz Nyquist frequency of the output
+	resampler := NewResampler(48000, 16000)
+	out := resampler.Process(sine(12000, 48000, 48000))
+
+	var peak float64
+	for _, sample := range out[800:] {
+		peak = math.Max(peak, math.Abs(float64(sample)))
+	}
+	if peak > 0.01 {
+		t.Errorf("Aliased tone peak = %f", peak)
+	}
+}
+
+func TestResamplerStreaming(t *testing.T) {
+	in := sine(440, 44100, 4410)
+
+	whole := NewResampler(44100, 16000).Process(in)
+
+	chunked := NewResampler(44100, 16000)
+	var out []float32
+	for start := 0; start < len(in); start += 441 {
+		out = append(out, chunked.Process(in[start:start+441])...)
+	}
+
+	if len(out) != len(whole) {
+		t.Fatalf("Chunked output has %d samples, expected %d", len(out), len(whole))
+	}
+	for i := range out {
+		if math.Abs(float64(out[i]-whole[i])) > 1e-5 {
+			t.Fatalf("Sample %d = %f, expected %f", i, out[i], whole[i])
+		}
+	}
+}
+
+func TestConverter(t *testing.T) {
+	from := Format{SampleRate: 48000, Channels: 2, Encoding: malgo.FormatF32}
+	converter, err := NewConverter(from, PipelineFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10ms of stereo float audio, split mid-frame
+	data := Encode(make([]float32, 960), malgo.FormatF32)
+	out := converter.Convert(data[:1001])
+	out = append(out, converter.Convert(data[1001:])...)
+
+	if len(out) != 320 {
+		t.Errorf("Expected 160 PCM16 samples, got %d bytes", len(out))
+	}
+
+	if _, err := NewConverter(Format{SampleRate: 16000}, PipelineFormat); err == nil {
+		t.Error("Expected error for an invalid format")
+	}
+}
+
+func TestSampleFormat(t *testing.T) {
+	tests := []struct {
+		bitDepth int
+		float    bool
+		want     malgo.FormatType
+	}{
+		{16, false, malgo.FormatS16},
+		{0, false, malgo.FormatS16},
+		{24, false, malgo.FormatS24},
+		{32, true, malgo.FormatF32},
+	}
+	for _, tt := range tests {
+		if got, err := SampleFormat(tt.bitDepth, tt.float); err != nil || got != tt.want {
+			t.Errorf("SampleFormat(%d, %v) = %v, %v", tt.bitDepth, tt.float, got, err)
+		}
+	}
+
+	if _, err := SampleFormat(12, false); err == nil {
+		t.Error("Expected error for 12-bit samples")
+	}
+	if _, err := SampleFormat(16, true); err == nil {
+		t.Error("Expected error for 16-bit float samples")
+	}
+}
@@ -0,0 +1,114 @@
+// Package dsp cleans up captured speech before detection and recognition.
+package dsp
+
+import (
+	"context"
+
+	"github.com/gen2brain/malgo"
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+// Processor is one stage of the chain, working on mono float samples in [-1, 1)
+type Processor interface {
+	Process(samples []float32) []float32
+}
+
+// Config selects the stages of the chain. Zero values disable a stage.
+type Config struct {
+	EchoCancellation bool
+	EchoTailMs       int     // echo tail covered by the canceller, default 128ms
+	HighPassHz       float64 // high-pass cutoff, also removes DC
+	NoiseSuppression bool
+	NoiseReductionDB float64 // maximum attenuation of noise, default 20 dB
+	AGC              bool
+	AGCTargetDB      float64 // target level in dBFS, default -20
+	AGCMaxGainDB     float64 // default 30 dB
+	NoiseGateDB      float64 // gate threshold in dBFS, e.g. -50
+}
+
+// Chain runs PCM16 audio through the configured stages: echo cancellation,
+// high-pass, noise suppression, AGC, then the noise gate
+type Chain struct {
+	stages []Processor
+	echo   *EchoCanceller
+	frames chan []byte
+}
+
+// NewChain creates a processing chain for mono audio at the sample rate
+func NewChain(config Config, sampleRate int) *Chain {
+	c := &Chain{frames: make(chan []byte, 100)}
+
+	// Echo cancellation needs the linear echo path, so it runs first
+	if config.EchoCancellation {
+		c.echo = NewEchoCanceller(sampleRate, config.EchoTailMs)
+		c.stages = append(c.stages, c.echo)
+	}
+	if config.HighPassHz > 0 {
+		c.stages = append(c.stages, NewHighPass(config.HighPassHz, sampleRate))
+	}
+	if config.NoiseSuppression {
+		c.stages = append(c.stages, NewNoiseSuppressor(sampleRate, config.NoiseReductionDB))
+	}
+	if config.AGC {
+		c.stages = append(c.stages, NewAGC(sampleRate, config.AGCTargetDB, config.AGCMaxGainDB))
+	}
+	if config.NoiseGateDB != 0 {
+		c.stages = append(c.stages, NewNoiseGate(sampleRate, config.NoiseGateDB))
+	}
+
+	return c
+}
+
+// Enabled returns whether any stage is configured
+func (c *Chain) Enabled() bool {
+	return len(c.stages) > 0
+}
+
+// Reference feeds audio sent to the speaker to the echo canceller, if enabled
+func (c *Chain) Reference(pcm []byte, sampleRate, channels int) {
+	if c.echo != nil {
+		c.echo.Reference(pcm, sampleRate, channels)
+	}
+}
+
+// Process runs a mono PCM16 frame through every stage
+func (c *Chain) Process(pcm []byte) []byte {
+	if len(c.stages) == 0 {
+		return pcm
+	}
+
+	samples := audio.Decode(pcm, malgo.FormatS16)
+	for _, stage := range c.stages {
+		samples = stage.Process(samples)
+	}
+	return audio.Encode(samples, malgo.FormatS16)
+}
+
+// Frames returns the channel of processed frames
+func (c *Chain) Frames() <-chan []byte {
+	return c.frames
+}
+
+// Run processes frames until the channel closes or the context is cancelled.
+// A slow consumer blocks Run instead of losing frames. The output channel is
+// closed on return.
+func (c *Chain) Run(ctx context.Context, frames <-chan []byte) {
+	defer close(c.frames)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+
+			select {
+			case c.frames <- c.Process(frame):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package dsp
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/malgo"
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+const sampleRate = 16000
+
+// sine returns n samples of a sine wave
+func sine(frequency, amplitude float64, n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate))
+	}
+	return out
+}
+
+// whiteNoise returns n samples of uniform white noise
+func whiteNoise(amplitude float64, n int, rng *rand.Rand) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amplitude * (2*rng.Float64() - 1))
+	}
+	return out
+}
+
+// rms returns the RMS level of samples
+func rms(x []float32) float64 {
+	var sum float64
+	for _, sample := range x {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+// db converts an amplitude ratio to decibels
+func db(ratio float64) float64 {
+	return 20 * math.Log10(ratio)
+}
+
+// process runs samples through a stage in 20ms blocks
+func process(stage Processor, x []float32) []float32 {
+	var out []float32
+	for start := 0; start < len(x); start += 320 {
+		block := append([]float32(nil), x[start:min(start+320, len(x))]...)
+		out = append(out, stage.Process(block)...)
+	}
+	return out
+}
+
+func TestHighPass(t *testing.T) {
+	// DC offset plus mains hum plus a 1 kHz tone
+	x := sine(1000, 0.3, sampleRate)
+	hum := sine(50, 0.3, sampleRate)
+	for i := range x {
+		x[i] += 0.2 + hum[i]
+	}
+
+	out := process(NewHighPass(100, sampleRate), x)
+	tail := out[sampleRate/2:]
+
+	var mean float64
+	for _, sample := range tail {
+		mean += float64(sample)
+	}
+	mean /= float64(len(tail))
+
+	if math.Abs(mean) > 0.001 {
+		t.Errorf("DC offset after filter = %f", mean)
+	}
+	// Only the 1 kHz tone remains: RMS of a 0.3 sine is 0.212
+	if level := rms(tail); math.Abs(level-0.212) > 0.02 {
+		t.Errorf("RMS after filter = %f, expected about 0.212", level)
+	}
+}
+
+func TestAGC(t *testing.T) {
+	agc := NewAGC(sampleRate, -20, 30)
+
+	// A quiet -40 dBFS tone is brought up towards -20 dBFS
+	out := process(agc, sine(300, 0.01*math.Sqrt2, 3*sampleRate))
+	if level := db(rms(out[2*sampleRate:])); math.Abs(level+20) > 2 {
+		t.Errorf("Quiet tone level = %.1f dBFS, expected -20", level)
+	}
+
+	// A loud tone is turned down without clipping
+	out = process(agc, sine(300, 0.9, 3*sampleRate))
+	if level := db(rms(out[2*sampleRate:])); math.Abs(level+20) > 2 {
+		t.Errorf("Loud tone level = %.1f dBFS, expected -20", level)
+	}
+	for _, sample := range out {
+		if math.Abs(float64(sample)) >= 1 {
+			t.Fatal("AGC output clipped")
+		}
+	}
+
+	// Gain is limited to 30 dB
+	agc = NewAGC(sampleRate, -20, 30)
+	out = process(agc, sine(300, 0.0005*math.Sqrt2, 3*sampleRate))
+	if level := db(rms(out[2*sampleRate:])); level > -35 {
+		t.Errorf("Very quiet tone level = %.1f dBFS, expected at most 30 dB of gain", level)
+	}
+}
+
+func TestNoiseGate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	gate := NewNoiseGate(sampleRate, -45)
+
+	// Background noise at -60 dBFS is muted
+	out := process(gate, whiteNoise(0.001*math.Sqrt(3), sampleRate, rng))
+	if level := rms(out[sampleRate/2:]); level > 1e-5 {
+		t.Errorf("Gated noise RMS = %g", level)
+	}
+
+	// Speech-level audio passes
+	x := sine(300, 0.3, sampleRate)
+	out = process(gate, append([]float32(nil), x...))
+	if level := rms(out[sampleRate/2:]); math.Abs(level-rms(x[sampleRate/2:])) > 0.001 {
+		t.Errorf("Open gate RMS = %f, expected %f", level, rms(x[sampleRate/2:]))
+	}
+}
+
+func TestNoiseSuppressor(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	suppressor := NewNoiseSuppressor(sampleRate, 20)
+
+	// One second of fan noise, then a tone in the same noise
+	noise := whiteNoise(0.05, 2*sampleRate, rng)
+	tone := sine(500, 0.3, sampleRate)
+	x := append([]float32(nil), noise...)
+	for i := range tone {
+		x[sampleRate+i] += tone[i]
+	}
+
+	out := process(suppressor, x)
+	if len(out) != len(x) {
+		t.Fatalf("Output has %d samples, expected %d", len(out), len(x))
+	}
+
+	// Noise alone is reduced by at least 10 dB
+	reduction := db(rms(out[sampleRate/2:sampleRate]) / rms(x[sampleRate/2:sampleRate]))
+	if reduction > -10 {
+		t.Errorf("Noise reduced by %.1f dB", -reduction)
+	}
+
+	// The tone survives: compare against the clean tone, allowing for the frame delay
+	delay := 512
+	var errPower, tonePower float64
+	for i := sampleRate / 4; i < sampleRate-delay; i++ {
+		diff := float64(out[sampleRate+i+delay] - tone[i])
+		errPower += diff * diff
+		tonePower += float64(tone[i]) * float64(tone[i])
+	}
+	snrIn := db(rms(tone) / 0.05 * math.Sqrt(3))
+	snrOut := 10 * math.Log10(tonePower/errPower)
+	if snrOut < snrIn+5 {
+		t.Errorf("SNR improved from %.1f dB to %.1f dB", snrIn, snrOut)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := NewChain(Config{HighPassHz: 80, NoiseSuppression: true, AGC: true, NoiseGateDB: -50}, sampleRate)
+	if !chain.Enabled() || len(chain.stages) != 4 {
+		t.Fatalf("Expected 4 stages, got %d", len(chain.stages))
+	}
+
+	pcm := audio.Encode(sine(300, 0.1, 320), malgo.FormatS16)
+	if out := chain.Process(pcm); len(out) != len(pcm) {
+		t.Errorf("Processed %d bytes, expected %d", len(out), len(pcm))
+	}
+
+	empty := NewChain(Config{}, sampleRate)
+	if empty.Enabled() {
+		t.Error("Expected an empty chain to be disabled")
+	}
+	if out := empty.Process(pcm); &out[0] != &pcm[0] {
+		t.Error("Expected an empty chain to pass audio through")
+	}
+}
+
+func TestChainRunDoesNotDrop(t *testing.T) {
+	chain := NewChain(Config{HighPassHz: 80}, sampleRate)
+
+	// More frames than the output channel holds, read only after they are all sent
+	frames := make(chan []byte, 300)
+	pcm := audio.Encode(sine(300, 0.1, 320), malgo.FormatS16)
+	for i := 0; i < 300; i++ {
+		frames <- pcm
+	}
+	close(frames)
+	go chain.Run(context.Background(), frames)
+
+	time.Sleep(20 * time.Millisecond)
+	received := 0
+	for range chain.Frames() {
+		received++
+	}
+	if received != 300 {
+		t.Errorf("Received %d frames, want 300", received)
+	}
+}
+
+// echoPath simulates the speaker-to-mic path: a delayed, decaying impulse response
+func echoPath(reference []float32) []float32 {
+	response := map[int]float64{40: 0.4, 41: 0.2, 90: -0.1, 200: 0.05}
+	out := make([]float32, len(reference))
+	for i := range out {
+		var sum float64
+		for delay, gain := range response {
+			if i >= delay {
+				sum += gain * float64(reference[i-delay])
+			}
+		}
+		out[i] = float32(sum)
+	}
+	return out
+}
+
+func TestEchoCanceller(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	canceller := NewEchoCanceller(sampleRate, 32)
+
+	// Three seconds of Jarvis talking, heard through the room
+	reference := whiteNoise(0.3, 3*sampleRate, rng)
+	capture := echoPath(reference)
+
+	var out []float32
+	for start := 0; start < len(capture); start += 320 {
+		canceller.Reference(audio.Encode(reference[start:start+320], malgo.FormatS16), sampleRate, 1)
+		block := append([]float32(nil), capture[start:start+320]...)
+		out = append(out, canceller.Process(block)...)
+	}
+
+	// Echo return loss enhancement after convergence
+	erle := db(rms(capture[2*sampleRate:]) / rms(out[2*sampleRate:]))
+	if erle < 20 {
+		t.Errorf("ERLE = %.1f dB, expected at least 20 dB", erle)
+	}
+
+	// The user talks over playback: their voice survives while the echo is removed
+	reference = whiteNoise(0.3, sampleRate, rng)
+	echo := echoPath(reference)
+	voice := sine(300, 0.3, sampleRate)
+	out = nil
+	for start := 0; start < sampleRate; start += 320 {
+		canceller.Reference(audio.Encode(reference[start:start+320], malgo.FormatS16), sampleRate, 1)
+		block := make([]float32, 320)
+		for i := range block {
+			block[i] = echo[start+i] + voice[start+i]
+		}
+		out = append(out, canceller.Process(block)...)
+	}
+
+	var errPower float64
+	for i := range out {
+		diff := float64(out[i] - voice[i])
+		errPower += diff * diff
+	}
+	residual := db(math.Sqrt(errPower/float64(len(out))) / rms(echo))
+	if residual > -10 {
+		t.Errorf("Echo during double talk reduced by only %.1f dB", -residual)
+	}
+}
+
+func TestEchoCancellerWithoutPlayback(t *testing.T) {
+	canceller := NewEchoCanceller(sampleRate, 32)
+
+	// No reference: capture passes through unchanged
+	x := sine(300, 0.3, 320)
+	out := canceller.Process(append([]float32(nil), x...))
+	for i := range x {
+		if out[i] != x[i] {
+			t.Fatalf("Sample %d = %f, expected %f", i, out[i], x[i])
+		}
+	}
+}
+
+func TestEchoReferenceConversion(t *testing.T) {
+	canceller := NewEchoCanceller(sampleRate, 32)
+
+	// 10ms of 48 kHz stereo playback becomes 10ms of 16 kHz mono reference
+	canceller.Reference(make([]byte, 480*2*2), 48000, 2)
+	if len(canceller.queue) != 160 {
+		t.Errorf("Queued %d reference samples, expected 160", len(canceller.queue))
+	}
+
+	// The queue is bounded when capture isn't running
+	for i := 0; i < 100; i++ {
+		canceller.Reference(make([]byte, 320*2), sampleRate, 1)
+	}
+	if len(canceller.queue) != sampleRate/2 {
+		t.Errorf("Queue has %d samples, expected %d", len(canceller.queue), sampleRate/2)
+	}
+}
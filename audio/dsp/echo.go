@@ -0,0 +1,134 @@
+package dsp
+
+import (
+	"math"
+	"sync"
+
+	"github.com/gen2brain/malgo"
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+const (
+	// echoStepSize is the NLMS step size
+	echoStepSize = 0.5
+
+	// doubleTalkThreshold is the Geigel detector threshold: capture louder
+	// than the recent reference peak times this is near-end speech. Speakers
+	// and mics in a room rarely couple with more than unity gain.
+	doubleTalkThreshold = 1.0
+)
+
+// EchoCanceller removes the playback signal (Jarvis's own voice) from the
+// capture stream with an NLMS adaptive filter. The playback reference is fed
+// with Reference as the player consumes it, and is paired with capture
+// samples in order. Adaptation is frozen while the user talks over playback
+// so barge-in speech isn't cancelled.
+type EchoCanceller struct {
+	sampleRate int
+	taps       int
+	weights    []float64
+	history    []float64 // reference samples, stored twice for contiguous windows
+	index      int
+	energy     float64 // reference energy in the window
+	peak       float64 // decaying reference peak for double-talk detection
+	peakDecay  float64
+	hangover   int
+	hold       int
+
+	queue      []float32 // reference samples waiting for capture
+	maxQueue   int
+	converter  *audio.Converter
+	convertFor audio.Format
+	mu         sync.Mutex
+}
+
+// NewEchoCanceller creates an echo canceller covering an echo tail in ms (default 128)
+func NewEchoCanceller(sampleRate, tailMs int) *EchoCanceller {
+	if tailMs <= 0 {
+		tailMs = 128
+	}
+	taps := sampleRate * tailMs / 1000
+
+	return &EchoCanceller{
+		sampleRate: sampleRate,
+		taps:       taps,
+		weights:    make([]float64, taps),
+		history:    make([]float64, 2*taps),
+		peakDecay:  math.Exp(-1 / float64(taps)),
+		hold:       sampleRate * 30 / 1000,
+		maxQueue:   sampleRate / 2,
+	}
+}
+
+// Reference adds PCM16 audio sent to the speaker. It is converted to the
+// capture sample rate; if capture falls behind, the oldest samples are dropped.
+func (e *EchoCanceller) Reference(pcm []byte, sampleRate, channels int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	from := audio.Format{SampleRate: sampleRate, Channels: channels, Encoding: malgo.FormatS16}
+	to := audio.Format{SampleRate: e.sampleRate, Channels: 1, Encoding: malgo.FormatS16}
+	if e.converter == nil || e.convertFor != from {
+		converter, err := audio.NewConverter(from, to)
+		if err != nil {
+			return
+		}
+		e.converter = converter
+		e.convertFor = from
+	}
+
+	e.queue = append(e.queue, audio.Decode(e.converter.Convert(pcm), malgo.FormatS16)...)
+	if len(e.queue) > e.maxQueue {
+		e.queue = e.queue[len(e.queue)-e.maxQueue:]
+	}
+}
+
+// Process cancels echo in captured samples in place
+func (e *EchoCanceller) Process(samples []float32) []float32 {
+	e.mu.Lock()
+	n := min(len(samples), len(e.queue))
+	reference := make([]float32, len(samples))
+	copy(reference, e.queue[:n])
+	e.queue = e.queue[n:]
+	e.mu.Unlock()
+
+	for i, sample := range samples {
+		samples[i] = float32(e.cancel(float64(sample), float64(reference[i])))
+	}
+	return samples
+}
+
+// cancel processes one capture sample with its reference sample
+func (e *EchoCanceller) cancel(capture, reference float64) float64 {
+	// Slide the reference window: window[k] is the reference k samples ago
+	e.index = (e.index - 1 + e.taps) % e.taps
+	oldest := e.history[e.index]
+	e.history[e.index] = reference
+	e.history[e.index+e.taps] = reference
+	window := e.history[e.index : e.index+e.taps]
+
+	e.energy = math.Max(e.energy+reference*reference-oldest*oldest, 0)
+	e.peak = math.Max(math.Abs(reference), e.peak*e.peakDecay)
+
+	var estimate float64
+	for k, w := range e.weights {
+		estimate += w * window[k]
+	}
+	residual := capture - estimate
+
+	// Geigel double-talk detector
+	if math.Abs(capture) > doubleTalkThreshold*e.peak {
+		e.hangover = e.hold
+	} else if e.hangover > 0 {
+		e.hangover--
+	}
+
+	if e.hangover == 0 && e.energy > 1e-6 {
+		step := echoStepSize * residual / (e.energy + 1e-6)
+		for k := range e.weights {
+			e.weights[k] += step * window[k]
+		}
+	}
+
+	return residual
+}
@@ -0,0 +1,164 @@
+package dsp
+
+import (
+	"math"
+)
+
+// HighPass is a second-order Butterworth high-pass filter
+type HighPass struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	x1, x2     float64
+	y1, y2     float64
+}
+
+// NewHighPass creates a high-pass filter with the cutoff frequency in Hz
+func NewHighPass(cutoff float64, sampleRate int) *HighPass {
+	w0 := 2 * math.Pi * cutoff / float64(sampleRate)
+	q := 1 / math.Sqrt2
+	alpha := math.Sin(w0) / (2 * q)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	return &HighPass{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Process filters samples in place
+func (h *HighPass) Process(samples []float32) []float32 {
+	for i, sample := range samples {
+		x := float64(sample)
+		y := h.b0*x + h.b1*h.x1 + h.b2*h.x2 - h.a1*h.y1 - h.a2*h.y2
+		h.x2, h.x1 = h.x1, x
+		h.y2, h.y1 = h.y1, y
+		samples[i] = float32(y)
+	}
+	return samples
+}
+
+// AGC is an automatic gain control that brings speech to a target level.
+// The level is tracked with a fast attack and slow release envelope, and
+// frames below the noise floor don't change the gain, so pauses aren't
+// amplified into hiss.
+type AGC struct {
+	target     float64
+	maxGain    float64
+	noiseFloor float64
+	attack     float64
+	release    float64
+	envelope   float64
+	gain       float64
+}
+
+// NewAGC creates an AGC with a target level in dBFS and a maximum gain in dB
+// (defaults -20 dBFS and 30 dB)
+func NewAGC(sampleRate int, targetDB, maxGainDB float64) *AGC {
+	if targetDB == 0 {
+		targetDB = -20
+	}
+	if maxGainDB == 0 {
+		maxGainDB = 30
+	}
+
+	return &AGC{
+		target:     dbToLinear(targetDB),
+		maxGain:    dbToLinear(maxGainDB),
+		noiseFloor: dbToLinear(-55),
+		attack:     smoothing(10, sampleRate),
+		release:    smoothing(500, sampleRate),
+		gain:       1,
+	}
+}
+
+// Process applies the gain in place
+func (a *AGC) Process(samples []float32) []float32 {
+	for i, sample := range samples {
+		level := math.Abs(float64(sample))
+		if level > a.envelope {
+			a.envelope += a.attack * (level - a.envelope)
+		} else {
+			a.envelope += a.release * (level - a.envelope)
+		}
+
+		if a.envelope > a.noiseFloor {
+			// The envelope of a sine is its peak; aim its RMS at the target
+			desired := math.Min(a.target*math.Sqrt2/a.envelope, a.maxGain)
+			a.gain += a.release * (desired - a.gain)
+		}
+
+		// Cut the gain immediately rather than clip on sudden loud sounds
+		if peak := math.Max(level, a.envelope); a.gain*peak > 0.99 {
+			a.gain = 0.99 / peak
+		}
+
+		samples[i] = float32(float64(sample) * a.gain)
+	}
+	return samples
+}
+
+// NoiseGate mutes audio below a threshold, opening quickly and closing after
+// a hold time so word endings aren't cut
+type NoiseGate struct {
+	threshold float64
+	attack    float64
+	release   float64
+	envelope  float64
+	hold      int
+	holdLeft  int
+	gain      float64
+}
+
+// NewNoiseGate creates a gate with a threshold in dBFS
+func NewNoiseGate(sampleRate int, thresholdDB float64) *NoiseGate {
+	return &NoiseGate{
+		threshold: dbToLinear(thresholdDB),
+		attack:    smoothing(1, sampleRate),
+		release:   smoothing(50, sampleRate),
+		hold:      sampleRate / 5, // 200ms
+	}
+}
+
+// Process gates samples in place
+func (g *NoiseGate) Process(samples []float32) []float32 {
+	for i, sample := range samples {
+		level := math.Abs(float64(sample))
+		if level > g.envelope {
+			g.envelope += g.attack * (level - g.envelope)
+		} else {
+			g.envelope += g.release * (level - g.envelope)
+		}
+
+		target := 0.0
+		if g.envelope >= g.threshold {
+			g.holdLeft = g.hold
+			target = 1
+		} else if g.holdLeft > 0 {
+			g.holdLeft--
+			target = 1
+		}
+
+		if target > g.gain {
+			g.gain += g.attack * (target - g.gain)
+		} else {
+			g.gain += g.release * (target - g.gain)
+		}
+
+		samples[i] = float32(float64(sample) * g.gain)
+	}
+	return samples
+}
+
+// smoothing returns the one-pole coefficient for a time constant in ms
+func smoothing(ms float64, sampleRate int) float64 {
+	return 1 - math.Exp(-1000/(ms*float64(sampleRate)))
+}
+
+// dbToLinear converts decibels to an amplitude ratio
+func dbToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
@@ -0,0 +1,133 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+const (
+	// overSubtraction scales the noise estimate removed from each bin
+	overSubtraction = 2.0
+
+	// noiseAdaptRate is how fast the noise estimate follows quiet frames
+	noiseAdaptRate = 0.05
+
+	// speechRatio is how far above the noise estimate a frame must be to
+	// count as speech and not update the estimate
+	speechRatio = 4.0
+)
+
+// NoiseSuppressor removes stationary noise (fans, hum, TV hiss) by spectral
+// subtraction. Audio is processed in 50% overlapping windowed frames; the
+// noise spectrum is learnt from the first frames and from frames without
+// speech, and its power is subtracted from every bin down to a floor.
+type NoiseSuppressor struct {
+	size     int
+	hop      int
+	window   []float64
+	floor    float64
+	noise    []float64
+	learnt   int
+	input    []float32 // samples not yet processed
+	overlap  []float64 // second half of the previous output frame
+	previous []float64 // second half of the previous input frame
+	output   []float32 // processed samples not yet returned
+}
+
+// NewNoiseSuppressor creates a noise suppressor. reductionDB limits how much
+// a bin is attenuated (default 20 dB).
+func NewNoiseSuppressor(sampleRate int, reductionDB float64) *NoiseSuppressor {
+	if reductionDB <= 0 {
+		reductionDB = 20
+	}
+
+	// About 32ms frames
+	size := audio.NextPowerOfTwo(sampleRate * 32 / 1000)
+	hop := size / 2
+
+	// Square-root Hann for analysis and synthesis sums to one at 50% overlap
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)))
+	}
+
+	return &NoiseSuppressor{
+		size:     size,
+		hop:      hop,
+		window:   window,
+		floor:    dbToLinear(-reductionDB),
+		noise:    make([]float64, size/2+1),
+		overlap:  make([]float64, hop),
+		previous: make([]float64, hop),
+		// Start with a hop of silence so output is always available
+		output: make([]float32, hop),
+	}
+}
+
+// Process returns as many samples as it is given, delayed by one frame (about 32ms)
+func (n *NoiseSuppressor) Process(samples []float32) []float32 {
+	n.input = append(n.input, samples...)
+
+	for len(n.input) >= n.hop {
+		n.processHop(n.input[:n.hop])
+		n.input = n.input[n.hop:]
+	}
+
+	out := make([]float32, len(samples))
+	copy(out, n.output)
+	n.output = n.output[len(samples):]
+	return out
+}
+
+// processHop processes one frame made of the previous hop and this one
+func (n *NoiseSuppressor) processHop(hop []float32) {
+	spectrum := make([]complex128, n.size)
+	for i := 0; i < n.hop; i++ {
+		spectrum[i] = complex(n.previous[i]*n.window[i], 0)
+		spectrum[n.hop+i] = complex(float64(hop[i])*n.window[n.hop+i], 0)
+		n.previous[i] = float64(hop[i])
+	}
+	audio.FFT(spectrum)
+
+	bins := n.size/2 + 1
+	power := make([]float64, bins)
+	var framePower, noisePower float64
+	for k := 0; k < bins; k++ {
+		magnitude := cmplx.Abs(spectrum[k])
+		power[k] = magnitude * magnitude
+		framePower += power[k]
+		noisePower += n.noise[k]
+	}
+
+	// Learn the noise from the first frames, then from frames without speech
+	if n.learnt < 10 {
+		n.learnt++
+		for k := range n.noise {
+			n.noise[k] += (power[k] - n.noise[k]) / float64(n.learnt)
+		}
+	} else if framePower < speechRatio*noisePower {
+		for k := range n.noise {
+			n.noise[k] += noiseAdaptRate * (power[k] - n.noise[k])
+		}
+	}
+
+	for k := 0; k < bins; k++ {
+		gain := n.floor
+		if power[k] > 0 {
+			gain = math.Max(math.Sqrt(math.Max(1-overSubtraction*n.noise[k]/power[k], 0)), n.floor)
+		}
+		spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < n.size/2 {
+			spectrum[n.size-k] = cmplx.Conj(spectrum[k])
+		}
+	}
+	audio.IFFT(spectrum)
+
+	// Overlap-add the first half with the previous frame's second half
+	for i := 0; i < n.hop; i++ {
+		n.output = append(n.output, float32(n.overlap[i]+real(spectrum[i])*n.window[i]))
+		n.overlap[i] = real(spectrum[n.hop+i]) * n.window[n.hop+i]
+	}
+}
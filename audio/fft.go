@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+)
+
+// FFT computes an in-place radix-2 fast Fourier transform. The length of x
+// must be a power of two.
+func FFT(x []complex128) {
+	fft(x, false)
+}
+
+// IFFT computes an in-place inverse FFT, scaled by 1/len(x)
+func IFFT(x []complex128) {
+	fft(x, true)
+	scale := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+// NextPowerOfTwo returns the smallest power of two >= n
+func NextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// fft is an iterative Cooley-Tukey transform
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	if n <= 1 {
+		return
+	}
+	if n&(n-1) != 0 {
+		panic("audio: FFT length must be a power of two")
+	}
+
+	// Bit-reversal permutation
+	shift := 64 - bits.Len(uint(n-1))
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		angle := sign * 2 * math.Pi / float64(size)
+		step := complex(math.Cos(angle), math.Sin(angle))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := x[start+k]
+				odd := w * x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFTPeak(t *testing.T) {
+	const n = 64
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(math.Sin(2*math.Pi*4*float64(i)/n), 0)
+	}
+
+	FFT(x)
+
+	// A sine with 4 cycles per frame has its energy in bins 4 and n-4
+	for k := 0; k < n; k++ {
+		magnitude := cmplx.Abs(x[k])
+		if k == 4 || k == n-4 {
+			if math.Abs(magnitude-n/2) > 1e-9 {
+				t.Errorf("Bin %d magnitude = %f, expected %d", k, magnitude, n/2)
+			}
+		} else if magnitude > 1e-9 {
+			t.Errorf("Bin %d magnitude = %f, expected 0", k, magnitude)
+		}
+	}
+}
+
+func TestIFFTRoundTrip(t *testing.T) {
+	x := []complex128{1, 2, 3, 4, -1, -2, 0.5, 8}
+	y := append([]complex128(nil), x...)
+
+	FFT(y)
+	IFFT(y)
+
+	for i := range x {
+		if cmplx.Abs(x[i]-y[i]) > 1e-9 {
+			t.Errorf("Sample %d = %v, expected %v", i, y[i], x[i])
+		}
+	}
+}
+
+func TestNextPowerOfTwo(t *testing.T) {
+	tests := map[int]int{0: 1, 1: 1, 2: 2, 3: 4, 320: 512, 512: 512}
+	for n, want := range tests {
+		if got := NextPowerOfTwo(n); got != want {
+			t.Errorf("NextPowerOfTwo(%d) = %d, expected %d", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// Player plays PCM16 audio through the default output device
+type Player struct {
+	ctx        *malgo.AllocatedContext
+	device     *malgo.Device
+	sampleRate uint32
+	channels   uint32
+	pending    []byte
+	done       chan struct{}
+	turn       chan struct{} // held by the running playback, queues the others
+	onStart    func()
+	onStop     func()
+	onOutput   func(pcm []byte, sampleRate, channels int)
+	mu         sync.Mutex
+}
+
+// NewPlayer creates a new audio player
+func NewPlayer() (*Player, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize malgo context: %w", err)
+	}
+
+	return &Player{ctx: ctx, turn: make(chan struct{}, 1)}, nil
+}
+
+// SetSpeakingHandlers sets callbacks run when playback starts and stops,
+// e.g. to duck the microphone while Jarvis is speaking
+func (p *Player) SetSpeakingHandlers(onStart, onStop func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onStart = onStart
+	p.onStop = onStop
+}
+
+// SetReferenceHandler sets a callback receiving every buffer sent to the
+// speaker, silence included, e.g. as the reference for echo cancellation.
+// It runs on the audio thread and must not block.
+func (p *Player) SetReferenceHandler(handler func(pcm []byte, sampleRate, channels int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onOutput = handler
+}
+
+// Play plays PCM16 audio and blocks until it finishes, is stopped, or the
+// context is cancelled. While another playback runs it waits for its turn,
+// so overlapping calls play one after the other in the order they came.
+func (p *Player) Play(ctx context.Context, pcm []byte, sampleRate, channels int) error {
+	if len(pcm) == 0 {
+		return nil
+	}
+
+	select {
+	case p.turn <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.turn }()
+
+	p.mu.Lock()
+	if err := p.openDevice(uint32(sampleRate), uint32(channels)); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+
+	done := make(chan struct{})
+	p.pending = pcm
+	p.done = done
+	onStart := p.onStart
+	p.mu.Unlock()
+
+	if onStart != nil {
+		onStart()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.Stop()
+		return ctx.Err()
+	}
+}
+
+// Stop interrupts the current playback. Playbacks waiting for their turn
+// still run unless their context is cancelled.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done != nil {
+		p.pending = nil
+		p.finish()
+	}
+}
+
+// IsPlaying returns whether audio is currently being played
+func (p *Player) IsPlaying() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done != nil
+}
+
+// Close releases the output device
+func (p *Player) Close() error {
+	p.Stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.device != nil {
+		p.device.Uninit()
+		p.device = nil
+	}
+
+	if p.ctx != nil {
+		_ = p.ctx.Uninit()
+		p.ctx.Free()
+		p.ctx = nil
+	}
+
+	return nil
+}
+
+// openDevice (re)opens the output device for the given format. Caller holds p.mu.
+func (p *Player) openDevice(sampleRate, channels uint32) error {
+	if p.device != nil && p.sampleRate == sampleRate && p.channels == channels {
+		return nil
+	}
+
+	if p.done != nil {
+		return fmt.Errorf("player is busy")
+	}
+
+	if p.device != nil {
+		p.device.Uninit()
+		p.device = nil
+	}
+
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
+	deviceConfig.Playback.Format = malgo.FormatS16
+	deviceConfig.Playback.Channels = channels
+	deviceConfig.SampleRate = sampleRate
+	deviceConfig.Alsa.NoMMap = 1
+
+	device, err := malgo.InitDevice(p.ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: func(pOutputSample, pInputSamples []byte, framecount uint32) {
+			p.fill(pOutputSample)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize playback device: %w", err)
+	}
+
+	if err := device.Start(); err != nil {
+		device.Uninit()
+		return fmt.Errorf("failed to start playback device: %w", err)
+	}
+
+	p.device = device
+	p.sampleRate = sampleRate
+	p.channels = channels
+	log.Printf("Audio player opened: %d Hz, %d channel(s)", sampleRate, channels)
+
+	return nil
+}
+
+// fill copies pending audio into the output buffer, padding with silence
+func (p *Player) fill(out []byte) {
+	p.mu.Lock()
+
+	n := copy(out, p.pending)
+	p.pending = p.pending[n:]
+	for i := n; i < len(out); i++ {
+		out[i] = 0
+	}
+
+	if len(p.pending) == 0 && p.done != nil {
+		p.finish()
+	}
+
+	onOutput, sampleRate, channels := p.onOutput, int(p.sampleRate), int(p.channels)
+	p.mu.Unlock()
+
+	if onOutput != nil {
+		onOutput(append([]byte(nil), out...), sampleRate, channels)
+	}
+}
+
+// finish signals the end of playback. Caller holds p.mu.
+func (p *Player) finish() {
+	close(p.done)
+	p.done = nil
+
+	if p.onStop != nil {
+		go p.onStop()
+	}
+}
+
+// Tone generates a mono PCM16 sine tone with short fades, e.g. for acknowledgement beeps
+func Tone(frequency float64, duration time.Duration, sampleRate int) []byte {
+	samples := int(duration.Seconds() * float64(sampleRate))
+	fade := sampleRate / 100 // 10ms
+	pcm := make([]byte, samples*2)
+
+	for i := 0; i < samples; i++ {
+		amplitude := 0.3
+		if i < fade {
+			amplitude *= float64(i) / float64(fade)
+		} else if samples-i < fade {
+			amplitude *= float64(samples-i) / float64(fade)
+		}
+		sample := amplitude * math.MaxInt16 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(sample)))
+	}
+
+	return pcm
+}
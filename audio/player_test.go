@@ -0,0 +1,127 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+func TestPlayerFill(t *testing.T) {
+	stopped := make(chan struct{})
+	player := &Player{}
+	player.SetSpeakingHandlers(nil, func() { close(stopped) })
+
+	done := make(chan struct{})
+	player.pending = []byte{1, 2, 3, 4, 5, 6}
+	player.done = done
+
+	out := []byte{9, 9, 9, 9}
+	player.fill(out)
+	if !bytes.Equal(out, []byte{1, 2, 3, 4}) || !player.IsPlaying() {
+		t.Fatalf("First buffer = %v, playing = %v", out, player.IsPlaying())
+	}
+
+	// The remainder is padded with silence and playback finishes
+	player.fill(out)
+	if !bytes.Equal(out, []byte{5, 6, 0, 0}) {
+		t.Errorf("Second buffer = %v", out)
+	}
+	if player.IsPlaying() {
+		t.Error("Expected playback to have finished")
+	}
+
+	select {
+	case <-done:
+	default:
+		t.Error("Expected done channel to be closed")
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Expected stop handler to be called")
+	}
+}
+
+func TestPlayerReference(t *testing.T) {
+	player := &Player{sampleRate: 22050, channels: 1}
+
+	var reference []byte
+	var rate int
+	player.SetReferenceHandler(func(pcm []byte, sampleRate, channels int) {
+		reference = append(reference, pcm...)
+		rate = sampleRate
+	})
+
+	player.pending = []byte{1, 2}
+	player.done = make(chan struct{})
+
+	// Everything sent to the speaker is reported, silence included
+	out := make([]byte, 4)
+	player.fill(out)
+	player.fill(out)
+
+	if !bytes.Equal(reference, []byte{1, 2, 0, 0, 0, 0, 0, 0}) || rate != 22050 {
+		t.Errorf("Reference = %v at %d Hz", reference, rate)
+	}
+}
+
+func TestPlayerQueue(t *testing.T) {
+	// An open device in the right format, so Play doesn't touch the hardware
+	player := &Player{device: &malgo.Device{}, sampleRate: 16000, channels: 1, turn: make(chan struct{}, 1)}
+
+	first := make(chan error, 1)
+	go func() { first <- player.Play(context.Background(), []byte{1, 2}, 16000, 1) }()
+	waitPlaying(t, player)
+
+	second := make(chan error, 1)
+	go func() { second <- player.Play(context.Background(), []byte{3, 4}, 16000, 1) }()
+
+	// A playback waiting for its turn can be cancelled without stopping the current one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() { cancelled <- player.Play(ctx, []byte{5, 6}, 16000, 1) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("Cancelled Play() error = %v, want %v", err, context.Canceled)
+	}
+
+	out := make([]byte, 2)
+	player.fill(out)
+	if !bytes.Equal(out, []byte{1, 2}) {
+		t.Errorf("First buffer = %v", out)
+	}
+	if err := <-first; err != nil {
+		t.Errorf("First Play() error = %v", err)
+	}
+
+	// The queued playback starts once the first one is done
+	waitPlaying(t, player)
+	player.fill(out)
+	if !bytes.Equal(out, []byte{3, 4}) {
+		t.Errorf("Second buffer = %v", out)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("Second Play() error = %v", err)
+	}
+}
+
+// waitPlaying waits until the player has audio to play
+func waitPlaying(t *testing.T, player *Player) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		player.mu.Lock()
+		pending := len(player.pending)
+		player.mu.Unlock()
+		if pending > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for playback")
+}
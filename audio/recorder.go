@@ -1,52 +1,73 @@
 package audio
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 
 	"github.com/gen2brain/malgo"
 )
 
-// AudioConfig holds the audio configuration
+// AudioConfig holds the audio configuration. Captured audio is always
+// delivered as PCM16 at SampleRate and Channels; Format is the sample
+// encoding requested from the device.
 type AudioConfig struct {
 	SampleRate uint32
 	Channels   uint32
 	Format     malgo.FormatType
 	BufferSize uint32
+
+	// Native captures in the device's native format and converts it in software
+	Native bool
 }
 
 // Recorder handles microphone input
 type Recorder struct {
-	ctx        *malgo.AllocatedContext
-	device     *malgo.Device
-	config     AudioConfig
-	audioQueue chan []byte
-	stopChan   chan struct{}
-	isRunning  bool
-	mu         sync.Mutex
+	ctx       *malgo.AllocatedContext
+	device    *malgo.Device
+	config    AudioConfig
+	ring      *Ring
+	frames    <-chan []byte
+	stopChan  chan struct{}
+	isRunning bool
+	converter *Converter
+	gainControl
+	mu sync.Mutex
 }
 
-// NewRecorder creates a new audio recorder
+// NewRecorder creates a new audio recorder capturing 16-bit PCM
 func NewRecorder(sampleRate, channels, bufferSize uint32) (*Recorder, error) {
+	return NewRecorderWithConfig(AudioConfig{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Format:     malgo.FormatS16, // 16-bit PCM
+		BufferSize: bufferSize,
+	})
+}
+
+// NewRecorderWithConfig creates a new audio recorder
+func NewRecorderWithConfig(config AudioConfig) (*Recorder, error) {
 	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize malgo context: %w", err)
 	}
 
+	if config.Format == malgo.FormatUnknown {
+		config.Format = malgo.FormatS16
+	}
+
 	recorder := &Recorder{
-		ctx: ctx,
-		config: AudioConfig{
-			SampleRate: sampleRate,
-			Channels:   channels,
-			Format:     malgo.FormatS16, // 16-bit PCM
-			BufferSize: bufferSize,
-		},
-		audioQueue: make(chan []byte, 100),
-		stopChan:   make(chan struct{}),
-		isRunning:  false,
+		ctx:       ctx,
+		config:    config,
+		ring:      NewRing("recorder", int(config.BufferSize), 100),
+		stopChan:  make(chan struct{}),
+		isRunning: false,
 	}
+	recorder.frames = recorder.ring.Frames(context.Background())
+	recorder.SetGain(1)
 
 	return recorder, nil
 }
@@ -65,6 +86,11 @@ func (r *Recorder) Start() error {
 	deviceConfig.Capture.Channels = r.config.Channels
 	deviceConfig.SampleRate = r.config.SampleRate
 	deviceConfig.Alsa.NoMMap = 1
+	if r.config.Native {
+		deviceConfig.Capture.Format = malgo.FormatUnknown
+		deviceConfig.Capture.Channels = 0
+		deviceConfig.SampleRate = 0
+	}
 
 	// Callback for audio data
 	onRecvFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
@@ -72,14 +98,16 @@ func (r *Recorder) Start() error {
 			// Create a copy of the buffer
 			data := make([]byte, len(pInputSamples))
 			copy(data, pInputSamples)
-
-			// Send to queue (non-blocking)
-			select {
-			case r.audioQueue <- data:
-			default:
-				// Queue is full, skip this frame
-				log.Println("Warning: Audio queue is full, dropping frame")
+			if r.converter != nil {
+				data = r.converter.Convert(data)
+				if len(data) == 0 {
+					return
+				}
 			}
+			applyGain(data, r.Gain())
+
+			// Chunk into fixed-size frames; never blocks the audio thread
+			r.ring.Write(data)
 		}
 	}
 
@@ -92,6 +120,24 @@ func (r *Recorder) Start() error {
 
 	r.device = device
 
+	// Convert whatever the device delivers to the pipeline format
+	captured := Format{
+		SampleRate: int(device.SampleRate()),
+		Channels:   int(device.CaptureChannels()),
+		Encoding:   device.CaptureFormat(),
+	}
+	target := Format{SampleRate: int(r.config.SampleRate), Channels: int(r.config.Channels), Encoding: malgo.FormatS16}
+	r.converter = nil
+	if captured != target {
+		converter, err := NewConverter(captured, target)
+		if err != nil {
+			device.Uninit()
+			return fmt.Errorf("unsupported capture format: %w", err)
+		}
+		r.converter = converter
+		log.Printf("Converting capture format %s to %s", captured, target)
+	}
+
 	if err := r.device.Start(); err != nil {
 		return fmt.Errorf("failed to start device: %w", err)
 	}
@@ -124,9 +170,14 @@ func (r *Recorder) Stop() error {
 	return nil
 }
 
+// Ring returns the buffer captured frames are written to, for adding readers
+func (r *Recorder) Ring() *Ring {
+	return r.ring
+}
+
 // GetAudioChannel returns the channel for receiving audio data
 func (r *Recorder) GetAudioChannel() <-chan []byte {
-	return r.audioQueue
+	return r.frames
 }
 
 // Close closes the recorder and releases resources
@@ -140,7 +191,7 @@ func (r *Recorder) Close() error {
 		r.ctx.Free()
 	}
 
-	close(r.audioQueue)
+	r.ring.Close()
 
 	return nil
 }
@@ -154,6 +205,21 @@ func ConvertToPCM16(data []byte) []int16 {
 	return samples
 }
 
+// RMS returns the root mean square level of PCM16 audio
+func RMS(data []byte) float64 {
+	samples := len(data) / 2
+	if samples == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := 0; i+1 < len(data); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(data[i:])))
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(samples))
+}
+
 // IsRunning returns whether the recorder is currently running
 func (r *Recorder) IsRunning() bool {
 	r.mu.Lock()
@@ -33,3 +33,23 @@ func TestConvertToPCM16(t *testing.T) {
 		t.Errorf("Expected first sample %d, got %d", expectedFirst, samples[0])
 	}
 }
+
+func TestApplyGain(t *testing.T) {
+	// Samples 1000, -1000 and 30000
+	data := []byte{0xE8, 0x03, 0x18, 0xFC, 0x30, 0x75}
+
+	applyGain(data, 2)
+
+	samples := ConvertToPCM16(data)
+	if samples[0] != 2000 || samples[1] != -2000 || samples[2] != 32767 {
+		t.Errorf("Samples = %v", samples)
+	}
+
+	applyGain(data, 0)
+	for _, sample := range ConvertToPCM16(data) {
+		if sample != 0 {
+			t.Errorf("Expected silence after muting, got %v", ConvertToPCM16(data))
+			break
+		}
+	}
+}
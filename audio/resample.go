@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"math"
+)
+
+// resamplerTaps is the number of filter taps per polyphase branch
+const resamplerTaps = 32
+
+// Resampler is a streaming polyphase FIR sample rate converter. The rate
+// ratio is reduced to up/down; a windowed-sinc low-pass filter at the lower
+// Nyquist frequency is split into up branches so only the taps needed for
+// each output sample are computed.
+type Resampler struct {
+	up      int
+	down    int
+	filter  [][]float32 // [phase][tap]
+	history []float32   // last resamplerTaps-1 input samples
+	next    int         // upsampled position of the next output, relative to the current block
+}
+
+// NewResampler creates a resampler between two sample rates
+func NewResampler(inRate, outRate int) *Resampler {
+	g := gcd(inRate, outRate)
+	up, down := outRate/g, inRate/g
+
+	// Prototype low-pass filter at the upsampled rate
+	length := up * resamplerTaps
+	cutoff := 0.5 / float64(max(up, down)) * 0.9
+	center := float64(length-1) / 2
+
+	filter := make([][]float32, up)
+	for phase := range filter {
+		filter[phase] = make([]float32, resamplerTaps)
+	}
+	for i := 0; i < length; i++ {
+		x := float64(i) - center
+		h := 2 * cutoff
+		if x != 0 {
+			h = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		// Blackman window
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(length-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(length-1))
+		filter[i%up][i/up] = float32(h * w * float64(up))
+	}
+
+	return &Resampler{
+		up:      up,
+		down:    down,
+		filter:  filter,
+		history: make([]float32, resamplerTaps-1),
+	}
+}
+
+// Process resamples a block of mono samples, keeping state between calls
+func (r *Resampler) Process(in []float32) []float32 {
+	if r.up == r.down {
+		return in
+	}
+
+	buf := append(r.history, in...)
+	offset := len(r.history)
+
+	out := make([]float32, 0, len(in)*r.up/r.down+1)
+	for {
+		index := r.next / r.up
+		if index >= len(in) {
+			break
+		}
+		taps := r.filter[r.next%r.up]
+
+		var acc float32
+		j := offset + index
+		for k, tap := range taps {
+			acc += tap * buf[j-k]
+		}
+		out = append(out, acc)
+		r.next += r.down
+	}
+
+	r.next -= len(in) * r.up
+	copy(r.history, buf[len(buf)-len(r.history):])
+
+	return out
+}
+
+// gcd returns the greatest common divisor
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
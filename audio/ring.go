@@ -0,0 +1,207 @@
+package audio
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+)
+
+// Ring is a lock-free ring buffer of fixed-size audio frames with one writer
+// and one reader. Writes never block: a reader that falls more than the
+// capacity behind skips the oldest frames and counts an overrun.
+type Ring struct {
+	name      string
+	frameSize int
+	slots     []atomic.Pointer[ringFrame]
+	written   atomic.Uint64 // frames published
+	overruns  atomic.Uint64 // frames lost by the reader
+	signal    atomic.Pointer[chan struct{}]
+	closed    atomic.Bool
+	pending   []byte // partial frame, only touched by the writer
+	next      uint64 // next frame to read, only touched by the reader
+}
+
+// ringFrame is one published frame
+type ringFrame struct {
+	seq  uint64
+	data []byte
+}
+
+// NewRing creates a ring of capacity frames of frameSize bytes. The name
+// identifies the ring in overrun warnings.
+func NewRing(name string, frameSize, capacity int) *Ring {
+	if frameSize <= 0 {
+		frameSize = 3200
+	}
+	if capacity <= 0 {
+		capacity = 100
+	}
+
+	r := &Ring{
+		name:      name,
+		frameSize: frameSize,
+		slots:     make([]atomic.Pointer[ringFrame], capacity),
+	}
+	signal := make(chan struct{})
+	r.signal.Store(&signal)
+	return r
+}
+
+// FrameSize returns the size of each frame in bytes
+func (r *Ring) FrameSize() int {
+	return r.frameSize
+}
+
+// Write splits audio of any length into frames and publishes every complete
+// frame. Write must only be called from one goroutine at a time.
+func (r *Ring) Write(data []byte) {
+	if r.closed.Load() {
+		return
+	}
+
+	r.pending = append(r.pending, data...)
+	published := false
+	for len(r.pending) >= r.frameSize {
+		frame := make([]byte, r.frameSize)
+		copy(frame, r.pending)
+		r.pending = r.pending[r.frameSize:]
+
+		seq := r.written.Load()
+		r.slots[seq%uint64(len(r.slots))].Store(&ringFrame{seq: seq, data: frame})
+		r.written.Store(seq + 1)
+		published = true
+	}
+
+	if published {
+		r.wake()
+	}
+}
+
+// WriteFrom writes frames from a channel until it closes or the context is
+// cancelled, then closes the ring
+func (r *Ring) WriteFrom(ctx context.Context, frames <-chan []byte) {
+	defer r.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			r.Write(frame)
+		}
+	}
+}
+
+// Close marks the end of the stream. The reader drains the remaining frames.
+func (r *Ring) Close() {
+	if r.closed.CompareAndSwap(false, true) {
+		r.wake()
+	}
+}
+
+// Written returns the number of frames written
+func (r *Ring) Written() uint64 {
+	return r.written.Load()
+}
+
+// wake notifies a waiting reader
+func (r *Ring) wake() {
+	signal := make(chan struct{})
+	old := r.signal.Swap(&signal)
+	close(*old)
+}
+
+// TryRead returns the next frame without waiting. ok is false if no frame
+// is available. Only one goroutine may read.
+func (r *Ring) TryRead() (frame []byte, ok bool) {
+	capacity := uint64(len(r.slots))
+
+	for {
+		written := r.written.Load()
+		if r.next >= written {
+			return nil, false
+		}
+
+		if written-r.next > capacity {
+			r.skip(written - capacity)
+			continue
+		}
+
+		f := r.slots[r.next%capacity].Load()
+		if f == nil || f.seq != r.next {
+			// Overwritten since written was loaded
+			r.skip(r.written.Load() - capacity)
+			continue
+		}
+
+		r.next++
+		return f.data, true
+	}
+}
+
+// Read waits for the next frame. ok is false when the ring is closed and
+// drained or the context is cancelled.
+func (r *Ring) Read(ctx context.Context) (frame []byte, ok bool) {
+	for {
+		signal := *r.signal.Load()
+		if frame, ok := r.TryRead(); ok {
+			return frame, true
+		}
+		if r.closed.Load() {
+			return nil, false
+		}
+
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// Frames returns a channel fed from the ring, for channel-based consumers.
+// It becomes the ring's reader, so call it once and don't Read as well. The
+// channel is closed when the ring closes or the context is cancelled.
+func (r *Ring) Frames(ctx context.Context) <-chan []byte {
+	frames := make(chan []byte)
+	go func() {
+		defer close(frames)
+		reported := uint64(0)
+		for {
+			frame, ok := r.Read(ctx)
+			if !ok {
+				return
+			}
+
+			if lost := r.Overruns(); lost > reported {
+				log.Printf("Warning: Audio reader %s fell behind, %d frame(s) lost", r.name, lost-reported)
+				reported = lost
+			}
+
+			select {
+			case frames <- frame:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return frames
+}
+
+// Overruns returns the number of frames the reader lost
+func (r *Ring) Overruns() uint64 {
+	return r.overruns.Load()
+}
+
+// skip moves the reader forward to seq, counting the frames lost
+func (r *Ring) skip(seq uint64) {
+	if seq <= r.next {
+		return
+	}
+	lost := seq - r.next
+	r.next = seq
+	r.overruns.Add(lost)
+}
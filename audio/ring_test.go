@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRingChunksFrames(t *testing.T) {
+	ring := NewRing("test", 4, 8)
+
+	// Writes of any size come out as 4-byte frames
+	ring.Write([]byte{1, 2, 3})
+	ring.Write([]byte{4, 5, 6, 7, 8, 9})
+	ring.Write([]byte{10, 11, 12})
+
+	var frames [][]byte
+	for {
+		frame, ok := ring.TryRead()
+		if !ok {
+			break
+		}
+		frames = append(frames, frame)
+	}
+
+	if len(frames) != 3 || !bytes.Equal(frames[0], []byte{1, 2, 3, 4}) || !bytes.Equal(frames[2], []byte{9, 10, 11, 12}) {
+		t.Errorf("Frames = %v", frames)
+	}
+	if ring.Written() != 3 {
+		t.Errorf("Written = %d", ring.Written())
+	}
+}
+
+func TestRingOverrun(t *testing.T) {
+	ring := NewRing("test", 1, 4)
+
+	// The writer never blocks; a reader 10 frames behind keeps the newest 4
+	for i := 0; i < 10; i++ {
+		ring.Write([]byte{byte(i)})
+	}
+
+	var got []byte
+	for {
+		frame, ok := ring.TryRead()
+		if !ok {
+			break
+		}
+		got = append(got, frame[0])
+	}
+
+	if !bytes.Equal(got, []byte{6, 7, 8, 9}) {
+		t.Errorf("Frames = %v", got)
+	}
+	if ring.Overruns() != 6 {
+		t.Errorf("Overruns = %d, expected 6", ring.Overruns())
+	}
+}
+
+func TestRingReadWaitsAndCloses(t *testing.T) {
+	ring := NewRing("test", 2, 4)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ring.Write([]byte{1, 2})
+		ring.Close()
+	}()
+
+	frame, ok := ring.Read(context.Background())
+	if !ok || !bytes.Equal(frame, []byte{1, 2}) {
+		t.Fatalf("Read = %v, %v", frame, ok)
+	}
+
+	if _, ok := ring.Read(context.Background()); ok {
+		t.Error("Expected end of stream after Close")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := NewRing("test", 2, 4).Read(ctx); ok {
+		t.Error("Expected cancelled read to fail")
+	}
+}
+
+func TestRingConcurrentReader(t *testing.T) {
+	const frames = 2000
+	ring := NewRing("test", 8, 64)
+
+	total := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		last := -1
+		for frame := range ring.Frames(context.Background()) {
+			seq := int(frame[0]) | int(frame[1])<<8
+			if seq <= last {
+				t.Errorf("Reader went backwards: %d after %d", seq, last)
+				return
+			}
+			last = seq
+			total++
+		}
+		total += int(ring.Overruns())
+	}()
+
+	for i := 0; i < frames; i++ {
+		ring.Write([]byte{byte(i), byte(i >> 8), 0, 0, 0, 0, 0, 0})
+	}
+	ring.Close()
+	<-done
+
+	// Every frame is either delivered or counted as an overrun
+	if total != frames {
+		t.Errorf("Reader accounted for %d frames, expected %d", total, frames)
+	}
+}
@@ -0,0 +1,249 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/gen2brain/malgo"
+)
+
+// RTPSource receives PCM16 audio over RTP/UDP from satellite microphones
+// (e.g. ESP32 boards). Payload types 10 and 11 are L16 (RFC 3551: big
+// endian, 44.1 kHz, stereo and mono) and are converted to the source's
+// format; dynamic payload types are taken as little endian PCM16 already
+// in that format.
+type RTPSource struct {
+	listen     string
+	format     Format
+	converters map[uint8]*Converter // for the static L16 payload types
+	conn       net.PacketConn
+	ring       *Ring
+	frames     <-chan []byte
+	lastSeq    uint16
+	haveSeq    bool
+	lost       uint64
+	isRunning  bool
+	gainControl
+	wg sync.WaitGroup
+	mu sync.Mutex
+}
+
+// NewRTPSource creates an RTP source listening on a UDP address (e.g. ":5004")
+// for PCM16 audio at sampleRate and channels. Packets are regrouped into
+// frames of frameSize bytes.
+func NewRTPSource(listen string, sampleRate, channels, frameSize int) *RTPSource {
+	if listen == "" {
+		listen = ":5004"
+	}
+
+	source := &RTPSource{
+		listen:     listen,
+		format:     Format{SampleRate: sampleRate, Channels: channels, Encoding: malgo.FormatS16},
+		converters: make(map[uint8]*Converter),
+		ring:       NewRing("rtp", frameSize, 100),
+	}
+	source.frames = source.ring.Frames(context.Background())
+	source.SetGain(1)
+
+	return source
+}
+
+// Start begins listening for RTP packets
+func (s *RTPSource) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("audio source is already running")
+	}
+
+	conn, err := net.ListenPacket("udp", s.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for RTP: %w", err)
+	}
+
+	s.conn = conn
+	s.isRunning = true
+	s.wg.Add(1)
+	go s.receive(conn)
+
+	log.Printf("Listening for RTP audio on %s", conn.LocalAddr())
+	return nil
+}
+
+// Addr returns the listening address, once started
+func (s *RTPSource) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
+// receive reads packets until the connection is closed
+func (s *RTPSource) receive(conn net.PacketConn) {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		packet, err := parseRTP(buf[:n])
+		if err != nil {
+			log.Printf("Warning: Dropping invalid RTP packet: %v", err)
+			continue
+		}
+		if !s.accept(packet.sequence) {
+			continue
+		}
+
+		data := make([]byte, len(packet.payload)-len(packet.payload)%2)
+		copy(data, packet.payload)
+		if packet.payloadType == 10 || packet.payloadType == 11 {
+			if data, err = s.convertL16(packet.payloadType, data); err != nil {
+				log.Printf("Warning: Dropping RTP packet: %v", err)
+				continue
+			}
+		}
+		applyGain(data, s.Gain())
+
+		s.write(data)
+	}
+}
+
+// convertL16 converts a big endian 44.1 kHz L16 payload to the source's
+// format, keeping a converter per payload type so resampling is continuous
+func (s *RTPSource) convertL16(payloadType uint8, data []byte) ([]byte, error) {
+	for i := 0; i < len(data); i += 2 {
+		data[i], data[i+1] = data[i+1], data[i]
+	}
+
+	converter, ok := s.converters[payloadType]
+	if !ok {
+		channels := 1
+		if payloadType == 10 {
+			channels = 2
+		}
+		from := Format{SampleRate: 44100, Channels: channels, Encoding: malgo.FormatS16}
+		var err error
+		if converter, err = NewConverter(from, s.format); err != nil {
+			return nil, err
+		}
+		s.converters[payloadType] = converter
+	}
+	return converter.Convert(data), nil
+}
+
+// write adds received audio to the ring
+func (s *RTPSource) write(data []byte) {
+	s.ring.Write(data)
+}
+
+// accept tracks sequence numbers, counting lost packets and rejecting late ones
+func (s *RTPSource) accept(seq uint16) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.haveSeq {
+		delta := seq - s.lastSeq
+		if delta == 0 || delta >= 0x8000 {
+			return false
+		}
+		s.lost += uint64(delta - 1)
+	}
+
+	s.lastSeq = seq
+	s.haveSeq = true
+	return true
+}
+
+// Lost returns the number of packets missing from the sequence
+func (s *RTPSource) Lost() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lost
+}
+
+// Stop stops listening
+func (s *RTPSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return nil
+	}
+
+	s.isRunning = false
+	return s.conn.Close()
+}
+
+// Close stops listening and closes the audio channel
+func (s *RTPSource) Close() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	s.wg.Wait()
+	s.ring.Close()
+	return nil
+}
+
+// GetAudioChannel returns the channel for receiving audio data
+func (s *RTPSource) GetAudioChannel() <-chan []byte {
+	return s.frames
+}
+
+// rtpPacket is a parsed RTP packet
+type rtpPacket struct {
+	payloadType uint8
+	sequence    uint16
+	timestamp   uint32
+	payload     []byte
+}
+
+// parseRTP parses an RTP packet (RFC 3550)
+func parseRTP(data []byte) (*rtpPacket, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("packet too short: %d bytes", len(data))
+	}
+	if data[0]>>6 != 2 {
+		return nil, fmt.Errorf("unsupported RTP version %d", data[0]>>6)
+	}
+
+	padding := data[0]&0x20 != 0
+	extension := data[0]&0x10 != 0
+	csrcCount := int(data[0] & 0x0F)
+
+	packet := &rtpPacket{
+		payloadType: data[1] & 0x7F,
+		sequence:    binary.BigEndian.Uint16(data[2:4]),
+		timestamp:   binary.BigEndian.Uint32(data[4:8]),
+	}
+
+	offset := 12 + 4*csrcCount
+	if extension {
+		if len(data) < offset+4 {
+			return nil, fmt.Errorf("truncated header extension")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+	}
+
+	end := len(data)
+	if padding && end > offset {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, fmt.Errorf("truncated packet")
+	}
+
+	packet.payload = data[offset:end]
+	return packet, nil
+}
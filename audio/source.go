@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+)
+
+// Source produces PCM16 audio frames, from a microphone, a file or the network
+type Source interface {
+	Start() error
+	Stop() error
+	Close() error
+	GetAudioChannel() <-chan []byte
+
+	// SetGain scales captured audio; 0 mutes the source (e.g. while Jarvis is speaking)
+	SetGain(gain float64)
+}
+
+// SourceConfig selects and configures an audio source
+type SourceConfig struct {
+	Type       string // mic, file, stdin, rtp or websocket
+	Path       string // file: WAV or raw PCM16 file
+	Listen     string // rtp, websocket: listen address
+	Token      string // websocket: token satellites must send
+	SampleRate int
+	Channels   int
+	BufferSize int  // bytes per frame
+	Realtime   bool // file, stdin: pace frames at the audio rate
+
+	// Microphone capture format, converted to PCM16 at SampleRate and Channels
+	BitDepth int
+	Float    bool
+	Native   bool // capture in the device's native format
+}
+
+// NewSource creates the configured audio source. The microphone is used when no type is set.
+func NewSource(config SourceConfig) (Source, error) {
+	switch config.Type {
+	case "", "mic":
+		format, err := SampleFormat(config.BitDepth, config.Float)
+		if err != nil {
+			return nil, err
+		}
+		return NewRecorderWithConfig(AudioConfig{
+			SampleRate: uint32(config.SampleRate),
+			Channels:   uint32(config.Channels),
+			Format:     format,
+			BufferSize: uint32(config.BufferSize),
+			Native:     config.Native,
+		})
+	case "file":
+		return NewFileSource(config.Path, config.SampleRate, config.Channels, config.BufferSize, config.Realtime)
+	case "stdin":
+		return NewReaderSource("stdin", os.Stdin, config.SampleRate, config.Channels, config.BufferSize, config.Realtime), nil
+	case "rtp":
+		return NewRTPSource(config.Listen, config.SampleRate, config.Channels, config.BufferSize), nil
+	case "websocket":
+		return NewWebSocketSource(config.Listen, config.Token, config.BufferSize), nil
+	default:
+		return nil, fmt.Errorf("unknown audio source: %s", config.Type)
+	}
+}
+
+// gainControl is the input gain shared by audio sources
+type gainControl struct {
+	bits atomic.Uint64 // math.Float64bits of the gain
+}
+
+// SetGain sets the input gain applied to captured audio. Use 0 to mute the
+// input (e.g. while Jarvis is speaking) and 1 for normal capture.
+func (g *gainControl) SetGain(gain float64) {
+	g.bits.Store(math.Float64bits(gain))
+}
+
+// Gain returns the current input gain
+func (g *gainControl) Gain() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// applyGain scales PCM16 samples in place
+func applyGain(data []byte, gain float64) {
+	if gain == 1 {
+		return
+	}
+
+	for i := 0; i+1 < len(data); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(data[i:]))) * gain
+		if sample > math.MaxInt16 {
+			sample = math.MaxInt16
+		} else if sample < math.MinInt16 {
+			sample = math.MinInt16
+		}
+		binary.LittleEndian.PutUint16(data[i:], uint16(int16(sample)))
+	}
+}
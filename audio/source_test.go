@@ -0,0 +1,325 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/malgo"
+	"github.com/gorilla/websocket"
+)
+
+// collect reads frames until the channel closes or the timeout expires
+func collect(t *testing.T, frames <-chan []byte, want int, timeout time.Duration) []byte {
+	t.Helper()
+
+	var out []byte
+	deadline := time.After(timeout)
+	for len(out) < want {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return out
+			}
+			out = append(out, frame...)
+		case <-deadline:
+			return out
+		}
+	}
+	return out
+}
+
+func TestFileSourceWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "utterance.wav")
+	pcm := make([]byte, 1000)
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	if err := WriteWAVFile(path, pcm, WAVInfo{SampleRate: 16000, Channels: 1, BitsPerSample: 16}); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewSource(SourceConfig{Type: "file", Path: path, SampleRate: 16000, Channels: 1, BufferSize: 320})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var sizes []int
+	var out []byte
+	for frame := range source.GetAudioChannel() {
+		sizes = append(sizes, len(frame))
+		out = append(out, frame...)
+	}
+
+	// The channel closes at the end of the file, with a short last frame
+	if !bytes.Equal(out, pcm) {
+		t.Error("Audio does not match the WAV data")
+	}
+	if len(sizes) != 4 || sizes[3] != 40 {
+		t.Errorf("Frame sizes = %v", sizes)
+	}
+}
+
+func TestFileSourceConvertsWAV(t *testing.T) {
+	// One second of 48 kHz stereo float audio
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	samples := make([]float32, 48000*2)
+	for i := range samples {
+		samples[i] = 0.25
+	}
+	if err := WriteWAVFile(path, Encode(samples, malgo.FormatF32), WAVInfo{SampleRate: 48000, Channels: 2, BitsPerSample: 32, AudioFormat: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewFileSource(path, 16000, 1, 3200, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var out []byte
+	for frame := range source.GetAudioChannel() {
+		out = append(out, frame...)
+	}
+
+	if len(out) != 32000 {
+		t.Errorf("Expected one second of 16 kHz mono PCM16, got %d bytes", len(out))
+	}
+	// Past the filter's start-up transient the level is preserved
+	if sample := int16(binary.LittleEndian.Uint16(out[16000:])); sample < 8100 || sample > 8280 {
+		t.Errorf("Sample = %d, expected about 8192", sample)
+	}
+}
+
+func TestReaderSourceRaw(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audio.raw")
+	pcm := make([]byte, 640)
+	binary.LittleEndian.PutUint16(pcm, 1000)
+	if err := os.WriteFile(path, pcm, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewFileSource(path, 16000, 1, 320, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.SetGain(0.5)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	start := time.Now()
+	out := collect(t, source.GetAudioChannel(), len(pcm), 2*time.Second)
+
+	if len(out) != len(pcm) {
+		t.Fatalf("Read %d bytes, expected %d", len(out), len(pcm))
+	}
+	if int16(binary.LittleEndian.Uint16(out)) != 500 {
+		t.Errorf("Expected gain to be applied, got %d", int16(binary.LittleEndian.Uint16(out)))
+	}
+	// Two 10ms frames paced in real time
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("Realtime source finished in %v", elapsed)
+	}
+}
+
+func TestRTPSource(t *testing.T) {
+	source := NewRTPSource("127.0.0.1:0", 44100, 1, 2)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	conn, err := net.Dial("udp", source.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(seq uint16, payloadType byte, payload []byte) {
+		header := make([]byte, 12)
+		header[0] = 0x80
+		header[1] = payloadType
+		binary.BigEndian.PutUint16(header[2:], seq)
+		if _, err := conn.Write(append(header, payload...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// L16 is big endian 44.1 kHz, the source's rate here; dynamic payloads are little endian
+	send(1, 11, []byte{0x01, 0x02})
+	send(3, 96, []byte{0x03, 0x04})
+	send(2, 96, []byte{0x05, 0x06}) // late, dropped
+
+	out := collect(t, source.GetAudioChannel(), 4, 2*time.Second)
+	if !bytes.Equal(out, []byte{0x02, 0x01, 0x03, 0x04}) {
+		t.Errorf("Audio = %v", out)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if source.Lost() != 1 {
+		t.Errorf("Expected 1 lost packet, got %d", source.Lost())
+	}
+}
+
+func TestRTPSourceResamplesL16(t *testing.T) {
+	source := NewRTPSource("127.0.0.1:0", 16000, 1, 320)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	conn, err := net.Dial("udp", source.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 100ms of 44.1 kHz stereo L16 in 10ms packets
+	for seq := 1; seq <= 10; seq++ {
+		packet := make([]byte, 12+441*4)
+		packet[0] = 0x80
+		packet[1] = 10
+		binary.BigEndian.PutUint16(packet[2:], uint16(seq))
+		if _, err := conn.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// About 1600 mono samples at 16 kHz, not 4410 frames taken as 16 kHz
+	out := collect(t, source.GetAudioChannel(), 1600*2, 2*time.Second)
+	if len(out) < 1400*2 {
+		t.Fatalf("Got %d bytes, want about %d", len(out), 1600*2)
+	}
+	if extra := collect(t, source.GetAudioChannel(), 1, 100*time.Millisecond); len(out)+len(extra) > 1700*2 {
+		t.Errorf("Got %d bytes, want about %d", len(out)+len(extra), 1600*2)
+	}
+}
+
+func TestParseRTPHeaderFields(t *testing.T) {
+	// CSRC count 1, extension with one word, 2 bytes of padding
+	packet := []byte{
+		0xB1, 0x60, 0x00, 0x07, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 2, 3, 4,
+		0xBE, 0xDE, 0x00, 0x01, 9, 9, 9, 9,
+		0xAA, 0xBB, 0x00, 0x02,
+	}
+
+	parsed, err := parseRTP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.payloadType != 96 || parsed.sequence != 7 || !bytes.Equal(parsed.payload, []byte{0xAA, 0xBB}) {
+		t.Errorf("Packet = %+v", parsed)
+	}
+
+	if _, err := parseRTP([]byte{0x40, 0, 0}); err == nil {
+		t.Error("Expected error for a short packet")
+	}
+}
+
+func TestWebSocketSource(t *testing.T) {
+	source := NewWebSocketSource("127.0.0.1:0", "s3cret", 4)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	url := "ws://" + source.Addr().String() + "/audio"
+
+	// Satellites without the token and pages from other origins are refused
+	rejected := []struct {
+		name   string
+		url    string
+		header http.Header
+	}{
+		{"No token", url, nil},
+		{"Wrong token", url + "?token=guess", nil},
+		{"Other origin", url + "?token=s3cret", http.Header{"Origin": {"http://evil.example"}}},
+	}
+	for _, tt := range rejected {
+		if conn, _, err := websocket.DefaultDialer.Dial(tt.url, tt.header); err == nil {
+			conn.Close()
+			t.Errorf("%s: expected the connection to be refused", tt.name)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer s3cret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"device":"esp32-kitchen"}`))
+	conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3, 4})
+
+	out := collect(t, source.GetAudioChannel(), 4, 2*time.Second)
+	if !bytes.Equal(out, []byte{1, 2, 3, 4}) {
+		t.Errorf("Audio = %v", out)
+	}
+}
+
+func TestWebSocketSourceOneSatellite(t *testing.T) {
+	source := NewWebSocketSource("127.0.0.1:0", "s3cret", 4)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "ws://" + source.Addr().String() + "/audio?token=s3cret"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3, 4})
+	collect(t, source.GetAudioChannel(), 4, 2*time.Second)
+
+	// A second satellite would interleave its audio with the first
+	if second, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		second.Close()
+		t.Error("Expected a second satellite to be refused")
+	} else if resp == nil || resp.StatusCode != http.StatusConflict {
+		t.Errorf("Second satellite error = %v", err)
+	}
+
+	// Close disconnects the satellite instead of waiting for it
+	closed := make(chan struct{})
+	go func() {
+		source.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() blocked while a satellite was connected")
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected the satellite to be disconnected")
+	}
+}
+
+func TestWebSocketSourceRequiresToken(t *testing.T) {
+	source := NewWebSocketSource("127.0.0.1:0", "", 4)
+	defer source.Close()
+	if err := source.Start(); err == nil {
+		t.Error("Expected error without a token")
+	}
+}
+
+func TestUnknownSource(t *testing.T) {
+	if _, err := NewSource(SourceConfig{Type: "bluetooth"}); err == nil {
+		t.Error("Expected error for unknown source")
+	}
+}
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"sync"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// ReaderSource reads raw PCM16 audio from a stream such as a file or stdin.
+// Unlike live sources it never drops frames, and the audio channel is closed
+// at the end of the stream.
+type ReaderSource struct {
+	name       string
+	reader     io.Reader
+	closer     io.Closer
+	sampleRate int
+	channels   int
+	bufferSize int
+	realtime   bool
+	audioQueue chan []byte
+	stopChan   chan struct{}
+	isRunning  bool
+	gainControl
+	mu sync.Mutex
+}
+
+// NewReaderSource creates a source reading raw PCM16 from r. If realtime is
+// set, frames are paced at the audio rate as if captured live.
+func NewReaderSource(name string, r io.Reader, sampleRate, channels, bufferSize int, realtime bool) *ReaderSource {
+	if bufferSize <= 0 {
+		bufferSize = 3200
+	}
+
+	source := &ReaderSource{
+		name:       name,
+		reader:     r,
+		sampleRate: sampleRate,
+		channels:   channels,
+		bufferSize: bufferSize,
+		realtime:   realtime,
+		audioQueue: make(chan []byte, 100),
+		stopChan:   make(chan struct{}),
+	}
+	if closer, ok := r.(io.Closer); ok {
+		source.closer = closer
+	}
+	source.SetGain(1)
+
+	return source
+}
+
+// NewFileSource creates a source reading a WAV or raw PCM16 file. WAV files
+// in other formats are converted to PCM16 at the configured rate and channels.
+func NewFileSource(path string, sampleRate, channels, bufferSize int, realtime bool) (*ReaderSource, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audio file path is not configured")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(f)
+	if header, err := reader.Peek(4); err == nil && string(header) == "RIFF" {
+		info, pcm, err := ReadWAV(reader)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if info.AudioFormat != 1 && info.AudioFormat != 3 {
+			return nil, fmt.Errorf("%s is not PCM or float audio", path)
+		}
+		encoding, err := SampleFormat(info.BitsPerSample, info.AudioFormat == 3)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		// Convert recordings in other formats to the configured one
+		from := Format{SampleRate: info.SampleRate, Channels: info.Channels, Encoding: encoding}
+		to := Format{SampleRate: sampleRate, Channels: channels, Encoding: malgo.FormatS16}
+		if from != to {
+			converter, err := NewConverter(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			pcm = converter.Convert(pcm)
+			log.Printf("Converted %s from %s to %s", path, from, to)
+		}
+		return NewReaderSource(path, bytes.NewReader(pcm), sampleRate, channels, bufferSize, realtime), nil
+	}
+
+	source := NewReaderSource(path, reader, sampleRate, channels, bufferSize, realtime)
+	source.closer = f
+	return source, nil
+}
+
+// Start begins reading the stream
+func (s *ReaderSource) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("audio source is already running")
+	}
+
+	s.isRunning = true
+	go s.read()
+
+	log.Printf("Reading audio from %s", s.name)
+	return nil
+}
+
+// read sends frames until the end of the stream or Stop
+func (s *ReaderSource) read() {
+	defer close(s.audioQueue)
+
+	frameDuration := time.Duration(0)
+	if s.realtime && s.sampleRate > 0 && s.channels > 0 {
+		frameDuration = time.Duration(s.bufferSize/2/s.channels) * time.Second / time.Duration(s.sampleRate)
+	}
+	next := time.Now()
+
+	for {
+		data := make([]byte, s.bufferSize)
+		n, err := io.ReadFull(s.reader, data)
+		if n > 0 {
+			data = data[:n-n%2]
+			applyGain(data, s.Gain())
+
+			if frameDuration > 0 {
+				next = next.Add(frameDuration)
+				time.Sleep(time.Until(next))
+			}
+
+			select {
+			case s.audioQueue <- data:
+			case <-s.stopChan:
+				return
+			}
+		}
+
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				log.Printf("Error reading audio from %s: %v", s.name, err)
+			} else {
+				log.Printf("End of audio from %s", s.name)
+			}
+			return
+		}
+	}
+}
+
+// Stop stops reading
+func (s *ReaderSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return nil
+	}
+
+	close(s.stopChan)
+	s.isRunning = false
+	return nil
+}
+
+// Close stops reading and closes the underlying stream
+func (s *ReaderSource) Close() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// GetAudioChannel returns the channel for receiving audio data
+func (s *ReaderSource) GetAudioChannel() <-chan []byte {
+	return s.audioQueue
+}
@@ -0,0 +1,157 @@
+package vad
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+// bands are the sub-bands analysed, the same as the WebRTC VAD's, in Hz
+var bands = [][2]float64{
+	{80, 250}, {250, 500}, {500, 1000}, {1000, 2000}, {2000, 3000}, {3000, 4000},
+}
+
+// bandWeights weight each band's log-likelihood ratio in the global test
+var bandWeights = []float64{0.16, 0.2, 0.2, 0.18, 0.14, 0.12}
+
+// Thresholds per aggressiveness mode: a frame is speech if one band's
+// log-likelihood ratio exceeds the local threshold or the weighted sum
+// exceeds the global one
+var (
+	localThresholds  = []float64{2.0, 2.5, 3.5, 5.0}
+	globalThresholds = []float64{1.0, 1.5, 2.2, 3.2}
+)
+
+const (
+	// minEnergy is the frame energy (dB) below which frames are never speech
+	minEnergy = 25
+
+	// minSeparation keeps the speech model above the noise model (dB)
+	minSeparation = 6
+
+	noiseAdaptRate  = 0.05
+	speechAdaptRate = 0.02
+)
+
+// Adaptive is a pure Go detector borrowing the WebRTC VAD's layout: log
+// energies in six sub-bands are scored against a speech and a noise model,
+// with aggressiveness modes 0-3 and the same sample rates. It is not a port
+// of the WebRTC VAD and its decisions differ: each model is a single Gaussian
+// per band that starts from fixed guesses and adapts to the input, where
+// WebRTC uses two-component GMMs with trained parameters in fixed point.
+type Adaptive struct {
+	sampleRate int
+	mode       int
+	noiseMean  []float64
+	noiseStd   []float64
+	speechMean []float64
+	speechStd  []float64
+}
+
+// NewAdaptive creates an adaptive detector for 8, 16, 32 or 48 kHz audio
+func NewAdaptive(sampleRate, mode int) (*Adaptive, error) {
+	switch sampleRate {
+	case 8000, 16000, 32000, 48000:
+	default:
+		return nil, fmt.Errorf("unsupported VAD sample rate: %d", sampleRate)
+	}
+	if mode < 0 || mode > 3 {
+		return nil, fmt.Errorf("VAD mode must be between 0 and 3, got %d", mode)
+	}
+
+	w := &Adaptive{
+		sampleRate: sampleRate,
+		mode:       mode,
+	}
+	for range bands {
+		w.noiseMean = append(w.noiseMean, 20)
+		w.noiseStd = append(w.noiseStd, 6)
+		w.speechMean = append(w.speechMean, 45)
+		w.speechStd = append(w.speechStd, 10)
+	}
+	return w, nil
+}
+
+// IsSpeech scores the frame's band energies against the speech and noise models
+func (w *Adaptive) IsSpeech(frame []byte) bool {
+	x := samples(frame)
+	if len(x) == 0 {
+		return false
+	}
+
+	features, total := w.bandEnergies(x)
+	if total < minEnergy {
+		w.adapt(features, false)
+		return false
+	}
+
+	global := 0.0
+	speech := false
+	for i, feature := range features {
+		llr := logGaussian(feature, w.speechMean[i], w.speechStd[i]) - logGaussian(feature, w.noiseMean[i], w.noiseStd[i])
+		if llr > localThresholds[w.mode] {
+			speech = true
+		}
+		global += bandWeights[i] * llr
+	}
+	if global > globalThresholds[w.mode] {
+		speech = true
+	}
+
+	w.adapt(features, speech)
+	return speech
+}
+
+// bandEnergies returns the log energy (dB) of each sub-band and of the whole frame
+func (w *Adaptive) bandEnergies(x []float64) ([]float64, float64) {
+	n := audio.NextPowerOfTwo(len(x))
+	spectrum := make([]complex128, n)
+	for i, sample := range x {
+		// Hann window
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(x)-1))
+		spectrum[i] = complex(sample*window, 0)
+	}
+	audio.FFT(spectrum)
+
+	binWidth := float64(w.sampleRate) / float64(n)
+	norm := float64(len(x)) * float64(len(x))
+
+	features := make([]float64, len(bands))
+	totalPower := 0.0
+	for i, band := range bands {
+		power := 0.0
+		for k := int(band[0] / binWidth); k <= int(band[1]/binWidth) && k < n/2; k++ {
+			magnitude := cmplx.Abs(spectrum[k])
+			power += 2 * magnitude * magnitude / norm
+		}
+		features[i] = 10 * math.Log10(power+1)
+		totalPower += power
+	}
+
+	return features, 10 * math.Log10(totalPower+1)
+}
+
+// adapt moves the noise or speech model towards the frame's features
+func (w *Adaptive) adapt(features []float64, speech bool) {
+	for i, feature := range features {
+		if speech {
+			w.speechMean[i] += speechAdaptRate * (feature - w.speechMean[i])
+		} else {
+			delta := feature - w.noiseMean[i]
+			w.noiseMean[i] += noiseAdaptRate * delta
+			w.noiseStd[i] = math.Max(2, w.noiseStd[i]+noiseAdaptRate*(math.Abs(delta)-w.noiseStd[i]))
+		}
+
+		if w.speechMean[i] < w.noiseMean[i]+minSeparation {
+			w.speechMean[i] = w.noiseMean[i] + minSeparation
+		}
+	}
+}
+
+// logGaussian returns the log density of a normal distribution
+func logGaussian(x, mean, std float64) float64 {
+	d := (x - mean) / std
+	return -0.5*d*d - math.Log(std)
+}
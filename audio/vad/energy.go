@@ -0,0 +1,61 @@
+package vad
+
+import (
+	"math"
+)
+
+// speechFloorRate is how fast the noise floor rises during speech. It is
+// slow enough to leave an utterance alone, but a steady loud noise, e.g. a
+// fan switched on, stops counting as speech after a few seconds.
+const speechFloorRate = 0.002
+
+// Energy is a baseline detector using frame energy against an adaptive noise
+// floor, with the zero-crossing rate to reject broadband noise
+type Energy struct {
+	threshold  float64
+	noiseFloor float64
+}
+
+// NewEnergy creates an energy detector. The RMS threshold defaults to 300.
+func NewEnergy(sampleRate int, threshold float64) *Energy {
+	if threshold <= 0 {
+		threshold = 300
+	}
+
+	return &Energy{
+		threshold:  threshold,
+		noiseFloor: threshold / 3,
+	}
+}
+
+// IsSpeech returns whether the frame is louder than the threshold and the noise floor
+func (e *Energy) IsSpeech(frame []byte) bool {
+	x := samples(frame)
+	if len(x) == 0 {
+		return false
+	}
+
+	var sum float64
+	crossings := 0
+	for i, sample := range x {
+		sum += sample * sample
+		if i > 0 && (sample >= 0) != (x[i-1] >= 0) {
+			crossings++
+		}
+	}
+	rms := math.Sqrt(sum / float64(len(x)))
+	zcr := float64(crossings) / float64(len(x))
+
+	speech := rms >= e.threshold && rms >= 3*e.noiseFloor
+	// Quiet frames that cross zero this often are hiss, not voiced speech
+	if speech && rms < 2*e.threshold && zcr > 0.35 {
+		speech = false
+	}
+
+	if speech {
+		e.noiseFloor += speechFloorRate * (rms - e.noiseFloor)
+	} else {
+		e.noiseFloor = 0.95*e.noiseFloor + 0.05*rms
+	}
+	return speech
+}
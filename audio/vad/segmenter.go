@@ -0,0 +1,184 @@
+package vad
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SegmenterConfig controls how speech frames are grouped into utterances
+type SegmenterConfig struct {
+	PreRoll      time.Duration // audio kept from before speech starts
+	Silence      time.Duration // silence that ends an utterance
+	MinSpeech    time.Duration // shorter utterances are discarded
+	MaxUtterance time.Duration // utterances are cut at this length
+}
+
+// Segmenter splits a PCM16 stream into complete utterances
+type Segmenter struct {
+	detector   Detector
+	sampleRate int
+	channels   int
+	config     SegmenterConfig
+	utterances chan []byte
+
+	pending   []byte   // input not yet split into detector frames
+	preRoll   [][]byte // recent non-speech frames
+	utterance []byte
+	speaking  bool
+	speech    time.Duration
+	silence   time.Duration
+}
+
+// NewSegmenter creates a segmenter, filling in defaults for unset durations
+func NewSegmenter(detector Detector, sampleRate, channels int, config SegmenterConfig) *Segmenter {
+	if config.PreRoll <= 0 {
+		config.PreRoll = 300 * time.Millisecond
+	}
+	if config.Silence <= 0 {
+		config.Silence = 700 * time.Millisecond
+	}
+	if config.MinSpeech <= 0 {
+		config.MinSpeech = 250 * time.Millisecond
+	}
+	if config.MaxUtterance <= 0 {
+		config.MaxUtterance = 15 * time.Second
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+
+	return &Segmenter{
+		detector:   detector,
+		sampleRate: sampleRate,
+		channels:   channels,
+		config:     config,
+		utterances: make(chan []byte, 10),
+	}
+}
+
+// Utterances returns the channel of complete utterances
+func (s *Segmenter) Utterances() <-chan []byte {
+	return s.utterances
+}
+
+// Run reads frames until the channel closes or the context is cancelled. A
+// gap in the input as long as the silence timeout also ends an utterance, so
+// gated audio doesn't leave one hanging. The utterance channel is closed on return.
+func (s *Segmenter) Run(ctx context.Context, frames <-chan []byte) {
+	defer close(s.utterances)
+
+	idle := time.NewTimer(s.config.Silence)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-idle.C:
+			s.Flush()
+			idle.Reset(s.config.Silence)
+		case frame, ok := <-frames:
+			if !ok {
+				s.Flush()
+				return
+			}
+			s.Write(frame)
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(s.config.Silence)
+		}
+	}
+}
+
+// Write feeds PCM16 audio of any length to the segmenter
+func (s *Segmenter) Write(pcm []byte) {
+	frameBytes := s.frameBytes()
+	s.pending = append(s.pending, pcm...)
+
+	for len(s.pending) >= frameBytes {
+		frame := make([]byte, frameBytes)
+		copy(frame, s.pending)
+		s.pending = s.pending[frameBytes:]
+		s.process(frame)
+	}
+}
+
+// process handles one detector frame
+func (s *Segmenter) process(frame []byte) {
+	speech := s.detector.IsSpeech(mono(frame, s.channels))
+
+	if !s.speaking {
+		if !speech {
+			s.preRoll = append(s.preRoll, frame)
+			if len(s.preRoll) > s.preRollFrames() {
+				s.preRoll = s.preRoll[1:]
+			}
+			return
+		}
+
+		s.speaking = true
+		for _, f := range s.preRoll {
+			s.utterance = append(s.utterance, f...)
+		}
+		s.preRoll = nil
+	}
+
+	s.utterance = append(s.utterance, frame...)
+	if speech {
+		s.speech += FrameDuration
+		s.silence = 0
+	} else {
+		s.silence += FrameDuration
+	}
+
+	if s.silence >= s.config.Silence || s.duration() >= s.config.MaxUtterance {
+		s.Flush()
+	}
+}
+
+// Flush ends the current utterance, emitting it if it contains enough speech
+func (s *Segmenter) Flush() {
+	utterance, speech := s.utterance, s.speech
+	s.utterance = nil
+	s.speaking = false
+	s.speech = 0
+	s.silence = 0
+
+	// The end of this utterance is the pre-roll of the next
+	frameBytes := s.frameBytes()
+	for start := len(utterance) - frameBytes; start >= 0 && len(s.preRoll) < s.preRollFrames(); start -= frameBytes {
+		frame := append([]byte(nil), utterance[start:start+frameBytes]...)
+		s.preRoll = append([][]byte{frame}, s.preRoll...)
+	}
+
+	if speech < s.config.MinSpeech {
+		return
+	}
+
+	select {
+	case s.utterances <- utterance:
+	default:
+		log.Println("Warning: Utterance channel is full, dropping utterance")
+	}
+}
+
+// frameBytes returns the size of one detector frame
+func (s *Segmenter) frameBytes() int {
+	return s.sampleRate * int(FrameDuration.Milliseconds()) / 1000 * 2 * s.channels
+}
+
+// preRollFrames returns the number of frames kept before speech starts
+func (s *Segmenter) preRollFrames() int {
+	return int(s.config.PreRoll / FrameDuration)
+}
+
+// duration returns the length of the current utterance
+func (s *Segmenter) duration() time.Duration {
+	samples := len(s.utterance) / 2 / s.channels
+	return time.Duration(samples) * time.Second / time.Duration(s.sampleRate)
+}
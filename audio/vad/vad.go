@@ -0,0 +1,62 @@
+// Package vad detects speech in PCM16 audio and segments a stream into utterances.
+package vad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// FrameDuration is the length of the frames passed to a Detector
+const FrameDuration = 20 * time.Millisecond
+
+// Detector classifies mono PCM16 frames of FrameDuration as speech or non-speech
+type Detector interface {
+	IsSpeech(frame []byte) bool
+}
+
+// Config holds voice activity detection configuration
+type Config struct {
+	Engine          string  // energy or adaptive
+	Mode            int     // adaptive aggressiveness, 0 (least) to 3 (most)
+	EnergyThreshold float64 // energy RMS threshold
+}
+
+// New creates a detector for the configured engine
+func New(config Config, sampleRate int) (Detector, error) {
+	switch config.Engine {
+	case "energy":
+		return NewEnergy(sampleRate, config.EnergyThreshold), nil
+	case "adaptive", "webrtc": // webrtc is the engine's former name
+		return NewAdaptive(sampleRate, config.Mode)
+	default:
+		return nil, fmt.Errorf("unknown VAD engine: %s", config.Engine)
+	}
+}
+
+// samples converts PCM16 bytes to float samples
+func samples(frame []byte) []float64 {
+	out := make([]float64, len(frame)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+	}
+	return out
+}
+
+// mono averages interleaved PCM16 channels into one
+func mono(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+
+	frames := len(pcm) / 2 / channels
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		sum := 0
+		for c := 0; c < channels; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/channels)))
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package vad
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+const sampleRate = 16000
+
+// tone returns a 20ms frame of a sine tone with harmonics, like voiced speech
+func tone(amplitude float64, offset int) []byte {
+	n := sampleRate * int(FrameDuration.Milliseconds()) / 1000
+	frame := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		t := float64(offset*n+i) / sampleRate
+		sample := amplitude * (0.6*math.Sin(2*math.Pi*200*t) + 0.3*math.Sin(2*math.Pi*600*t) + 0.1*math.Sin(2*math.Pi*1400*t))
+		binary.LittleEndian.PutUint16(frame[i*2:], uint16(int16(sample)))
+	}
+	return frame
+}
+
+// noise returns a 20ms frame of white noise
+func noise(amplitude float64, rng *rand.Rand) []byte {
+	n := sampleRate * int(FrameDuration.Milliseconds()) / 1000
+	frame := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(frame[i*2:], uint16(int16(amplitude*(2*rng.Float64()-1))))
+	}
+	return frame
+}
+
+func TestDetectors(t *testing.T) {
+	for _, config := range []Config{{Engine: "energy"}, {Engine: "adaptive", Mode: 0}, {Engine: "adaptive", Mode: 3}, {Engine: "webrtc", Mode: 2}} {
+		detector, err := New(config, sampleRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		falsePositives := 0
+		for i := 0; i < 50; i++ {
+			if detector.IsSpeech(noise(40, rng)) {
+				falsePositives++
+			}
+		}
+
+		detected := 0
+		for i := 0; i < 25; i++ {
+			if detector.IsSpeech(tone(6000, i)) {
+				detected++
+			}
+		}
+
+		if falsePositives > 2 {
+			t.Errorf("%+v: %d/50 background frames detected as speech", config, falsePositives)
+		}
+		if detected < 20 {
+			t.Errorf("%+v: only %d/25 speech frames detected", config, detected)
+		}
+	}
+}
+
+func TestAdaptiveValidation(t *testing.T) {
+	if _, err := NewAdaptive(44100, 0); err == nil {
+		t.Error("Expected error for unsupported sample rate")
+	}
+	if _, err := NewAdaptive(16000, 4); err == nil {
+		t.Error("Expected error for invalid mode")
+	}
+	if _, err := New(Config{Engine: "silero"}, sampleRate); err == nil {
+		t.Error("Expected error for unknown engine")
+	}
+}
+
+func TestEnergyAdaptsToSteadyNoise(t *testing.T) {
+	detector := NewEnergy(sampleRate, 0)
+
+	// A loud steady tone, like a fan switched on, starts as speech...
+	if !detector.IsSpeech(tone(6000, 0)) {
+		t.Fatal("Expected the first loud frame to be speech")
+	}
+
+	// ...but the noise floor catches up within 10 seconds
+	for i := 1; i < 500; i++ {
+		if !detector.IsSpeech(tone(6000, i)) {
+			if i < 100 {
+				t.Errorf("Speech rejected after only %d frames", i)
+			}
+			return
+		}
+	}
+	t.Error("Steady noise still detected as speech after 10 seconds")
+}
+
+// levelDetector treats any non-silent frame as speech
+type levelDetector struct{}
+
+func (levelDetector) IsSpeech(frame []byte) bool {
+	for _, b := range frame {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSegmenter(t *testing.T) {
+	segmenter := NewSegmenter(levelDetector{}, sampleRate, 1, SegmenterConfig{
+		PreRoll:   60 * time.Millisecond,
+		Silence:   100 * time.Millisecond,
+		MinSpeech: 100 * time.Millisecond,
+	})
+
+	silence := make([]byte, 640)
+	feed := func(frame []byte, count int) {
+		for i := 0; i < count; i++ {
+			segmenter.Write(frame)
+		}
+	}
+
+	// Leading silence, 200ms of speech, then enough silence to end it
+	feed(silence, 10)
+	feed(tone(6000, 0), 10)
+	feed(silence, 5)
+
+	// A 40ms click is too short to be an utterance
+	feed(tone(6000, 0), 2)
+	feed(silence, 5)
+
+	// Speech still in progress is emitted by Flush
+	feed(tone(6000, 0), 6)
+	segmenter.Flush()
+
+	var lengths []int
+	for len(segmenter.Utterances()) > 0 {
+		lengths = append(lengths, len(<-segmenter.Utterances())/640)
+	}
+
+	// First: 3 pre-roll + 10 speech + 5 silence frames; second: 3 pre-roll + 6 speech
+	if len(lengths) != 2 || lengths[0] != 18 || lengths[1] != 9 {
+		t.Errorf("Utterance lengths in frames = %v", lengths)
+	}
+}
+
+func TestSegmenterIdleFlush(t *testing.T) {
+	segmenter := NewSegmenter(levelDetector{}, sampleRate, 1, SegmenterConfig{
+		Silence:   50 * time.Millisecond,
+		MinSpeech: 20 * time.Millisecond,
+	})
+
+	frames := make(chan []byte, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go segmenter.Run(ctx, frames)
+
+	// Input stops mid-utterance, e.g. when the wake word window closes
+	for i := 0; i < 5; i++ {
+		frames <- tone(6000, i)
+	}
+
+	select {
+	case utterance := <-segmenter.Utterances():
+		if len(utterance) != 5*640 {
+			t.Errorf("Utterance length = %d", len(utterance))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected utterance after input went idle")
+	}
+}
+
+func TestMono(t *testing.T) {
+	stereo := make([]byte, 8)
+	binary.LittleEndian.PutUint16(stereo[0:], uint16(1000))
+	binary.LittleEndian.PutUint16(stereo[2:], uint16(3000))
+	binary.LittleEndian.PutUint16(stereo[4:], uint16(0xFFFF)) // -1
+	binary.LittleEndian.PutUint16(stereo[6:], uint16(0xFFFD)) // -3
+
+	out := mono(stereo, 2)
+	if int16(binary.LittleEndian.Uint16(out[0:])) != 2000 || int16(binary.LittleEndian.Uint16(out[2:])) != -2 {
+		t.Errorf("Mono = %v", out)
+	}
+}
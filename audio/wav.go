@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WAVInfo describes the format of PCM data in a WAV file
+type WAVInfo struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	AudioFormat   int // 1 = PCM, 3 = IEEE float
+}
+
+// WriteWAV writes PCM data with a RIFF/WAVE header
+func WriteWAV(w io.Writer, pcm []byte, info WAVInfo) error {
+	audioFormat := info.AudioFormat
+	if audioFormat == 0 {
+		audioFormat = 1
+	}
+
+	blockAlign := info.Channels * info.BitsPerSample / 8
+	byteRate := info.SampleRate * blockAlign
+
+	header := new(bytes.Buffer)
+	header.WriteString("RIFF")
+	binary.Write(header, binary.LittleEndian, uint32(36+len(pcm)))
+	header.WriteString("WAVE")
+
+	header.WriteString("fmt ")
+	binary.Write(header, binary.LittleEndian, uint32(16))
+	binary.Write(header, binary.LittleEndian, uint16(audioFormat))
+	binary.Write(header, binary.LittleEndian, uint16(info.Channels))
+	binary.Write(header, binary.LittleEndian, uint32(info.SampleRate))
+	binary.Write(header, binary.LittleEndian, uint32(byteRate))
+	binary.Write(header, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(header, binary.LittleEndian, uint16(info.BitsPerSample))
+
+	header.WriteString("data")
+	binary.Write(header, binary.LittleEndian, uint32(len(pcm)))
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return fmt.Errorf("failed to write WAV header: %w", err)
+	}
+	if _, err := w.Write(pcm); err != nil {
+		return fmt.Errorf("failed to write WAV data: %w", err)
+	}
+
+	return nil
+}
+
+// WriteWAVFile writes PCM data to a WAV file
+func WriteWAVFile(filename string, pcm []byte, info WAVInfo) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteWAV(f, pcm, info); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadWAV reads a WAV stream and returns its format and PCM data
+func ReadWAV(r io.Reader) (WAVInfo, []byte, error) {
+	var info WAVInfo
+
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return info, nil, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return info, nil, fmt.Errorf("not a WAV file")
+	}
+
+	haveFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return info, nil, fmt.Errorf("missing data chunk: %w", err)
+		}
+
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return info, nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			if size < 16 {
+				return info, nil, fmt.Errorf("invalid fmt chunk")
+			}
+			info.AudioFormat = int(binary.LittleEndian.Uint16(data[0:2]))
+			info.Channels = int(binary.LittleEndian.Uint16(data[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+			info.BitsPerSample = int(binary.LittleEndian.Uint16(data[14:16]))
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return info, nil, fmt.Errorf("data chunk before fmt chunk")
+			}
+			// Streams written before their length was known use 0 or 0xffffffff
+			if size == 0 || size == 0xffffffff {
+				pcm, err := io.ReadAll(r)
+				return info, pcm, err
+			}
+			pcm := make([]byte, size)
+			n, err := io.ReadFull(r, pcm)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return info, nil, fmt.Errorf("failed to read data chunk: %w", err)
+			}
+			return info, pcm[:n], nil
+
+		default:
+			// Skip unknown chunks (LIST, fact, ...), padded to an even size
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return info, nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// ReadWAVFile reads a WAV file
+func ReadWAVFile(filename string) (WAVInfo, []byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return WAVInfo{}, nil, err
+	}
+	defer f.Close()
+
+	return ReadWAV(f)
+}
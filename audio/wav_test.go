@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	pcm := []byte{0x01, 0x00, 0xff, 0x7f, 0x00, 0x80, 0x10, 0x20}
+	info := WAVInfo{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, pcm, info); err != nil {
+		t.Fatalf("WriteWAV() error = %v", err)
+	}
+
+	if buf.Len() != 44+len(pcm) {
+		t.Errorf("Expected %d bytes, got %d", 44+len(pcm), buf.Len())
+	}
+
+	gotInfo, gotPCM, err := ReadWAV(&buf)
+	if err != nil {
+		t.Fatalf("ReadWAV() error = %v", err)
+	}
+
+	if gotInfo.SampleRate != 16000 || gotInfo.Channels != 1 || gotInfo.BitsPerSample != 16 || gotInfo.AudioFormat != 1 {
+		t.Errorf("Unexpected format: %+v", gotInfo)
+	}
+	if !bytes.Equal(gotPCM, pcm) {
+		t.Errorf("PCM = %v, want %v", gotPCM, pcm)
+	}
+}
+
+func TestReadWAVInvalid(t *testing.T) {
+	if _, _, err := ReadWAV(bytes.NewReader([]byte("not a wav file at all"))); err == nil {
+		t.Error("Expected error for invalid WAV")
+	}
+}
@@ -0,0 +1,217 @@
+package audio
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketSource accepts PCM16 audio from satellite microphones over
+// WebSocket. Each binary message is one little endian PCM16 frame; text
+// messages are ignored. Satellites authenticate with a shared token, sent
+// as ?token=... or an "Authorization: Bearer ..." header; browser requests
+// from another origin are refused. One satellite streams at a time, so
+// audio from two microphones is never interleaved in the ring.
+type WebSocketSource struct {
+	listen    string
+	token     string
+	listener  net.Listener
+	server    *http.Server
+	upgrader  websocket.Upgrader
+	ring      *Ring
+	frames    <-chan []byte
+	satellite net.Conn // the connected satellite, closed by Stop
+	busy      bool     // a satellite holds the connection slot
+	isRunning bool
+	gainControl
+	wg sync.WaitGroup
+	mu sync.Mutex
+}
+
+// NewWebSocketSource creates a WebSocket source serving /audio on the listen
+// address, 127.0.0.1:8765 by default; listen on e.g. ":8765" to accept
+// satellites on the network. Messages are regrouped into frames of frameSize bytes.
+func NewWebSocketSource(listen, token string, frameSize int) *WebSocketSource {
+	if listen == "" {
+		listen = "127.0.0.1:8765"
+	}
+
+	source := &WebSocketSource{
+		listen: listen,
+		token:  token,
+		ring:   NewRing("websocket", frameSize, 100),
+	}
+	source.frames = source.ring.Frames(context.Background())
+	source.SetGain(1)
+
+	return source
+}
+
+// Start begins accepting connections
+func (s *WebSocketSource) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("audio source is already running")
+	}
+	if s.token == "" {
+		return fmt.Errorf("a token is required for WebSocket audio")
+	}
+
+	listener, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for WebSocket audio: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/audio", s.handle)
+
+	s.listener = listener
+	s.server = &http.Server{Handler: mux}
+	s.isRunning = true
+	go s.server.Serve(listener)
+
+	log.Printf("Listening for WebSocket audio on ws://%s/audio", listener.Addr())
+	return nil
+}
+
+// Addr returns the listening address, once started
+func (s *WebSocketSource) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// handle reads frames from one satellite
+func (s *WebSocketSource) handle(w http.ResponseWriter, r *http.Request) {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	if !s.authorized(r) {
+		log.Printf("Warning: Rejected audio satellite %s: invalid token", r.RemoteAddr)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.claim() {
+		log.Printf("Warning: Rejected audio satellite %s: another satellite is connected", r.RemoteAddr)
+		http.Error(w, "another satellite is connected", http.StatusConflict)
+		return
+	}
+	defer s.release()
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Warning: WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	if !s.track(conn.UnderlyingConn()) {
+		return
+	}
+
+	log.Printf("Audio satellite connected: %s", r.RemoteAddr)
+	defer log.Printf("Audio satellite disconnected: %s", r.RemoteAddr)
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		data = data[:len(data)-len(data)%2]
+		applyGain(data, s.Gain())
+
+		s.ring.Write(data)
+	}
+}
+
+// claim takes the connection slot for a satellite
+func (s *WebSocketSource) claim() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning || s.busy {
+		return false
+	}
+	s.busy = true
+	return true
+}
+
+// track records the satellite's connection so Stop can close it. It
+// returns false if the source stopped during the upgrade.
+func (s *WebSocketSource) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return false
+	}
+	s.satellite = conn
+	return true
+}
+
+// release frees the connection slot
+func (s *WebSocketSource) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.satellite = nil
+	s.busy = false
+}
+
+// authorized checks the token sent by a satellite
+func (s *WebSocketSource) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// Stop stops accepting audio and disconnects satellites
+func (s *WebSocketSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return nil
+	}
+
+	s.isRunning = false
+	// Hijacked connections aren't closed by the server
+	if s.satellite != nil {
+		s.satellite.Close()
+	}
+	return s.server.Close()
+}
+
+// Close stops the server and closes the audio channel
+func (s *WebSocketSource) Close() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	s.wg.Wait()
+	s.ring.Close()
+	return nil
+}
+
+// GetAudioChannel returns the channel for receiving audio data
+func (s *WebSocketSource) GetAudioChannel() <-chan []byte {
+	return s.frames
+}
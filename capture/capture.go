@@ -0,0 +1,239 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+	"github.com/truong-nautilus/smart-home-ai/core"
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+// idFormat names captures by time, so they sort chronologically
+const idFormat = "20060102-150405.000"
+
+// Config holds utterance capture configuration
+type Config struct {
+	Dir      string
+	MaxFiles int           // keep at most this many utterances (0: unlimited)
+	MaxAge   time.Duration // delete utterances older than this (0: forever)
+}
+
+// Record describes one captured utterance. It is stored as a JSON sidecar
+// next to the utterance's WAV file.
+type Record struct {
+	ID         string              `json:"id"`
+	Timestamp  time.Time           `json:"timestamp"`
+	Audio      string              `json:"audio"`
+	SampleRate int                 `json:"sample_rate"`
+	Channels   int                 `json:"channels"`
+	DurationMs int64               `json:"duration_ms"`
+	Transcript string              `json:"transcript"`
+	Language   string              `json:"language,omitempty"`
+	Response   string              `json:"response,omitempty"`
+	Commands   []*core.Command     `json:"commands,omitempty"`
+	Results    []*core.BatchResult `json:"results,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// Store saves utterances and their outcome to a directory
+type Store struct {
+	config Config
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewStore creates a store, creating its directory if needed
+func NewStore(config Config) (*Store, error) {
+	if config.Dir == "" {
+		config.Dir = "captures"
+	}
+
+	// Recordings are private: only the user running Jarvis can read them
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	return &Store{config: config, now: time.Now}, nil
+}
+
+// Dir returns the capture directory
+func (s *Store) Dir() string {
+	return s.config.Dir
+}
+
+// Save writes an utterance and its transcript, then applies the retention limits
+func (s *Store) Save(transcript *stt.Transcript, format stt.Format) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timestamp := s.now()
+	id := timestamp.Format(idFormat)
+	for n := 2; s.exists(id); n++ {
+		id = fmt.Sprintf("%s-%d", timestamp.Format(idFormat), n)
+	}
+
+	record := &Record{
+		ID:         id,
+		Timestamp:  timestamp,
+		Audio:      id + ".wav",
+		SampleRate: format.SampleRate,
+		Channels:   format.Channels,
+		DurationMs: format.Duration(transcript.Audio).Milliseconds(),
+		Transcript: transcript.Text,
+		Language:   transcript.Language,
+	}
+
+	if err := s.writeWAV(record, transcript.Audio); err != nil {
+		return nil, fmt.Errorf("failed to save utterance: %w", err)
+	}
+	if err := s.write(record); err != nil {
+		return nil, err
+	}
+
+	s.prune(timestamp)
+	return record, nil
+}
+
+// Complete records the assistant's response and the commands it ran
+func (s *Store) Complete(record *Record, response string, results []*core.BatchResult, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Response = response
+	record.Results = results
+	record.Commands = nil
+	for _, batch := range results {
+		for _, result := range batch.Results {
+			record.Commands = append(record.Commands, result.Command)
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if !s.exists(record.ID) {
+		// Pruned while waiting for the response
+		return nil
+	}
+	return s.write(record)
+}
+
+// Load reads a record by ID, or by the path of its WAV or JSON file
+func (s *Store) Load(ref string) (*Record, error) {
+	path := filepath.Join(s.config.Dir, ref+".json")
+	if strings.HasSuffix(ref, ".wav") || strings.HasSuffix(ref, ".json") {
+		path = strings.TrimSuffix(strings.TrimSuffix(ref, ".wav"), ".json") + ".json"
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	record.Audio = filepath.Join(filepath.Dir(path), record.Audio)
+
+	return &record, nil
+}
+
+// List returns the IDs of stored utterances, oldest first
+func (s *Store) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids()
+}
+
+// exists reports whether a record with the ID is stored
+func (s *Store) exists(id string) bool {
+	_, err := os.Stat(filepath.Join(s.config.Dir, id+".json"))
+	return err == nil
+}
+
+// write saves the record's JSON sidecar
+func (s *Store) write(record *Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode capture: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.config.Dir, record.ID+".json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to save capture: %w", err)
+	}
+	return nil
+}
+
+// writeWAV saves the record's audio, readable only by the owner
+func (s *Store) writeWAV(record *Record, pcm []byte) error {
+	f, err := os.OpenFile(filepath.Join(s.config.Dir, record.Audio), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	info := audio.WAVInfo{SampleRate: record.SampleRate, Channels: record.Channels, BitsPerSample: 16}
+	if err := audio.WriteWAV(f, pcm, info); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ids lists stored IDs in chronological order
+func (s *Store) ids() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.config.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune deletes the oldest utterances beyond MaxFiles or MaxAge
+func (s *Store) prune(now time.Time) {
+	ids, err := s.ids()
+	if err != nil {
+		log.Printf("Warning: Failed to list captures: %v", err)
+		return
+	}
+
+	remove := 0
+	if s.config.MaxFiles > 0 && len(ids) > s.config.MaxFiles {
+		remove = len(ids) - s.config.MaxFiles
+	}
+	if s.config.MaxAge > 0 {
+		cutoff := now.Add(-s.config.MaxAge)
+		for remove < len(ids) {
+			id := ids[remove]
+			if len(id) < len(idFormat) {
+				break
+			}
+			timestamp, err := time.ParseInLocation(idFormat, id[:len(idFormat)], time.Local)
+			if err != nil || !timestamp.Before(cutoff) {
+				break
+			}
+			remove++
+		}
+	}
+
+	for _, id := range ids[:remove] {
+		for _, ext := range []string{".wav", ".json"} {
+			if err := os.Remove(filepath.Join(s.config.Dir, id+ext)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Warning: Failed to delete capture %s: %v", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package capture
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+	"github.com/truong-nautilus/smart-home-ai/core"
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+// clock returns a fake time source advancing by step on each call
+func clock(start time.Time, step time.Duration) func() time.Time {
+	now := start.Add(-step)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func TestSaveAndComplete(t *testing.T) {
+	store, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	pcm := make([]byte, format.Bytes(500*time.Millisecond))
+	record, err := store.Save(&stt.Transcript{Text: "bật đèn phòng khách", Language: "vi", Audio: pcm}, format)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if record.DurationMs != 500 {
+		t.Errorf("DurationMs = %d, want 500", record.DurationMs)
+	}
+
+	command := &core.Command{Action: "turn_on", Device: "living_room_light"}
+	results := []*core.BatchResult{{Results: []core.CommandResult{{Command: command, Success: true}}, Success: true}}
+	if err := store.Complete(record, "Đã bật đèn.", results, nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	loaded, err := store.Load(record.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Transcript != "bật đèn phòng khách" || loaded.Response != "Đã bật đèn." {
+		t.Errorf("Loaded record = %+v", loaded)
+	}
+	if len(loaded.Commands) != 1 || loaded.Commands[0].Device != "living_room_light" {
+		t.Errorf("Commands = %+v", loaded.Commands)
+	}
+	if len(loaded.Results) != 1 || !loaded.Results[0].Success {
+		t.Errorf("Results = %+v", loaded.Results)
+	}
+
+	info, saved, err := audio.ReadWAVFile(loaded.Audio)
+	if err != nil {
+		t.Fatalf("ReadWAVFile() error = %v", err)
+	}
+	if info.SampleRate != 16000 || len(saved) != len(pcm) {
+		t.Errorf("Saved audio: %+v, %d bytes", info, len(saved))
+	}
+
+	// The WAV path works as a reference too
+	if _, err := store.Load(loaded.Audio); err != nil {
+		t.Errorf("Load(wav) error = %v", err)
+	}
+}
+
+func TestCapturesArePrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "captures")
+	store, err := NewStore(Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	record, err := store.Save(&stt.Transcript{Text: "bật đèn", Audio: make([]byte, 320)}, format)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	want := map[string]os.FileMode{
+		dir:                                   0o700,
+		filepath.Join(dir, record.Audio):      0o600,
+		filepath.Join(dir, record.ID+".json"): 0o600,
+	}
+	for path, mode := range want {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s mode = %o, want %o", path, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestCompleteRecordsError(t *testing.T) {
+	store, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	record, err := store.Save(&stt.Transcript{Text: "tắt quạt"}, format)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Complete(record, "", nil, errors.New("API timeout")); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	loaded, err := store.Load(record.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Error != "API timeout" {
+		t.Errorf("Error = %q", loaded.Error)
+	}
+}
+
+func TestRetentionMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(Config{Dir: dir, MaxFiles: 3})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	store.now = clock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local), time.Second)
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	var records []*Record
+	for i := 0; i < 5; i++ {
+		record, err := store.Save(&stt.Transcript{Text: "xin chào"}, format)
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		records = append(records, record)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(ids) != 3 || ids[0] != records[2].ID {
+		t.Errorf("Kept %v, want the last 3 of %d", ids, len(records))
+	}
+	if _, err := os.Stat(filepath.Join(dir, records[0].ID+".wav")); !os.IsNotExist(err) {
+		t.Errorf("Oldest WAV file was not deleted")
+	}
+}
+
+func TestRetentionMaxAge(t *testing.T) {
+	store, err := NewStore(Config{Dir: t.TempDir(), MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	store.now = clock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local), 10*time.Hour)
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	for i := 0; i < 4; i++ {
+		if _, err := store.Save(&stt.Transcript{Text: "xin chào"}, format); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	// Saved at 0h, 10h, 20h and 30h; the first is more than a day old
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(ids) != 3 {
+		t.Errorf("Kept %v, want 3", ids)
+	}
+}
+
+func TestSaveUniqueIDs(t *testing.T) {
+	store, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	store.now = clock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local), 0)
+
+	format := stt.Format{SampleRate: 16000, Channels: 1}
+	first, _ := store.Save(&stt.Transcript{Text: "một"}, format)
+	second, _ := store.Save(&stt.Transcript{Text: "hai"}, format)
+	if first == nil || second == nil || first.ID == second.ID {
+		t.Fatalf("Expected distinct IDs, got %v and %v", first, second)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/truong-nautilus/smart-home-ai/claude"
+)
+
+// runChat reads typed requests in a loop and answers them like spoken ones,
+// without audio. "/reset" forgets the conversation and "/quit" exits.
+func runChat() {
+	config := loadConfig()
+	router := initRouter(config)
+	defer router.Close()
+	jarvis := newAssistant(config, newSecurityManager(config), router)
+
+	fmt.Println("Jarvis chat. Type a request, /reset to start over, /quit to exit.")
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		text := strings.TrimSpace(scanner.Text())
+		switch text {
+		case "":
+			continue
+		case "/quit", "/exit":
+			return
+		case "/reset":
+			if jarvis.claude != nil {
+				jarvis.claude.ResetSession()
+			}
+			fmt.Println("Conversation reset.")
+			continue
+		}
+
+		reply, err := jarvis.ask(text)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		printReply(reply)
+	}
+}
+
+// runDo answers a single request given as arguments, e.g.
+// jarvis do "tắt đèn bếp". It exits with status 1 if the request fails or
+// any command doesn't run.
+func runDo(args []string) {
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		log.Fatal("Usage: jarvis do <request>")
+	}
+
+	config := loadConfig()
+	router := initRouter(config)
+	jarvis := newAssistant(config, newSecurityManager(config), router)
+
+	reply, err := jarvis.ask(text)
+	router.Close()
+	if err != nil {
+		log.Fatalf("Request failed: %v", err)
+	}
+	printReply(reply)
+
+	for _, result := range reply.Results {
+		if !result.Success {
+			os.Exit(1)
+		}
+	}
+}
+
+// printReply prints the reply text and the outcome of each command batch
+func printReply(reply *claude.Reply) {
+	if reply.Text != "" {
+		fmt.Printf("Jarvis: %s\n", reply.Text)
+	}
+	for _, result := range reply.Results {
+		if len(result.Results) > 0 {
+			fmt.Printf("Result: %s\n", result.Summary())
+		}
+	}
+}
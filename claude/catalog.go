@@ -0,0 +1,131 @@
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// BuildTools generates one tool per allowed action, restricting the device
+// parameter to the IDs that support the action and the value to the range
+// accepted by the security manager
+func BuildTools(catalog []core.CatalogEntry, security *core.SecurityManager) []Tool {
+	deviceIDs := make(map[string][]string)
+	deviceNames := make(map[string][]string)
+	temps := make(map[int]bool)
+
+	tempRange, limited := security.ValueRange("ac.set_temp")
+
+	for _, entry := range catalog {
+		// Each AC only has IR codes for some temperatures, so they are listed per device
+		var allowed []string
+		for _, temp := range entry.Temperatures {
+			if !limited || (float64(temp) >= tempRange.Min && float64(temp) <= tempRange.Max) {
+				temps[temp] = true
+				allowed = append(allowed, fmt.Sprint(temp))
+			}
+		}
+
+		for _, action := range entry.Actions {
+			name := fmt.Sprintf("%s (%s)", entry.ID, entry.Name)
+			if action == "ac.set_temp" {
+				name = fmt.Sprintf("%s (%s: %s°C)", entry.ID, entry.Name, strings.Join(allowed, ", "))
+			}
+			deviceIDs[action] = append(deviceIDs[action], entry.ID)
+			deviceNames[action] = append(deviceNames[action], name)
+		}
+	}
+
+	actions := make([]string, 0, len(deviceIDs))
+	for action := range deviceIDs {
+		if _, ok := actionSpecs[action]; ok && security.IsCommandAllowed(action) {
+			actions = append(actions, action)
+		}
+	}
+	sort.Strings(actions)
+
+	tools := make([]Tool, 0, len(actions))
+	for _, action := range actions {
+		spec := actionSpecs[action]
+		value := valueSchema(action, spec.value, security, temps)
+		description := fmt.Sprintf("%s. Devices: %s", spec.description, strings.Join(deviceNames[action], ", "))
+		tools = append(tools, newTool(action, description, deviceIDs[action], value))
+	}
+
+	return tools
+}
+
+// valueSchema narrows an action's value schema to the accepted range
+func valueSchema(action string, base map[string]interface{}, security *core.SecurityManager, temps map[int]bool) map[string]interface{} {
+	if base == nil {
+		return nil
+	}
+
+	schema := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		schema[key] = value
+	}
+
+	r, ok := security.ValueRange(action)
+	if ok {
+		schema["minimum"] = r.Min
+		schema["maximum"] = r.Max
+	}
+
+	// Only temperatures with a learned IR code can be set. The enum covers
+	// every AC; toolCommand checks the temperature against the chosen one.
+	if action == "ac.set_temp" {
+		enum := make([]int, 0, len(temps))
+		for temp := range temps {
+			enum = append(enum, temp)
+		}
+		sort.Ints(enum)
+		schema["enum"] = enum
+	}
+
+	return schema
+}
+
+// CatalogPrompt describes the configured devices for the system prompt
+func CatalogPrompt(catalog []core.CatalogEntry) string {
+	var sb strings.Builder
+	sb.WriteString("Danh sách thiết bị (chỉ dùng đúng device ID dưới đây, gọi tool tương ứng để điều khiển):\n")
+
+	for _, entry := range catalog {
+		fmt.Fprintf(&sb, "- %s: %s [%s]", entry.ID, entry.Name, strings.Join(entry.Actions, ", "))
+		if len(entry.Temperatures) > 0 {
+			temps := make([]string, len(entry.Temperatures))
+			for i, temp := range entry.Temperatures {
+				temps[i] = fmt.Sprint(temp)
+			}
+			fmt.Fprintf(&sb, " nhiệt độ: %s°C", strings.Join(temps, ", "))
+		}
+		if len(entry.Aliases) > 0 {
+			fmt.Fprintf(&sb, " còn gọi là: %s", strings.Join(entry.Aliases, ", "))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// UpdateCatalog regenerates the tools and device list from the configuration.
+// Call it at startup and whenever the configuration is reloaded.
+func (c *Client) UpdateCatalog(config *core.Config, security *core.SecurityManager) {
+	catalog := core.DeviceCatalog(config)
+	tools := BuildTools(catalog, security)
+
+	c.SetTools(tools)
+
+	devices := make(map[string]core.CatalogEntry, len(catalog))
+	for _, entry := range catalog {
+		devices[entry.ID] = entry
+	}
+
+	c.mu.Lock()
+	c.catalog = CatalogPrompt(catalog)
+	c.devices = devices
+	c.mu.Unlock()
+}
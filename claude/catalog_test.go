@@ -0,0 +1,169 @@
+package claude
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// testConfig loads the device fixture shared with the core tests
+func testConfig(t *testing.T) *core.Config {
+	t.Helper()
+
+	config, err := core.LoadConfig(filepath.Join("..", "core", "testdata", "devices.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return config
+}
+
+// addBedroomAC adds a second AC that has an IR code for other temperatures
+func addBedroomAC(config *core.Config) {
+	config.Devices.IRDevices["dieu_hoa_phong_ngu"] = core.IRDeviceInfo{
+		Type:     "broadlink",
+		DeviceIP: "192.168.1.32",
+		Commands: map[string]string{"on": "26", "temp_24": "26"},
+		Name:     "Điều Hòa Phòng Ngủ",
+	}
+}
+
+func findTool(tools []Tool, name string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+func deviceEnum(tool Tool) []string {
+	properties := tool.InputSchema["properties"].(map[string]interface{})
+	device := properties["device"].(map[string]interface{})
+	enum, _ := device["enum"].([]string)
+	return enum
+}
+
+func TestBuildTools(t *testing.T) {
+	config := testConfig(t)
+	addBedroomAC(config)
+	// A TV with on/off codes is still a TV
+	config.Devices.IRDevices["tv"].Commands["on"] = "26"
+	config.Devices.IRDevices["tv"].Commands["off"] = "26"
+	delete(config.Devices.IRDevices["tv"].Commands, "vol_down")
+	delete(config.Devices.Switches, "quat")
+
+	tools := BuildTools(core.DeviceCatalog(config), core.NewSecurityManager())
+
+	tests := []struct {
+		tool    string
+		devices []string
+	}{
+		{"light_on", []string{"bep", "phong_khach", "phong_ngu"}},
+		{"light_color", []string{"phong_khach", "phong_ngu"}},
+		{"ac_on", []string{"dieu_hoa", "dieu_hoa_phong_ngu"}},
+		{"ac_off", []string{"dieu_hoa"}},
+		{"ac_set_temp", []string{"dieu_hoa", "dieu_hoa_phong_ngu"}},
+		{"tv_power", []string{"tv"}},
+		{"vacuum_start", []string{"robot"}},
+		{"vacuum_spot", []string{"robot"}},
+		{"vacuum_fan_speed", []string{"robot"}},
+	}
+
+	for _, tt := range tests {
+		tool, ok := findTool(tools, tt.tool)
+		if !ok {
+			t.Errorf("Missing tool %s", tt.tool)
+			continue
+		}
+		if got := deviceEnum(tool); strings.Join(got, ",") != strings.Join(tt.devices, ",") {
+			t.Errorf("%s devices = %v, want %v", tt.tool, got, tt.devices)
+		}
+	}
+
+	// Not supported by any device
+	for _, name := range []string{"switch_toggle", "tv_vol_down"} {
+		if _, ok := findTool(tools, name); ok {
+			t.Errorf("Unexpected tool %s", name)
+		}
+	}
+}
+
+func TestBuildToolsValueRange(t *testing.T) {
+	security := core.NewSecurityManager()
+	security.SetValueRange("light.brightness", core.ValueRange{Min: 10, Max: 90})
+
+	config := testConfig(t)
+	addBedroomAC(config)
+	config.Devices.IRDevices["dieu_hoa"].Commands["temp_32"] = "26"
+
+	tools := BuildTools(core.DeviceCatalog(config), security)
+
+	tool, _ := findTool(tools, "ac_set_temp")
+	value := tool.InputSchema["properties"].(map[string]interface{})["value"].(map[string]interface{})
+	enum := value["enum"].([]int)
+	if len(enum) != 3 || enum[0] != 18 || enum[1] != 24 || enum[2] != 26 {
+		t.Errorf("ac_set_temp enum = %v, want [18 24 26] (32 is outside the allowed range)", enum)
+	}
+	for _, want := range []string{"dieu_hoa (Điều Hòa Phòng Khách: 18, 26°C)", "dieu_hoa_phong_ngu (Điều Hòa Phòng Ngủ: 24°C)"} {
+		if !strings.Contains(tool.Description, want) {
+			t.Errorf("ac_set_temp description %q is missing %q", tool.Description, want)
+		}
+	}
+
+	tool, _ = findTool(tools, "light_brightness")
+	value = tool.InputSchema["properties"].(map[string]interface{})["value"].(map[string]interface{})
+	if value["minimum"] != float64(10) || value["maximum"] != float64(90) {
+		t.Errorf("light_brightness range = %v-%v, want 10-90", value["minimum"], value["maximum"])
+	}
+
+	tool, _ = findTool(tools, "vacuum_fan_speed")
+	value = tool.InputSchema["properties"].(map[string]interface{})["value"].(map[string]interface{})
+	if value["minimum"] != float64(1) || value["maximum"] != float64(100) {
+		t.Errorf("vacuum_fan_speed range = %v-%v, want 1-100", value["minimum"], value["maximum"])
+	}
+}
+
+func TestCatalogPrompt(t *testing.T) {
+	prompt := CatalogPrompt(core.DeviceCatalog(testConfig(t)))
+
+	for _, want := range []string{
+		"- phong_khach: Đèn Phòng Khách [light.on",
+		"- dieu_hoa: Điều Hòa Phòng Khách [ac.on, ac.off, ac.set_temp] nhiệt độ: 18, 26°C",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestUpdateCatalog(t *testing.T) {
+	client := NewClient(ClaudeConfig{})
+	client.UpdateCatalog(testConfig(t), core.NewSecurityManager())
+
+	if _, ok := client.toolActions["light_color_temp"]; !ok {
+		t.Error("Expected light_color_temp tool after UpdateCatalog")
+	}
+	if !strings.Contains(client.catalog, "robot") {
+		t.Error("Expected catalog prompt to list robot")
+	}
+}
+
+func TestToolCommandTemperature(t *testing.T) {
+	config := testConfig(t)
+	addBedroomAC(config)
+	devices := make(map[string]core.CatalogEntry)
+	for _, entry := range core.DeviceCatalog(config) {
+		devices[entry.ID] = entry
+	}
+
+	if _, err := toolCommand("ac.set_temp", map[string]interface{}{"device": "dieu_hoa_phong_ngu", "value": float64(24)}, devices); err != nil {
+		t.Errorf("toolCommand() error = %v", err)
+	}
+	// 26°C is learned for the living room AC only
+	_, err := toolCommand("ac.set_temp", map[string]interface{}{"device": "dieu_hoa_phong_ngu", "value": float64(26)}, devices)
+	if err == nil || !strings.Contains(err.Error(), "available: 24") {
+		t.Errorf("Expected a missing IR code error, got %v", err)
+	}
+}
@@ -1,414 +1,612 @@
 package claude
 
 import (
-	"encoding/base64"
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/truong-nautilus/smart-home-ai/core"
 )
 
+// Messages API defaults
+const (
+	DefaultAPIURL    = "https://api.anthropic.com/v1/messages"
+	anthropicVersion = "2023-06-01"
+	maxToolRounds    = 5
+)
+
 // ClaudeConfig holds Claude API configuration
 type ClaudeConfig struct {
 	APIKey       string
 	Model        string
-	WebSocketURL string
+	APIURL       string
 	SystemPrompt string
 	MaxTokens    int
 	Temperature  float64
+	Session      SessionConfig
 }
 
-// RealtimeClient handles communication with Claude Realtime API
-type RealtimeClient struct {
-	config         ClaudeConfig
-	conn           *websocket.Conn
-	audioInChan    chan []byte
-	commandOutChan chan *core.Command
-	isConnected    bool
-	mu             sync.Mutex
-	stopChan       chan struct{}
+// CommandHandler executes the commands requested by Claude
+type CommandHandler func(batch *core.Batch) *core.BatchResult
+
+// Reply is Claude's answer to a user message
+type Reply struct {
+	Text    string
+	Results []*core.BatchResult
 }
 
-// Command is imported from core package
+// Client talks to the Claude Messages API using SSE streaming and tool use
+type Client struct {
+	config      ClaudeConfig
+	httpClient  *http.Client
+	tools       []Tool
+	toolActions map[string]string
+	handler     CommandHandler
+	onText      func(delta string)
+	catalog     string
+	devices     map[string]core.CatalogEntry
+	context     string
+	session     *session
+	mu          sync.RWMutex
+}
 
-// RealtimeEvent represents events sent to/from Claude
-type RealtimeEvent struct {
-	Type  string                 `json:"type"`
-	Event map[string]interface{} `json:"event,omitempty"`
+// message is a Messages API conversation turn
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
 }
 
-// InputAudioBufferAppend event structure
-type InputAudioBufferAppendEvent struct {
-	Type  string `json:"type"`
-	Audio string `json:"audio"` // base64 encoded PCM16
+// contentBlock is a text, tool_use or tool_result content block
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
 }
 
-// NewRealtimeClient creates a new Claude Realtime client
-func NewRealtimeClient(config ClaudeConfig) *RealtimeClient {
-	return &RealtimeClient{
-		config:         config,
-		audioInChan:    make(chan []byte, 100),
-		commandOutChan: make(chan *core.Command, 10),
-		isConnected:    false,
-		stopChan:       make(chan struct{}),
-	}
+// messagesRequest is the Messages API request body
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	Tools       []Tool    `json:"tools,omitempty"`
+	Stream      bool      `json:"stream"`
 }
 
-// Connect establishes WebSocket connection to Claude API
-func (c *RealtimeClient) Connect() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// streamEvent is a server-sent event from the Messages API
+type streamEvent struct {
+	Type         string        `json:"type"`
+	Index        int           `json:"index"`
+	ContentBlock *contentBlock `json:"content_block,omitempty"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
 
-	if c.isConnected {
-		return fmt.Errorf("already connected")
+// NewClient creates a new Claude Messages API client
+func NewClient(config ClaudeConfig) *Client {
+	if config.APIURL == "" {
+		config.APIURL = DefaultAPIURL
 	}
 
-	// Note: Claude API v1 uses standard HTTPS endpoints with streaming
-	// For realtime, we'll use the messages streaming endpoint
-	url := "wss://api.anthropic.com/v1/messages"
-
-	headers := make(map[string][]string)
-	headers["anthropic-version"] = []string{"2023-06-01"}
-	headers["x-api-key"] = []string{c.config.APIKey}
-	headers["content-type"] = []string{"application/json"}
-
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = 10 * time.Second
-
-	conn, _, err := dialer.Dial(url, headers)
-	if err != nil {
-		return fmt.Errorf("failed to connect to Claude API: %w", err)
+	c := &Client{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		session: newSession(config.Session),
 	}
+	c.SetTools(DefaultTools())
 
-	c.conn = conn
-	c.isConnected = true
-
-	log.Println("Connected to Claude Realtime API")
+	return c
+}
 
-	// Send initial session configuration
-	if err := c.sendSessionConfig(); err != nil {
-		return fmt.Errorf("failed to send session config: %w", err)
+// SetTools replaces the tools offered to Claude
+func (c *Client) SetTools(tools []Tool) {
+	toolActions := make(map[string]string, len(tools))
+	for _, tool := range tools {
+		toolActions[tool.Name] = toolAction(tool)
 	}
 
-	// Start listening for responses
-	go c.listenForResponses()
-
-	// Start processing audio input
-	go c.processAudioInput()
-
-	return nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = tools
+	c.toolActions = toolActions
 }
 
-// sendSessionConfig sends initial session configuration
-func (c *RealtimeClient) sendSessionConfig() error {
-	config := map[string]interface{}{
-		"type": "session.update",
-		"session": map[string]interface{}{
-			"model":               c.config.Model,
-			"modalities":          []string{"text", "audio"},
-			"instructions":        c.config.SystemPrompt,
-			"voice":               "alloy",
-			"input_audio_format":  "pcm16",
-			"output_audio_format": "pcm16",
-			"input_audio_transcription": map[string]interface{}{
-				"enabled": true,
-				"model":   "whisper-1",
-			},
-			"turn_detection": map[string]interface{}{
-				"type":                "server_vad",
-				"threshold":           0.5,
-				"prefix_padding_ms":   300,
-				"silence_duration_ms": 500,
-			},
-			"temperature":                c.config.Temperature,
-			"max_response_output_tokens": c.config.MaxTokens,
-		},
-	}
+// SetCommandHandler sets the function that executes tool calls
+func (c *Client) SetCommandHandler(handler CommandHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
 
-	return c.sendJSON(config)
+// SetTextHandler sets a callback for streamed text deltas
+func (c *Client) SetTextHandler(onText func(delta string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onText = onText
 }
 
-// SendAudio sends audio data to Claude
-func (c *RealtimeClient) SendAudio(audioData []byte) error {
-	select {
-	case c.audioInChan <- audioData:
-		return nil
-	default:
-		return fmt.Errorf("audio input channel is full")
-	}
+// SetContext sets extra context (e.g. device availability) appended to the system prompt
+func (c *Client) SetContext(context string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.context = context
 }
 
-// processAudioInput processes audio data and sends to Claude
-func (c *RealtimeClient) processAudioInput() {
-	for {
-		select {
-		case <-c.stopChan:
-			return
-		case audioData := <-c.audioInChan:
-			if err := c.sendAudioBuffer(audioData); err != nil {
-				log.Printf("Error sending audio buffer: %v", err)
+// Send sends a user message, executes any tool calls and returns Claude's
+// final reply. Recent turns of the conversation are sent along, so
+// follow-ups like "make it dimmer" refer to the right device. If a round
+// fails after tool calls ran, the reply holding their results is returned
+// with the error, so the caller doesn't run the commands again.
+func (c *Client) Send(ctx context.Context, text string) (*Reply, error) {
+	history, summary, devices := c.session.history()
+	start := len(history)
+	messages := append(history, message{Role: "user", Content: []contentBlock{{Type: "text", Text: text}}})
+	conversation := c.conversationContext(summary, devices)
+
+	reply := &Reply{}
+	usedTools := false
+
+	for round := 0; round < maxToolRounds; round++ {
+		blocks, stopReason, err := c.stream(ctx, c.request(messages, conversation, true), c.textHandler())
+		if err != nil {
+			if !usedTools {
+				return nil, err
 			}
+			// Remember what was done, without the interrupted round
+			messages = append(messages, assistantMessage(reply))
+			c.remember(turn{messages: messages[start:], text: text, reply: reply.Text}, reply.Results)
+			return reply, err
 		}
-	}
-}
 
-// sendAudioBuffer sends audio buffer to Claude
-func (c *RealtimeClient) sendAudioBuffer(audioData []byte) error {
-	// Encode audio to base64
-	encoded := base64.StdEncoding.EncodeToString(audioData)
+		var textParts []string
+		var toolUses []contentBlock
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				textParts = append(textParts, block.Text)
+			case "tool_use":
+				toolUses = append(toolUses, block)
+			}
+		}
+		reply.Text = strings.TrimSpace(strings.Join(textParts, ""))
 
-	event := InputAudioBufferAppendEvent{
-		Type:  "input_audio_buffer.append",
-		Audio: encoded,
-	}
+		if stopReason != "tool_use" || len(toolUses) == 0 {
+			break
+		}
 
-	return c.sendJSON(event)
-}
+		usedTools = true
+		results, batchResult := c.executeToolUses(toolUses)
+		if batchResult != nil {
+			reply.Results = append(reply.Results, batchResult)
+		}
 
-// CommitAudioBuffer commits the audio buffer to trigger processing
-func (c *RealtimeClient) CommitAudioBuffer() error {
-	event := map[string]interface{}{
-		"type": "input_audio_buffer.commit",
+		messages = append(messages,
+			message{Role: "assistant", Content: nonEmptyBlocks(blocks)},
+			message{Role: "user", Content: results},
+		)
 	}
-	return c.sendJSON(event)
-}
 
-// listenForResponses listens for responses from Claude
-func (c *RealtimeClient) listenForResponses() {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in listenForResponses: %v", r)
-		}
-	}()
-
-	for {
-		select {
-		case <-c.stopChan:
-			return
-		default:
-			_, message, err := c.conn.ReadMessage()
-			if err != nil {
-				log.Printf("Error reading message: %v", err)
-				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					return
-				}
-				continue
+	// Fall back to JSON commands written as plain text
+	if !usedTools && reply.Text != "" {
+		if batch, err := core.ParseBatch(reply.Text); err == nil {
+			if result := c.handle(batch); result != nil {
+				reply.Results = append(reply.Results, result)
 			}
-
-			c.handleResponse(message)
 		}
 	}
+
+	messages = append(messages, assistantMessage(reply))
+	c.remember(turn{messages: messages[start:], text: text, reply: reply.Text}, reply.Results)
+
+	return reply, nil
 }
 
-// handleResponse processes responses from Claude
-func (c *RealtimeClient) handleResponse(message []byte) {
-	var event map[string]interface{}
-	if err := json.Unmarshal(message, &event); err != nil {
-		log.Printf("Error unmarshaling response: %v", err)
-		return
+// Remember adds a request answered without Claude, e.g. by the local intent
+// parser, to the conversation so later follow-ups can refer to it
+func (c *Client) Remember(text string, reply *Reply) {
+	messages := []message{
+		{Role: "user", Content: []contentBlock{{Type: "text", Text: text}}},
+		assistantMessage(reply),
 	}
+	c.remember(turn{messages: messages, text: text, reply: reply.Text}, reply.Results)
+}
 
-	eventType, ok := event["type"].(string)
-	if !ok {
-		log.Println("Response missing type field")
+// ResetSession forgets the conversation
+func (c *Client) ResetSession() {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	c.session.reset()
+}
+
+// remember records a turn and summarizes older turns in the background once
+// the conversation grows too long
+func (c *Client) remember(t turn, results []*core.BatchResult) {
+	old, previous, generation := c.session.add(t, results)
+	if len(old) == 0 {
 		return
 	}
 
-	log.Printf("Received event type: %s", eventType)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
 
-	switch eventType {
-	case "session.created":
-		log.Println("Session created successfully")
+		summary, err := c.summarize(ctx, old, previous)
+		if err != nil {
+			log.Printf("Warning: Failed to summarize conversation: %v", err)
+			summary = truncateSummary(strings.TrimSpace(previous + "\n" + transcript(old)))
+		}
+		c.session.summarized(generation, len(old), summary)
+	}()
+}
 
-	case "session.updated":
-		log.Println("Session updated successfully")
+// summarize asks Claude to condense older turns, without tools
+func (c *Client) summarize(ctx context.Context, turns []turn, previous string) (string, error) {
+	text := transcript(turns)
+	if previous != "" {
+		text = "Tóm tắt trước đó: " + previous + "\n\n" + text
+	}
 
-	case "input_audio_buffer.speech_started":
-		log.Println("Speech detected")
+	body := c.request([]message{{Role: "user", Content: []contentBlock{{Type: "text", Text: text}}}}, "", false)
+	body.System = "Tóm tắt ngắn gọn (tối đa 5 câu) cuộc hội thoại điều khiển nhà thông minh sau. " +
+		"Giữ lại thiết bị, khu vực, trạng thái đã đặt và các yêu cầu còn dang dở."
+	body.MaxTokens = 300
 
-	case "input_audio_buffer.speech_stopped":
-		log.Println("Speech ended")
+	blocks, _, err := c.stream(ctx, body, nil)
+	if err != nil {
+		return "", err
+	}
 
-	case "conversation.item.created":
-		log.Println("Conversation item created")
+	var sb strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(sb.String()) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return truncateSummary(strings.TrimSpace(sb.String())), nil
+}
 
-	case "response.done":
-		log.Println("Response completed")
-		c.handleResponseDone(event)
+// conversationContext describes the summary and recently used devices for the system prompt
+func (c *Client) conversationContext(summary string, devices []string) string {
+	var sb strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&sb, "Tóm tắt hội thoại trước: %s\n", summary)
+	}
 
-	case "response.text.delta":
-		c.handleTextDelta(event)
+	if len(devices) > 0 {
+		c.mu.RLock()
+		names := make([]string, len(devices))
+		var area string
+		for i, id := range devices {
+			names[i] = id
+			entry, ok := c.devices[id]
+			if !ok {
+				continue
+			}
+			if entry.Area != "" {
+				names[i] = fmt.Sprintf("%s (%s, %s)", id, entry.Name, entry.Area)
+				if area == "" {
+					area = entry.Area
+				}
+			} else {
+				names[i] = fmt.Sprintf("%s (%s)", id, entry.Name)
+			}
+		}
+		c.mu.RUnlock()
 
-	case "response.audio.delta":
-		c.handleAudioDelta(event)
+		fmt.Fprintf(&sb, "Thiết bị vừa được nhắc tới (mới nhất trước): %s.\n", strings.Join(names, ", "))
+		if area != "" {
+			fmt.Fprintf(&sb, "Khu vực gần nhất: %s.\n", area)
+		}
+		sb.WriteString("Khi người dùng nói \"nó\", \"đèn đó\", \"ở đó\" hoặc không nêu thiết bị, hãy dùng ngữ cảnh này.")
+	}
 
-	case "response.function_call_arguments.done":
-		c.handleFunctionCall(event)
+	return strings.TrimSpace(sb.String())
+}
 
-	case "error":
-		c.handleError(event)
+// assistantMessage closes a turn with the reply text, so the history alternates roles
+func assistantMessage(reply *Reply) message {
+	text := reply.Text
+	if text == "" {
+		var commands []string
+		for _, batch := range reply.Results {
+			for _, result := range batch.Results {
+				if result.Command != nil && !result.Skipped {
+					commands = append(commands, commandSummary(result))
+				}
+			}
+		}
+		text = "Đã thực hiện."
+		if len(commands) > 0 {
+			text = "Đã thực hiện: " + strings.Join(commands, "; ") + "."
+		}
 	}
+	return message{Role: "assistant", Content: []contentBlock{{Type: "text", Text: text}}}
 }
 
-// handleResponseDone handles completed responses
-func (c *RealtimeClient) handleResponseDone(event map[string]interface{}) {
-	response, ok := event["response"].(map[string]interface{})
-	if !ok {
-		return
-	}
+// executeToolUses runs the tool calls of one response as a batch and builds the tool results
+func (c *Client) executeToolUses(toolUses []contentBlock) ([]contentBlock, *core.BatchResult) {
+	c.mu.RLock()
+	toolActions, devices := c.toolActions, c.devices
+	c.mu.RUnlock()
 
-	output, ok := response["output"].([]interface{})
-	if !ok || len(output) == 0 {
-		return
-	}
+	results := make([]contentBlock, len(toolUses))
+	batch := &core.Batch{}
+	batchIndex := make([]int, 0, len(toolUses))
+
+	for i, toolUse := range toolUses {
+		results[i] = contentBlock{Type: "tool_result", ToolUseID: toolUse.ID}
 
-	for _, item := range output {
-		itemMap, ok := item.(map[string]interface{})
+		action, ok := toolActions[toolUse.Name]
 		if !ok {
+			results[i].Content = fmt.Sprintf("unknown tool: %s", toolUse.Name)
+			results[i].IsError = true
 			continue
 		}
 
-		if itemType, ok := itemMap["type"].(string); ok && itemType == "message" {
-			if content, ok := itemMap["content"].([]interface{}); ok {
-				for _, contentItem := range content {
-					contentMap, ok := contentItem.(map[string]interface{})
-					if !ok {
-						continue
-					}
-
-					if text, ok := contentMap["text"].(string); ok {
-						c.parseCommand(text)
-					}
-				}
-			}
+		var input map[string]interface{}
+		if err := json.Unmarshal(toolUse.Input, &input); err != nil {
+			results[i].Content = fmt.Sprintf("invalid input: %v", err)
+			results[i].IsError = true
+			continue
 		}
-	}
-}
 
-// handleTextDelta handles streaming text responses
-func (c *RealtimeClient) handleTextDelta(event map[string]interface{}) {
-	if delta, ok := event["delta"].(map[string]interface{}); ok {
-		if text, ok := delta["text"].(string); ok {
-			log.Printf("Claude: %s", text)
+		cmd, err := toolCommand(action, input, devices)
+		if err != nil {
+			results[i].Content = err.Error()
+			results[i].IsError = true
+			continue
 		}
-	}
-}
 
-// handleAudioDelta handles streaming audio responses
-func (c *RealtimeClient) handleAudioDelta(event map[string]interface{}) {
-	if delta, ok := event["delta"].(map[string]interface{}); ok {
-		if audioBase64, ok := delta["audio"].(string); ok {
-			// Decode and play audio (implementation depends on audio output system)
-			audioData, err := base64.StdEncoding.DecodeString(audioBase64)
-			if err != nil {
-				log.Printf("Error decoding audio: %v", err)
-				return
-			}
-			log.Printf("Received audio chunk: %d bytes", len(audioData))
-			// TODO: Play audio through speakers
-		}
+		log.Printf("Tool call: %s -> action=%s, device=%s, value=%v", toolUse.Name, cmd.Action, cmd.Device, cmd.Value)
+		batch.Commands = append(batch.Commands, cmd)
+		batchIndex = append(batchIndex, i)
 	}
-}
 
-// handleFunctionCall handles function call responses
-func (c *RealtimeClient) handleFunctionCall(event map[string]interface{}) {
-	if args, ok := event["arguments"].(string); ok {
-		c.parseCommand(args)
+	if len(batch.Commands) == 0 {
+		return results, nil
 	}
-}
 
-// handleError handles error events
-func (c *RealtimeClient) handleError(event map[string]interface{}) {
-	if errData, ok := event["error"].(map[string]interface{}); ok {
-		log.Printf("Claude API Error: %v", errData)
+	batchResult := c.handle(batch)
+	for j, i := range batchIndex {
+		switch {
+		case batchResult == nil:
+			results[i].Content = "no command handler configured"
+			results[i].IsError = true
+		case batchResult.Results[j].Skipped:
+			results[i].Content = "skipped"
+		case batchResult.Results[j].Success:
+			results[i].Content = "ok"
+		default:
+			results[i].Content = "error: " + batchResult.Results[j].Error
+			results[i].IsError = true
+		}
 	}
+
+	return results, batchResult
 }
 
-// parseCommand parses command from Claude's text response
-func (c *RealtimeClient) parseCommand(text string) {
-	// Try to parse as JSON command
-	var command core.Command
-	if err := json.Unmarshal([]byte(text), &command); err != nil {
-		// Not a valid JSON command, might be regular text
-		log.Printf("Not a command, regular text: %s", text)
-		return
-	}
+// handle passes a batch to the command handler
+func (c *Client) handle(batch *core.Batch) *core.BatchResult {
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
 
-	if command.Action == "" {
-		return
+	if handler == nil {
+		log.Printf("Warning: No command handler, ignoring %d command(s)", len(batch.Commands))
+		return nil
 	}
+	return handler(batch)
+}
 
-	log.Printf("Parsed command: action=%s, device=%s, value=%v", command.Action, command.Device, command.Value)
+// request builds a streaming Messages API request, optionally with the device tools
+func (c *Client) request(messages []message, conversation string, tools bool) messagesRequest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	select {
-	case c.commandOutChan <- &command:
-		log.Println("Command sent to execution queue")
-	default:
-		log.Println("Warning: Command output channel is full")
+	system := c.config.SystemPrompt
+	for _, extra := range []string{c.catalog, c.context, conversation} {
+		if extra != "" {
+			system += "\n\n" + extra
+		}
+	}
+	body := messagesRequest{
+		Model:       c.config.Model,
+		MaxTokens:   c.config.MaxTokens,
+		Temperature: c.config.Temperature,
+		System:      system,
+		Messages:    messages,
+		Stream:      true,
+	}
+	if tools {
+		body.Tools = c.tools
 	}
+	return body
 }
 
-// GetCommandChannel returns the channel for receiving commands
-func (c *RealtimeClient) GetCommandChannel() <-chan *core.Command {
-	return c.commandOutChan
+// textHandler returns the callback for streamed text deltas
+func (c *Client) textHandler() func(delta string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.onText
 }
 
-// sendJSON sends a JSON message to Claude
-func (c *RealtimeClient) sendJSON(data interface{}) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// stream sends a streaming Messages API request and assembles the response
+// content blocks, passing text deltas to onText if set
+func (c *Client) stream(ctx context.Context, body messagesRequest, onText func(delta string)) ([]contentBlock, string, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
 
-	if !c.isConnected {
-		return fmt.Errorf("not connected")
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.APIURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	jsonData, err := json.Marshal(data)
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("accept", "text/event-stream")
+	req.Header.Set("anthropic-version", anthropicVersion)
+	req.Header.Set("x-api-key", c.config.APIKey)
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
+		return nil, "", fmt.Errorf("request failed: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if err := c.conn.WriteMessage(websocket.TextMessage, jsonData); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("API error %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
 	}
 
-	return nil
-}
+	var blocks []contentBlock
+	var partialJSON []strings.Builder
+	stopReason := ""
 
-// Disconnect closes the WebSocket connection
-func (c *RealtimeClient) Disconnect() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	err = readEvents(resp.Body, func(data []byte) error {
+		var event streamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+
+		switch event.Type {
+		case "content_block_start":
+			if event.ContentBlock == nil {
+				return nil
+			}
+			for len(blocks) <= event.Index {
+				blocks = append(blocks, contentBlock{})
+				partialJSON = append(partialJSON, strings.Builder{})
+			}
+			blocks[event.Index] = *event.ContentBlock
+
+		case "content_block_delta":
+			if event.Index >= len(blocks) {
+				return fmt.Errorf("delta for unknown content block %d", event.Index)
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				blocks[event.Index].Text += event.Delta.Text
+				if onText != nil {
+					onText(event.Delta.Text)
+				}
+			case "input_json_delta":
+				partialJSON[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+
+		case "content_block_stop":
+			if event.Index < len(blocks) && blocks[event.Index].Type == "tool_use" {
+				if input := partialJSON[event.Index].String(); input != "" {
+					blocks[event.Index].Input = json.RawMessage(input)
+				}
+				if len(blocks[event.Index].Input) == 0 {
+					blocks[event.Index].Input = json.RawMessage("{}")
+				}
+			}
+
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("API error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return fmt.Errorf("API error")
+		}
 
-	if !c.isConnected {
 		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return blocks, stopReason, nil
+}
+
+// readEvents reads a server-sent event stream and calls handle with each event's data
+func readEvents(r io.Reader, handle func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data bytes.Buffer
+	dispatch := func() error {
+		if data.Len() == 0 {
+			return nil
+		}
+		defer data.Reset()
+		return handle(data.Bytes())
 	}
 
-	close(c.stopChan)
+	for scanner.Scan() {
+		line := scanner.Text()
 
-	if c.conn != nil {
-		err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		if err != nil {
-			log.Printf("Error sending close message: %v", err)
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "data:") {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
 		}
-		c.conn.Close()
 	}
 
-	c.isConnected = false
-	close(c.audioInChan)
-	close(c.commandOutChan)
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
 
-	log.Println("Disconnected from Claude Realtime API")
+	return dispatch()
+}
 
-	return nil
+// nonEmptyBlocks drops empty text blocks, which the API rejects in requests
+func nonEmptyBlocks(blocks []contentBlock) []contentBlock {
+	result := make([]contentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" && block.Text == "" {
+			continue
+		}
+		result = append(result, block)
+	}
+	return result
 }
 
-// IsConnected returns whether the client is connected
-func (c *RealtimeClient) IsConnected() bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.isConnected
+// toolAction returns the device action a tool maps to
+func toolAction(tool Tool) string {
+	if tool.action != "" {
+		return tool.action
+	}
+	return strings.Replace(tool.Name, "_", ".", 1)
 }
@@ -1,7 +1,13 @@
 package claude
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/truong-nautilus/smart-home-ai/core"
@@ -71,11 +77,11 @@ func TestCommandParsing(t *testing.T) {
 
 func TestClaudeConfig(t *testing.T) {
 	config := ClaudeConfig{
-		APIKey:       "test-key",
-		Model:        "claude-3-5-sonnet-20241022",
-		WebSocketURL: "wss://api.anthropic.com/v1/messages",
-		MaxTokens:    1024,
-		Temperature:  0.7,
+		APIKey:      "test-key",
+		Model:       "claude-3-5-sonnet-20241022",
+		APIURL:      "https://api.anthropic.com/v1/messages",
+		MaxTokens:   1024,
+		Temperature: 0.7,
 	}
 
 	if config.Model != "claude-3-5-sonnet-20241022" {
@@ -86,3 +92,254 @@ func TestClaudeConfig(t *testing.T) {
 		t.Errorf("Expected temperature 0.7, got %f", config.Temperature)
 	}
 }
+
+// sseResponse formats events as a server-sent event stream
+func sseResponse(events ...string) string {
+	var sb strings.Builder
+	for _, event := range events {
+		var head struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal([]byte(event), &head)
+		fmt.Fprintf(&sb, "event: %s\ndata: %s\n\n", head.Type, event)
+	}
+	return sb.String()
+}
+
+// newTestServer serves the given SSE responses in order and records request bodies
+func newTestServer(t *testing.T, responses ...string) (*httptest.Server, *[]messagesRequest) {
+	t.Helper()
+
+	var requests []messagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error"}}`, http.StatusUnauthorized)
+			return
+		}
+
+		var req messagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		requests = append(requests, req)
+
+		if len(requests) > len(responses) {
+			t.Errorf("Unexpected request %d", len(requests))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, responses[len(requests)-1])
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestSendToolUse(t *testing.T) {
+	server, requests := newTestServer(t,
+		sseResponse(
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Vâng, "}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"light_brightness","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"device\": \"phong"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"_khach\", \"value\": 80}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"vacuum_start","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"device\": \"robot_hut_bui\"}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+			`{"type":"message_stop"}`,
+		),
+		sseResponse(
+			`{"type":"message_start","message":{"id":"msg_2","role":"assistant","content":[]}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Đã chỉnh đèn, "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"robot bị lỗi."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+			`{"type":"message_stop"}`,
+		),
+	)
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", Model: "test-model", APIURL: server.URL, MaxTokens: 256})
+
+	var executed []*core.Command
+	client.SetCommandHandler(func(batch *core.Batch) *core.BatchResult {
+		executed = append(executed, batch.Commands...)
+		return &core.BatchResult{Results: []core.CommandResult{
+			{Command: batch.Commands[0], Success: true},
+			{Command: batch.Commands[1], Error: "device offline"},
+		}}
+	})
+
+	var streamed strings.Builder
+	client.SetTextHandler(func(delta string) { streamed.WriteString(delta) })
+
+	reply, err := client.Send(context.Background(), "Chỉnh đèn phòng khách 80% và bật robot")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if reply.Text != "Đã chỉnh đèn, robot bị lỗi." {
+		t.Errorf("Reply text = %q", reply.Text)
+	}
+	if streamed.String() != "Vâng, Đã chỉnh đèn, robot bị lỗi." {
+		t.Errorf("Streamed text = %q", streamed.String())
+	}
+
+	if len(executed) != 2 {
+		t.Fatalf("Expected 2 executed commands, got %d", len(executed))
+	}
+	if executed[0].Action != "light.brightness" || executed[0].Device != "phong_khach" || executed[0].Value != float64(80) {
+		t.Errorf("Unexpected first command: %+v", executed[0])
+	}
+	if executed[1].Action != "vacuum.start" {
+		t.Errorf("Unexpected second command: %+v", executed[1])
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+
+	first := (*requests)[0]
+	if !first.Stream || len(first.Tools) == 0 {
+		t.Error("Expected streaming request with tools")
+	}
+
+	second := (*requests)[1]
+	if len(second.Messages) != 3 {
+		t.Fatalf("Expected 3 messages in follow-up request, got %d", len(second.Messages))
+	}
+	results := second.Messages[2].Content
+	if len(results) != 2 || results[0].ToolUseID != "toolu_1" || results[0].IsError {
+		t.Errorf("Unexpected first tool result: %+v", results)
+	}
+	if results[1].ToolUseID != "toolu_2" || !results[1].IsError || !strings.Contains(results[1].Content, "device offline") {
+		t.Errorf("Unexpected second tool result: %+v", results[1])
+	}
+}
+
+func TestSendTextCommandFallback(t *testing.T) {
+	server, _ := newTestServer(t, sseResponse(
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"[{\"action\":\"light.off\",\"device\":\"bep\"},{\"action\":\"tv.power\",\"device\":\"tv\"}]"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+	))
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+
+	var batches []*core.Batch
+	client.SetCommandHandler(func(batch *core.Batch) *core.BatchResult {
+		batches = append(batches, batch)
+		return &core.BatchResult{Success: true}
+	})
+
+	reply, err := client.Send(context.Background(), "tắt đèn bếp và tắt TV")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(batches) != 1 || len(batches[0].Commands) != 2 {
+		t.Fatalf("Expected one batch of 2 commands, got %v", batches)
+	}
+	if len(reply.Results) != 1 {
+		t.Errorf("Expected 1 batch result, got %d", len(reply.Results))
+	}
+}
+
+func TestSendToolRoundFails(t *testing.T) {
+	server, _ := newTestServer(t,
+		sseResponse(
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"switch_toggle","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"device\": \"quat\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		),
+		sseResponse(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+	)
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+	var result *core.BatchResult
+	client.SetCommandHandler(func(batch *core.Batch) *core.BatchResult {
+		result = &core.BatchResult{Success: true, Results: []core.CommandResult{{Command: batch.Commands[0], Success: true}}}
+		return result
+	})
+
+	// The toggle already ran, so it is reported along with the error
+	reply, err := client.Send(context.Background(), "bật tắt quạt")
+	if err == nil {
+		t.Fatal("Expected the failed round's error")
+	}
+	if reply == nil || len(reply.Results) != 1 || reply.Results[0] != result {
+		t.Errorf("Expected the executed batch in the reply, got %+v", reply)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	server, _ := newTestServer(t, sseResponse(
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	))
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+	if _, err := client.Send(context.Background(), "xin chào"); err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected overloaded error, got %v", err)
+	}
+
+	client = NewClient(ClaudeConfig{APIKey: "wrong-key", APIURL: server.URL})
+	if _, err := client.Send(context.Background(), "xin chào"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected HTTP 401 error, got %v", err)
+	}
+}
+
+func TestDefaultTools(t *testing.T) {
+	tools := DefaultTools()
+	if len(tools) != len(actionSpecs) {
+		t.Errorf("Expected %d tools, got %d", len(actionSpecs), len(tools))
+	}
+
+	for _, tool := range tools {
+		if tool.Name == "light_color_temp" && toolAction(tool) != "light.color_temp" {
+			t.Errorf("light_color_temp maps to %s", toolAction(tool))
+		}
+	}
+}
+
+func TestToolCommandBatchOptions(t *testing.T) {
+	cmd, err := toolCommand("light.off", map[string]interface{}{
+		"device":    "bep",
+		"delay_ms":  float64(5000),
+		"condition": "on_success",
+	}, nil)
+	if err != nil {
+		t.Fatalf("toolCommand() error = %v", err)
+	}
+	if cmd.DelayMs != 5000 || cmd.Condition != core.ConditionOnSuccess {
+		t.Errorf("Command = %+v", cmd)
+	}
+
+	invalid := []map[string]interface{}{
+		{"device": "bep", "delay_ms": float64(core.MaxDelayMs + 1)},
+		{"device": "bep", "delay_ms": float64(-1)},
+		{"device": "bep", "delay_ms": 1.5},
+		{"device": "bep", "condition": "sometimes"},
+	}
+	for _, input := range invalid {
+		if _, err := toolCommand("light.off", input, nil); err == nil {
+			t.Errorf("Expected error for %v", input)
+		}
+	}
+
+	// Every tool offers the options
+	properties := DefaultTools()[0].InputSchema["properties"].(map[string]interface{})
+	if delay, ok := properties["delay_ms"].(map[string]interface{}); !ok || delay["maximum"] != core.MaxDelayMs {
+		t.Errorf("delay_ms schema = %v", properties["delay_ms"])
+	}
+	if _, ok := properties["condition"]; !ok {
+		t.Error("Expected a condition property")
+	}
+}
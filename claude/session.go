@@ -0,0 +1,179 @@
+package claude
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// Session defaults
+const (
+	defaultIdleTimeout = 5 * time.Minute
+	defaultMaxTurns    = 8
+	maxRecentDevices   = 5
+	maxSummaryRunes    = 2000
+)
+
+// SessionConfig controls how much of the conversation the client remembers
+type SessionConfig struct {
+	IdleTimeout time.Duration // forget the conversation after this long without requests
+	MaxTurns    int           // recent turns sent verbatim; older turns are summarized
+}
+
+// turn is one request and the messages exchanged while answering it
+type turn struct {
+	messages []message
+	text     string
+	reply    string
+	commands []string
+}
+
+// session is the recent conversation of a client. Follow-ups such as "make
+// it dimmer" are resolved from the recent turns and the last devices used.
+type session struct {
+	config      SessionConfig
+	turns       []turn
+	summary     string
+	devices     []string // recently referenced device IDs, most recent first
+	lastActive  time.Time
+	generation  int // incremented on reset, so stale summaries are discarded
+	summarizing bool
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+// newSession creates a session, applying defaults
+func newSession(config SessionConfig) *session {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultIdleTimeout
+	}
+	if config.MaxTurns <= 0 {
+		config.MaxTurns = defaultMaxTurns
+	}
+	return &session{config: config, now: time.Now}
+}
+
+// history returns the messages of the recent turns, the summary of older
+// ones and the recently used devices. An idle session is reset first.
+func (s *session) history() ([]message, string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.lastActive.IsZero() && s.now().Sub(s.lastActive) > s.config.IdleTimeout {
+		log.Println("Conversation idle, starting a new session")
+		s.reset()
+	}
+
+	var messages []message
+	for _, t := range s.turns {
+		messages = append(messages, t.messages...)
+	}
+	return messages, s.summary, append([]string(nil), s.devices...)
+}
+
+// add records a finished turn and the devices its commands used. If the
+// session has grown too long, it returns the oldest turns to summarize.
+func (s *session) add(t turn, results []*core.BatchResult) ([]turn, string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, batch := range results {
+		for _, result := range batch.Results {
+			if result.Skipped || result.Command == nil {
+				continue
+			}
+			t.commands = append(t.commands, commandSummary(result))
+			s.touchDevice(result.Command.Device)
+		}
+	}
+
+	s.turns = append(s.turns, t)
+	s.lastActive = s.now()
+
+	if len(s.turns) <= s.config.MaxTurns || s.summarizing {
+		return nil, "", 0
+	}
+
+	// Summarize the older half, keeping the latest turns verbatim
+	keep := s.config.MaxTurns / 2
+	if keep < 1 {
+		keep = 1
+	}
+	s.summarizing = true
+	old := append([]turn(nil), s.turns[:len(s.turns)-keep]...)
+	return old, s.summary, s.generation
+}
+
+// summarized replaces the first n turns with a summary
+func (s *session) summarized(generation, n int, summary string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.summarizing = false
+	if generation != s.generation || n > len(s.turns) {
+		return
+	}
+	s.turns = s.turns[n:]
+	s.summary = summary
+}
+
+// touchDevice moves a device to the front of the recent devices
+func (s *session) touchDevice(id string) {
+	devices := []string{id}
+	for _, device := range s.devices {
+		if device != id && len(devices) < maxRecentDevices {
+			devices = append(devices, device)
+		}
+	}
+	s.devices = devices
+}
+
+// reset forgets the conversation. The caller holds the lock.
+func (s *session) reset() {
+	s.turns = nil
+	s.summary = ""
+	s.devices = nil
+	s.lastActive = time.Time{}
+	s.generation++
+}
+
+// commandSummary describes an executed command, e.g. "light.on phong_ngu (ok)"
+func commandSummary(result core.CommandResult) string {
+	status := "ok"
+	if !result.Success {
+		status = "lỗi: " + result.Error
+	}
+	summary := result.Command.Action + " " + result.Command.Device
+	if result.Command.Value != nil {
+		summary += fmt.Sprintf(" %v", result.Command.Value)
+	}
+	return summary + " (" + status + ")"
+}
+
+// transcript renders turns as plain text for summarization
+func transcript(turns []turn) string {
+	var sb strings.Builder
+	for _, t := range turns {
+		fmt.Fprintf(&sb, "Người dùng: %s\n", t.text)
+		if len(t.commands) > 0 {
+			fmt.Fprintf(&sb, "Lệnh: %s\n", strings.Join(t.commands, "; "))
+		}
+		if t.reply != "" {
+			fmt.Fprintf(&sb, "Jarvis: %s\n", t.reply)
+		}
+	}
+	return sb.String()
+}
+
+// truncateSummary keeps the end of a summary within maxSummaryRunes
+func truncateSummary(summary string) string {
+	runes := []rune(summary)
+	if len(runes) <= maxSummaryRunes {
+		return summary
+	}
+	return string(runes[len(runes)-maxSummaryRunes:])
+}
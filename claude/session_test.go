@@ -0,0 +1,161 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// textResponse is an SSE response with a single text block
+func textResponse(text string) string {
+	return sseResponse(
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"`+text+`"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+	)
+}
+
+// okHandler reports every command as successful
+func okHandler(batch *core.Batch) *core.BatchResult {
+	result := &core.BatchResult{Success: true}
+	for _, cmd := range batch.Commands {
+		result.Results = append(result.Results, core.CommandResult{Command: cmd, Success: true})
+	}
+	return result
+}
+
+func TestSendKeepsConversation(t *testing.T) {
+	server, requests := newTestServer(t,
+		sseResponse(
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"light_on","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"device\": \"phong_ngu\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		),
+		sseResponse(`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`),
+		textResponse("Đã giảm độ sáng."),
+	)
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+	client.UpdateCatalog(&core.Config{Devices: core.DevicesConfig{Lights: map[string]core.DeviceInfo{
+		"phong_ngu": {Type: "tapo", Name: "Đèn Phòng Ngủ", Area: "phòng ngủ"},
+	}}}, core.NewSecurityManager())
+	client.SetCommandHandler(okHandler)
+
+	if _, err := client.Send(context.Background(), "bật đèn phòng ngủ"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := client.Send(context.Background(), "làm nó tối hơn"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(*requests))
+	}
+	followUp := (*requests)[2]
+
+	// user, tool_use, tool_result, closing assistant text, then the follow-up
+	if len(followUp.Messages) != 5 {
+		t.Fatalf("Expected 5 messages, got %d: %+v", len(followUp.Messages), followUp.Messages)
+	}
+	for i, role := range []string{"user", "assistant", "user", "assistant", "user"} {
+		if followUp.Messages[i].Role != role {
+			t.Errorf("Message %d role = %s, want %s", i, followUp.Messages[i].Role, role)
+		}
+	}
+	if closing := followUp.Messages[3].Content[0].Text; !strings.Contains(closing, "light.on phong_ngu") {
+		t.Errorf("Closing message = %q", closing)
+	}
+	if !strings.Contains(followUp.System, "phong_ngu (Đèn Phòng Ngủ, phòng ngủ)") || !strings.Contains(followUp.System, "Khu vực gần nhất: phòng ngủ") {
+		t.Errorf("System prompt lacks recent device context:\n%s", followUp.System)
+	}
+}
+
+func TestSessionIdleReset(t *testing.T) {
+	server, requests := newTestServer(t, textResponse("Chào bạn."), textResponse("Vâng."))
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL, Session: SessionConfig{IdleTimeout: time.Minute}})
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	client.session.now = func() time.Time { return now }
+
+	if _, err := client.Send(context.Background(), "xin chào"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := client.Send(context.Background(), "tắt nó đi"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if got := len((*requests)[1].Messages); got != 1 {
+		t.Errorf("Expected a fresh conversation after idling, got %d messages", got)
+	}
+}
+
+func TestRememberLocalTurn(t *testing.T) {
+	server, requests := newTestServer(t, textResponse("Đã giảm."))
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL})
+	cmd := &core.Command{Action: "light.on", Device: "bep"}
+	client.Remember("bật đèn bếp", &Reply{Results: []*core.BatchResult{{
+		Results: []core.CommandResult{{Command: cmd, Success: true}}, Success: true,
+	}}})
+
+	if _, err := client.Send(context.Background(), "giảm độ sáng"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	request := (*requests)[0]
+	if len(request.Messages) != 3 || request.Messages[0].Content[0].Text != "bật đèn bếp" {
+		t.Errorf("Unexpected messages: %+v", request.Messages)
+	}
+	if !strings.Contains(request.System, "Thiết bị vừa được nhắc tới (mới nhất trước): bep") {
+		t.Errorf("System prompt lacks the locally controlled device:\n%s", request.System)
+	}
+}
+
+func TestSessionSummarizesOldTurns(t *testing.T) {
+	server, requests := newTestServer(t, textResponse("Đã bật đèn bếp và quạt."), textResponse("Vâng."))
+
+	client := NewClient(ClaudeConfig{APIKey: "test-key", APIURL: server.URL, Session: SessionConfig{MaxTurns: 2}})
+	for _, text := range []string{"bật đèn bếp", "bật quạt", "mấy giờ rồi"} {
+		client.Remember(text, &Reply{Text: "Vâng."})
+	}
+
+	// The two oldest turns are summarized in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client.session.mu.Lock()
+		summary, turns := client.session.summary, len(client.session.turns)
+		client.session.mu.Unlock()
+		if summary != "" {
+			if turns != 1 {
+				t.Errorf("Expected 1 remaining turn, got %d", turns)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Conversation was not summarized")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	summaryRequest := (*requests)[0]
+	if len(summaryRequest.Tools) != 0 || !strings.Contains(summaryRequest.Messages[0].Content[0].Text, "Người dùng: bật quạt") {
+		t.Errorf("Unexpected summary request: %+v", summaryRequest)
+	}
+
+	if _, err := client.Send(context.Background(), "tắt hết đi"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	request := (*requests)[1]
+	if !strings.Contains(request.System, "Tóm tắt hội thoại trước: Đã bật đèn bếp và quạt.") {
+		t.Errorf("System prompt lacks the summary:\n%s", request.System)
+	}
+	if len(request.Messages) != 3 {
+		t.Errorf("Expected the last turn and the new request, got %d messages", len(request.Messages))
+	}
+}
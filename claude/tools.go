@@ -0,0 +1,191 @@
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// Tool is a tool definition sent to the Messages API
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+
+	// action is the device action the tool maps to
+	action string
+}
+
+// actionSpec describes a device action exposed as a tool
+type actionSpec struct {
+	description string
+	value       map[string]interface{}
+}
+
+// actionSpecs lists the device actions Claude can call
+var actionSpecs = map[string]actionSpec{
+	"light.on":  {description: "Turn a light on"},
+	"light.off": {description: "Turn a light off"},
+	"light.brightness": {
+		description: "Set the brightness of a light in percent",
+		value:       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100},
+	},
+	"light.color": {
+		description: "Set the color of a light",
+		value: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"hue":        map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 360},
+				"saturation": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100},
+			},
+			"required": []string{"hue", "saturation"},
+		},
+	},
+	"light.color_temp": {
+		description: "Set the color temperature of a light in Kelvin",
+		value:       map[string]interface{}{"type": "integer", "minimum": 2500, "maximum": 6500},
+	},
+	"switch.on":     {description: "Turn a switch or smart plug on"},
+	"switch.off":    {description: "Turn a switch or smart plug off"},
+	"switch.toggle": {description: "Toggle a switch or smart plug"},
+	"ac.on":         {description: "Turn an air conditioner on"},
+	"ac.off":        {description: "Turn an air conditioner off"},
+	"ac.set_temp": {
+		description: "Set the target temperature of an air conditioner in °C",
+		value:       map[string]interface{}{"type": "integer", "minimum": 16, "maximum": 30},
+	},
+	"vacuum.start": {description: "Start the vacuum robot"},
+	"vacuum.stop":  {description: "Stop the vacuum robot"},
+	"vacuum.pause": {description: "Pause the vacuum robot"},
+	"vacuum.home":  {description: "Send the vacuum robot back to its dock"},
+	"vacuum.spot":  {description: "Spot clean around the vacuum robot"},
+	"vacuum.fan_speed": {
+		description: "Set the suction power of the vacuum robot in percent",
+		value:       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100},
+	},
+	"tv.power":    {description: "Toggle TV power"},
+	"tv.vol_up":   {description: "Turn the TV volume up"},
+	"tv.vol_down": {description: "Turn the TV volume down"},
+}
+
+// ToolName returns the tool name for a device action (e.g. light.on -> light_on)
+func ToolName(action string) string {
+	return strings.ReplaceAll(action, ".", "_")
+}
+
+// DefaultTools returns a tool definition for every known device action
+func DefaultTools() []Tool {
+	actions := make([]string, 0, len(actionSpecs))
+	for action := range actionSpecs {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	tools := make([]Tool, 0, len(actions))
+	for _, action := range actions {
+		spec := actionSpecs[action]
+		tools = append(tools, newTool(action, spec.description, nil, spec.value))
+	}
+	return tools
+}
+
+// newTool builds a tool whose input is a device ID, an optional value and
+// the batch options delay_ms and condition
+func newTool(action, description string, deviceIDs []string, value map[string]interface{}) Tool {
+	device := map[string]interface{}{
+		"type":        "string",
+		"description": "Device ID",
+	}
+	if len(deviceIDs) > 0 {
+		device["enum"] = deviceIDs
+	}
+
+	properties := map[string]interface{}{
+		"device": device,
+		"delay_ms": map[string]interface{}{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     core.MaxDelayMs,
+			"description": "Milliseconds to wait before running the command",
+		},
+		"condition": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{core.ConditionAlways, core.ConditionOnSuccess, core.ConditionOnFailure},
+			"description": "Run only if the previous tool call in this response succeeded or failed",
+		},
+	}
+	required := []string{"device"}
+	if value != nil {
+		properties["value"] = value
+		required = append(required, "value")
+	}
+
+	return Tool{
+		Name:        ToolName(action),
+		Description: description,
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+		action: action,
+	}
+}
+
+// toolCommand converts a tool call into a command
+func toolCommand(action string, input map[string]interface{}, devices map[string]core.CatalogEntry) (*core.Command, error) {
+	device, ok := input["device"].(string)
+	if !ok || device == "" {
+		return nil, fmt.Errorf("missing device for %s", action)
+	}
+
+	if entry, ok := devices[device]; ok && action == "ac.set_temp" {
+		if err := checkTemperature(entry, input["value"]); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := &core.Command{
+		Action: action,
+		Device: device,
+		Value:  input["value"],
+	}
+
+	if delay, ok := input["delay_ms"]; ok {
+		ms, ok := delay.(float64)
+		if !ok || ms != float64(int(ms)) || ms < 0 || ms > core.MaxDelayMs {
+			return nil, fmt.Errorf("delay_ms must be a whole number between 0 and %d", core.MaxDelayMs)
+		}
+		cmd.DelayMs = int(ms)
+	}
+
+	if condition, ok := input["condition"]; ok {
+		cmd.Condition, _ = condition.(string)
+		switch cmd.Condition {
+		case core.ConditionAlways, core.ConditionOnSuccess, core.ConditionOnFailure:
+		default:
+			return nil, fmt.Errorf("unknown condition: %v", condition)
+		}
+	}
+
+	return cmd, nil
+}
+
+// checkTemperature rejects a temperature the AC has no IR code for
+func checkTemperature(entry core.CatalogEntry, value interface{}) error {
+	temp, ok := value.(float64)
+	if !ok {
+		return fmt.Errorf("invalid temperature value")
+	}
+
+	available := make([]string, len(entry.Temperatures))
+	for i, t := range entry.Temperatures {
+		if float64(t) == temp {
+			return nil
+		}
+		available[i] = fmt.Sprint(t)
+	}
+	return fmt.Errorf("%s has no IR code for %v°C (available: %s)", entry.ID, temp, strings.Join(available, ", "))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// runConfig runs the config subcommands. "validate" checks the configuration
+// and, with -reachable, probes every device.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		log.Fatal("Usage: jarvis config validate [-reachable]")
+	}
+
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	reachable := flags.Bool("reachable", false, "also probe every device and warn about unreachable ones")
+	flags.Parse(args[1:])
+
+	// .env may define variables the config refers to
+	godotenv.Load(envFile)
+	config, err := core.LoadConfigWithSecrets(configFile(), secretStore)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	var problems core.ConfigErrors
+	errors.As(config.Validate(), &problems)
+	for _, problem := range problems {
+		fmt.Printf("error: %s\n", problem)
+	}
+
+	if *reachable {
+		router := initRouter(config)
+		monitor := core.NewHealthMonitor(router, config.Health)
+		monitor.CheckOnce()
+		router.Close()
+
+		for _, status := range monitor.GetAllStatus() {
+			switch {
+			case !status.Checked:
+				fmt.Printf("warning: %s (%s) availability is unknown: %s\n", status.DeviceID, status.Protocol, status.Error)
+			case !status.Online:
+				fmt.Printf("warning: %s (%s) is unreachable: %s\n", status.DeviceID, status.Protocol, status.Error)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s: %d errors\n", configFile(), len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", configFile())
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Command conditions, evaluated against the previous command in a batch
+const (
+	ConditionAlways    = "always"
+	ConditionOnSuccess = "on_success"
+	ConditionOnFailure = "on_failure"
+)
+
+// MaxDelayMs is the longest delay allowed before a batch command. The batch
+// runs while the request is answered, so a long wait would block Jarvis.
+const MaxDelayMs = 60000
+
+// Batch is an ordered list of commands
+type Batch struct {
+	Commands    []*Command `json:"commands"`
+	StopOnError bool       `json:"stop_on_error,omitempty"`
+}
+
+// CommandResult is the outcome of a single command in a batch
+type CommandResult struct {
+	Command *Command `json:"command"`
+	Success bool     `json:"success"`
+	Skipped bool     `json:"skipped,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// BatchResult is the combined outcome of a batch
+type BatchResult struct {
+	Results []CommandResult `json:"results"`
+	Success bool            `json:"success"`
+
+	// Pending is set when the batch was held back to ask the user first
+	Pending *PendingAction `json:"pending,omitempty"`
+}
+
+// ParseBatch parses one or more commands from text or JSON. It accepts a
+// single command object, an array of commands, or a batch object with a
+// "commands" field, optionally surrounded by other text.
+func ParseBatch(text string) (*Batch, error) {
+	text = strings.TrimSpace(text)
+
+	if batch, err := decodeBatch(text); err == nil {
+		return batch, nil
+	}
+
+	// Try extracting JSON from text. A bracket may also appear in the prose
+	// before the command, so every candidate start is tried in turn. JSON
+	// that isn't a valid batch is skipped whole, so a bad command list
+	// isn't replaced by one of its commands.
+	err := fmt.Errorf("not a valid command")
+	for start := strings.IndexAny(text, "[{"); start >= 0; {
+		end := start + 1
+		var raw json.RawMessage
+		decoder := json.NewDecoder(strings.NewReader(text[start:]))
+		if decoder.Decode(&raw) == nil {
+			var batch *Batch
+			if batch, err = decodeBatch(string(raw)); err == nil {
+				return batch, nil
+			}
+			end = start + int(decoder.InputOffset())
+		}
+
+		next := strings.IndexAny(text[end:], "[{")
+		if next < 0 {
+			break
+		}
+		start = end + next
+	}
+
+	return nil, err
+}
+
+// decodeBatch decodes a JSON command, command array or batch object
+func decodeBatch(text string) (*Batch, error) {
+	var commands []*Command
+	if err := json.Unmarshal([]byte(text), &commands); err == nil {
+		return newBatch(commands, false)
+	}
+
+	var batch Batch
+	if err := json.Unmarshal([]byte(text), &batch); err == nil && len(batch.Commands) > 0 {
+		return newBatch(batch.Commands, batch.StopOnError)
+	}
+
+	var cmd Command
+	if err := json.Unmarshal([]byte(text), &cmd); err == nil && cmd.Action != "" {
+		return newBatch([]*Command{&cmd}, false)
+	}
+
+	return nil, fmt.Errorf("not a valid command")
+}
+
+// newBatch checks that every command in a batch has an action
+func newBatch(commands []*Command, stopOnError bool) (*Batch, error) {
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("empty command list")
+	}
+
+	for i, cmd := range commands {
+		if cmd == nil || cmd.Action == "" {
+			return nil, fmt.Errorf("command %d has no action", i)
+		}
+		switch cmd.Condition {
+		case "", ConditionAlways, ConditionOnSuccess, ConditionOnFailure:
+		default:
+			return nil, fmt.Errorf("command %d has unknown condition: %s", i, cmd.Condition)
+		}
+		if err := checkDelay(cmd); err != nil {
+			return nil, fmt.Errorf("command %d: %w", i, err)
+		}
+	}
+
+	return &Batch{Commands: commands, StopOnError: stopOnError}, nil
+}
+
+// checkDelay rejects negative delays and delays over MaxDelayMs
+func checkDelay(cmd *Command) error {
+	if cmd.DelayMs < 0 || cmd.DelayMs > MaxDelayMs {
+		return fmt.Errorf("delay_ms must be between 0 and %d", MaxDelayMs)
+	}
+	return nil
+}
+
+// Summary returns a one-line description of the batch outcome
+func (b *BatchResult) Summary() string {
+	parts := make([]string, 0, len(b.Results))
+	for _, result := range b.Results {
+		status := "ok"
+		if result.Skipped {
+			status = "skipped"
+		} else if !result.Success {
+			status = "failed: " + result.Error
+		}
+		parts = append(parts, fmt.Sprintf("%s %s (%s)", result.Command.Action, result.Command.Device, status))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Executor validates and executes commands through the security manager and router
+type Executor struct {
+	security *SecurityManager
+	router   *CommandRouter
+	sleep    func(time.Duration)
+	now      func() time.Time
+}
+
+// NewExecutor creates a new command executor
+func NewExecutor(security *SecurityManager, router *CommandRouter) *Executor {
+	return &Executor{
+		security: security,
+		router:   router,
+		sleep:    time.Sleep,
+		now:      time.Now,
+	}
+}
+
+// Execute runs the commands of a batch in order and returns the combined
+// result. A batch naming an ambiguous device or needing confirmation isn't
+// run; its result carries the pending action instead.
+func (e *Executor) Execute(batch *Batch) *BatchResult {
+	if pending := e.hold(batch); pending != nil {
+		log.Printf("Holding batch: %s", pending.status())
+		result := &BatchResult{Results: make([]CommandResult, 0, len(batch.Commands)), Pending: pending}
+		for _, cmd := range batch.Commands {
+			result.Results = append(result.Results, CommandResult{Command: cmd, Error: pending.status()})
+		}
+		return result
+	}
+	return e.run(batch)
+}
+
+// Resume runs a pending batch once the user has answered. After a device
+// was picked, the batch may still need confirmation.
+func (e *Executor) Resume(pending *PendingAction) *BatchResult {
+	if pending.Ambiguous != nil {
+		return e.Execute(pending.Batch)
+	}
+	return e.run(pending.Batch)
+}
+
+// hold returns the pending action for a batch that must wait for the user
+func (e *Executor) hold(batch *Batch) *PendingAction {
+	for _, cmd := range batch.Commands {
+		deviceID, err := e.router.ResolveDevice(cmd.Device, cmd.Action)
+		var ambiguous *AmbiguousDeviceError
+		if errors.As(err, &ambiguous) {
+			ambiguous.Batch, ambiguous.Command = batch, cmd
+			return &PendingAction{Batch: batch, Ambiguous: ambiguous}
+		}
+		if err == nil {
+			cmd.Device = deviceID
+		}
+	}
+
+	if reason := e.security.ConfirmationReason(batch, e.now()); reason != "" {
+		return &PendingAction{Batch: batch, Reason: reason}
+	}
+	return nil
+}
+
+// run executes a batch without asking the user
+func (e *Executor) run(batch *Batch) *BatchResult {
+	result := &BatchResult{
+		Results: make([]CommandResult, 0, len(batch.Commands)),
+		Success: true,
+	}
+
+	previousOK := true
+	stopped := false
+
+	for _, cmd := range batch.Commands {
+		if stopped || !conditionMet(cmd.Condition, previousOK) {
+			log.Printf("Skipping command: %s on %s", cmd.Action, cmd.Device)
+			result.Results = append(result.Results, CommandResult{Command: cmd, Skipped: true})
+			continue
+		}
+
+		// Batches built in code skip ParseBatch, so the delay is checked again
+		err := checkDelay(cmd)
+		if err == nil && cmd.DelayMs > 0 {
+			log.Printf("Waiting %dms before %s on %s", cmd.DelayMs, cmd.Action, cmd.Device)
+			e.sleep(time.Duration(cmd.DelayMs) * time.Millisecond)
+		}
+
+		if err == nil {
+			err = e.ExecuteCommand(cmd)
+		}
+		cmdResult := CommandResult{Command: cmd, Success: err == nil}
+		if err != nil {
+			cmdResult.Error = err.Error()
+			result.Success = false
+			stopped = batch.StopOnError
+		}
+
+		result.Results = append(result.Results, cmdResult)
+		previousOK = err == nil
+	}
+
+	return result
+}
+
+// ExecuteCommand validates, executes and logs a single command
+func (e *Executor) ExecuteCommand(cmd *Command) error {
+	// Use the canonical ID, so results and logs name the actual device
+	if deviceID, err := e.router.ResolveDevice(cmd.Device, cmd.Action); err == nil {
+		cmd.Device = deviceID
+	}
+
+	if err := e.security.ValidateCommand(cmd); err != nil {
+		log.Printf("Command validation failed: %v", err)
+		e.security.LogCommand(cmd, false, err)
+		return err
+	}
+
+	if err := e.router.ExecuteCommand(cmd); err != nil {
+		log.Printf("Command execution failed: %v", err)
+		e.security.LogCommand(cmd, false, err)
+		return err
+	}
+
+	log.Printf("Command executed successfully: %s on %s", cmd.Action, cmd.Device)
+	e.security.LogCommand(cmd, true, nil)
+	return nil
+}
+
+// conditionMet checks a command condition against the previous command's outcome
+func conditionMet(condition string, previousOK bool) bool {
+	switch condition {
+	case ConditionOnSuccess:
+		return previousOK
+	case ConditionOnFailure:
+		return !previousOK
+	default:
+		return true
+	}
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		stop    bool
+		wantErr bool
+	}{
+		{
+			name:  "Single command",
+			input: `{"action":"light.on","device":"phong_khach"}`,
+			want:  []string{"light.on"},
+		},
+		{
+			name:  "Command array",
+			input: `[{"action":"light.off","device":"phong_khach"},{"action":"vacuum.start","device":"robot_hut_bui"}]`,
+			want:  []string{"light.off", "vacuum.start"},
+		},
+		{
+			name:  "Batch object",
+			input: `{"commands":[{"action":"ac.on","device":"dieu_hoa"},{"action":"ac.set_temp","device":"dieu_hoa","value":26,"delay_ms":2000,"condition":"on_success"}],"stop_on_error":true}`,
+			want:  []string{"ac.on", "ac.set_temp"},
+			stop:  true,
+		},
+		{
+			name:  "Array surrounded by text",
+			input: `Vâng, tôi sẽ tắt đèn và bật robot: [{"action":"light.off","device":"bep"},{"action":"vacuum.start","device":"robot_hut_bui"}] Xong!`,
+			want:  []string{"light.off", "vacuum.start"},
+		},
+		{
+			name:  "Bracket in the prose before the command",
+			input: `Được [đèn bếp]: {"action":"light.off","device":"bep"}`,
+			want:  []string{"light.off"},
+		},
+		{
+			name:    "Delay too long",
+			input:   `[{"action":"light.on","device":"bep","delay_ms":3600000}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Negative delay",
+			input:   `{"action":"light.on","device":"bep","delay_ms":-1}`,
+			wantErr: true,
+		},
+		{
+			name:    "Plain text",
+			input:   "Xin chào, tôi có thể giúp gì?",
+			wantErr: true,
+		},
+		{
+			name:    "Command without action",
+			input:   `[{"action":"light.on","device":"bep"},{"device":"bep"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown condition",
+			input:   `[{"action":"light.on","device":"bep","condition":"if_dark"}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := ParseBatch(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(batch.Commands) != len(tt.want) {
+				t.Fatalf("Expected %d commands, got %d", len(tt.want), len(batch.Commands))
+			}
+			for i, action := range tt.want {
+				if batch.Commands[i].Action != action {
+					t.Errorf("Command %d action = %s, want %s", i, batch.Commands[i].Action, action)
+				}
+			}
+			if batch.StopOnError != tt.stop {
+				t.Errorf("StopOnError = %v, want %v", batch.StopOnError, tt.stop)
+			}
+		})
+	}
+}
+
+func TestParseCommandRejectsMultiple(t *testing.T) {
+	_, err := ParseCommand(`[{"action":"light.on","device":"a"},{"action":"light.off","device":"b"}]`)
+	if err == nil {
+		t.Error("Expected error for multiple commands")
+	}
+}
+
+func TestExecutorBatch(t *testing.T) {
+	router := newDryRunRouter(t)
+	executor := NewExecutor(NewSecurityManager(), router)
+
+	var slept time.Duration
+	executor.sleep = func(d time.Duration) { slept += d }
+
+	batch := &Batch{Commands: []*Command{
+		{Action: "light.off", Device: "phong_khach"},
+		{Action: "light.on", Device: "missing"},
+		{Action: "vacuum.start", Device: "robot", Condition: ConditionOnSuccess},
+		{Action: "light.on", Device: "bep", Condition: ConditionOnFailure, DelayMs: 500},
+		{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(26)},
+	}}
+
+	result := executor.Execute(batch)
+
+	if result.Success {
+		t.Error("Expected batch to report failure")
+	}
+
+	want := []struct{ success, skipped bool }{
+		{true, false},
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, false},
+	}
+	for i, w := range want {
+		got := result.Results[i]
+		if got.Success != w.success || got.Skipped != w.skipped {
+			t.Errorf("Result %d = %+v, want success=%v skipped=%v", i, got, w.success, w.skipped)
+		}
+	}
+
+	if slept != 500*time.Millisecond {
+		t.Errorf("Expected 500ms delay, got %v", slept)
+	}
+}
+
+func TestExecutorStopOnError(t *testing.T) {
+	executor := NewExecutor(NewSecurityManager(), newDryRunRouter(t))
+
+	result := executor.Execute(&Batch{
+		StopOnError: true,
+		Commands: []*Command{
+			{Action: "light.on", Device: "missing"},
+			{Action: "light.on", Device: "bep"},
+		},
+	})
+
+	if !result.Results[1].Skipped {
+		t.Errorf("Expected second command to be skipped, got %+v", result.Results[1])
+	}
+}
+
+func TestExecutorRejectsLongDelay(t *testing.T) {
+	executor := NewExecutor(NewSecurityManager(), newDryRunRouter(t))
+	executor.sleep = func(d time.Duration) { t.Errorf("Slept %v for a delay over the maximum", d) }
+
+	result := executor.Execute(&Batch{Commands: []*Command{
+		{Action: "light.on", Device: "bep", DelayMs: MaxDelayMs + 1},
+	}})
+	if result.Success || result.Results[0].Error == "" {
+		t.Errorf("Expected the command to fail, got %+v", result.Results[0])
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CatalogEntry describes a configured device and the actions the router supports for it
+type CatalogEntry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Actions  []string `json:"actions"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Area     string   `json:"area,omitempty"`
+
+	// Temperatures lists the AC temperatures that have an IR code
+	Temperatures []int `json:"temperatures,omitempty"`
+}
+
+// Actions supported per device type, mirroring CommandRouter.Resolve
+var (
+	tapoLightActions    = []string{"light.on", "light.off", "light.brightness", "light.color", "light.color_temp"}
+	mqttLightActions    = []string{"light.on", "light.off", "light.brightness"}
+	tapoSwitchActions   = []string{"switch.on", "switch.off"}
+	mqttSwitchActions   = []string{"switch.on", "switch.off", "switch.toggle"}
+	xiaomiVacuumActions = []string{"vacuum.start", "vacuum.stop", "vacuum.pause", "vacuum.home", "vacuum.spot", "vacuum.fan_speed"}
+)
+
+// DeviceCatalog lists every configured device with the actions it supports, sorted by ID
+func DeviceCatalog(config *Config) []CatalogEntry {
+	entries := make([]CatalogEntry, 0)
+
+	for id, info := range config.Devices.Lights {
+		switch info.Type {
+		case "tapo":
+			entries = append(entries, CatalogEntry{ID: id, Name: info.Name, Category: "light", Actions: tapoLightActions, Aliases: info.Aliases, Area: info.Area})
+		case "mqtt":
+			entries = append(entries, CatalogEntry{ID: id, Name: info.Name, Category: "light", Actions: mqttLightActions, Aliases: info.Aliases, Area: info.Area})
+		}
+	}
+
+	for id, info := range config.Devices.Switches {
+		switch info.Type {
+		case "tapo":
+			entries = append(entries, CatalogEntry{ID: id, Name: info.Name, Category: "switch", Actions: tapoSwitchActions, Aliases: info.Aliases, Area: info.Area})
+		case "mqtt":
+			entries = append(entries, CatalogEntry{ID: id, Name: info.Name, Category: "switch", Actions: mqttSwitchActions, Aliases: info.Aliases, Area: info.Area})
+		}
+	}
+
+	for id, info := range config.Devices.IRDevices {
+		if info.Type != "broadlink" {
+			continue
+		}
+		entry := CatalogEntry{ID: id, Name: info.Name, Category: "tv", Aliases: info.Aliases, Area: info.Area}
+
+		// A remote with power or volume codes is a TV, even if it also has on/off codes
+		for _, key := range []string{"power", "vol_up", "vol_down"} {
+			if info.Commands[key] != "" {
+				entry.Actions = append(entry.Actions, "tv."+key)
+			}
+		}
+
+		if len(entry.Actions) == 0 {
+			entry.Category = "ac"
+			for _, key := range []string{"on", "off"} {
+				if info.Commands[key] != "" {
+					entry.Actions = append(entry.Actions, "ac."+key)
+				}
+			}
+			entry.Temperatures = irTemperatures(info)
+			if len(entry.Temperatures) > 0 {
+				entry.Actions = append(entry.Actions, "ac.set_temp")
+			}
+		}
+
+		if len(entry.Actions) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	for id, info := range config.Devices.Vacuum {
+		if info.Type == "xiaomi" {
+			entries = append(entries, CatalogEntry{ID: id, Name: info.Name, Category: "vacuum", Actions: xiaomiVacuumActions, Aliases: info.Aliases, Area: info.Area})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries
+}
+
+// irTemperatures returns the sorted temperatures that have a temp_<N> IR code
+func irTemperatures(info IRDeviceInfo) []int {
+	temps := make([]int, 0)
+	for key, code := range info.Commands {
+		if !strings.HasPrefix(key, "temp_") || code == "" {
+			continue
+		}
+		if temp, err := strconv.Atoi(strings.TrimPrefix(key, "temp_")); err == nil {
+			temps = append(temps, temp)
+		}
+	}
+	sort.Ints(temps)
+	return temps
+}
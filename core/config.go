@@ -1,23 +1,402 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig loads configuration from a JSON file
+// ConfigFiles lists the default config file names, in the order they are looked for
+var ConfigFiles = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// configDecoders decode each supported file extension into a generic tree
+var configDecoders = map[string]func([]byte) (interface{}, error){
+	".json": decodeJSON,
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".toml": decodeTOML,
+}
+
+// envReference matches ${NAME} and ${NAME:-default}. $${...} is kept as
+// written, without the first $.
+var envReference = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// envName matches environment variable names
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SecretPrefix marks a string value that names a secret, e.g.
+// "secret:tapo_password"
+const SecretPrefix = "secret:"
+
+// SecretStore looks up the secrets a configuration refers to
+type SecretStore interface {
+	Secret(name string) (string, error)
+}
+
+// LoadConfig loads configuration from a JSON, YAML or TOML file, chosen by
+// its extension. Files listed under "include" are merged in, and ${VAR}
+// references in string values are replaced from the environment.
 func LoadConfig(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
+	return LoadConfigWithSecrets(filename, nil)
+}
+
+// LoadConfigWithSecrets loads a configuration like LoadConfig and replaces
+// values of the form "secret:<name>" with the secret from the store
+func LoadConfigWithSecrets(filename string, secrets SecretStore) (*Config, error) {
+	config, _, err := loadConfigFiles(filename, secrets)
+	return config, err
+}
+
+// loadConfigFiles loads a configuration and returns the loader, which
+// lists every file read and include pattern used
+func loadConfigFiles(filename string, secrets SecretStore) (*Config, *configLoader, error) {
+	loader := &configLoader{}
+	tree, err := loader.load(filename)
 	if err != nil {
-		return nil, err
+		return nil, loader, err
 	}
 
+	e := &expander{secrets: secrets}
+	expanded := e.expand(tree, "")
+	if len(e.problems) > 0 {
+		return nil, loader, e.problems
+	}
+
+	// The tree is decoded through JSON so the json tags apply to every format
+	data, err := json.Marshal(coerce(expanded, reflect.TypeOf(Config{})))
+	if err != nil {
+		return nil, loader, err
+	}
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, loader, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return &config, loader, nil
+}
+
+// configLoader reads a config file and the files it includes
+type configLoader struct {
+	files    []string // every file read, in order
+	patterns []string // include patterns, resolved against their file
+	loading  []string // files being loaded, to detect include cycles
+}
+
+// load decodes a file and merges in its includes. Paths in "include" are
+// relative to the file and may be glob patterns, e.g. rooms/*.yaml.
+func (l *configLoader) load(filename string) (map[string]interface{}, error) {
+	for _, file := range l.loading {
+		if file == filename {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(l.loading, filename), " -> "))
+		}
+	}
+	l.files = append(l.files, filename)
+
+	decode, ok := configDecoders[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported config format (expected .json, .yaml, .yml or .toml)", filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
 		return nil, err
 	}
+	value, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	tree, ok := normalize(value).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", filename)
+	}
+
+	includes, err := includeList(tree["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	delete(tree, "include")
+
+	l.loading = append(l.loading, filename)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filename), pattern)
+		}
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			l.patterns = append(l.patterns, pattern)
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("%s: include %q: %w", filename, pattern, err)
+			}
+		}
+
+		for _, match := range matches {
+			included, err := l.load(match)
+			if err != nil {
+				return nil, err
+			}
+			if err := mergeTree(tree, included, ""); err != nil {
+				return nil, fmt.Errorf("%s: %w", match, err)
+			}
+		}
+	}
 
-	return &config, nil
+	return tree, nil
+}
+
+// includeList reads the "include" setting: one path or a list of paths
+func includeList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, len(v))
+		for i, item := range v {
+			path, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include[%d] must be a path", i)
+			}
+			paths[i] = path
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("include must be a path or a list of paths")
+	}
+}
+
+// mergeTree merges an included tree into dst. Mappings are merged key by
+// key; a device or any other value may only be defined once, so two room
+// files can't silently define the same device.
+func mergeTree(dst, src map[string]interface{}, path string) error {
+	for _, key := range sortedKeys(src) {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = src[key]
+			continue
+		}
+		dstMap, dstOK := existing.(map[string]interface{})
+		srcMap, srcOK := src[key].(map[string]interface{})
+		isDevice := strings.HasPrefix(keyPath, "devices.") && strings.Count(keyPath, ".") == 2
+		if !dstOK || !srcOK || isDevice {
+			return fmt.Errorf("%s is already defined", keyPath)
+		}
+		if err := mergeTree(dstMap, srcMap, keyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expander replaces ${VAR} and secret references in a tree, collecting
+// the references it can't resolve
+type expander struct {
+	secrets  SecretStore
+	problems ConfigErrors
+}
+
+// expand replaces the references in every string of a tree. A variable
+// that isn't set is reported at its path unless it has a default.
+func (e *expander) expand(value interface{}, path string) interface{} {
+	switch v := value.(type) {
+	case string:
+		expanded := envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name, fallback, hasDefault := strings.Cut(ref[2:len(ref)-1], ":-")
+			if !envName.MatchString(name) {
+				e.add(path, "invalid environment reference %s", ref)
+				return ref
+			}
+			env, ok := os.LookupEnv(name)
+			switch {
+			case hasDefault && env == "":
+				return fallback
+			case !ok:
+				e.add(path, "environment variable %s is not set", name)
+			}
+			return env
+		})
+		if strings.HasPrefix(expanded, SecretPrefix) {
+			return e.secret(strings.TrimPrefix(expanded, SecretPrefix), path)
+		}
+		if loc := envReference.FindStringIndex(v); loc != nil && loc[0] == 0 && loc[1] == len(v) && !strings.HasPrefix(v, "$$") {
+			return envValue(expanded)
+		}
+		return expanded
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			v[key] = e.expand(v[key], keyPath)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = e.expand(v[i], fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	return value
+}
+
+// envValue is a value that was written as a single ${VAR} reference. It
+// takes the type of the field it is decoded into, so port: ${MQTT_PORT}
+// fills an int, while a token made of digits still fills a string.
+type envValue string
+
+// coerce walks a tree alongside the type it will be decoded into and
+// converts each envValue to a number or bool where the field expects one
+func coerce(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			switch t.Kind() {
+			case reflect.Struct:
+				if field, ok := jsonField(t, key); ok {
+					v[key] = coerce(item, field.Type)
+				}
+			case reflect.Map:
+				v[key] = coerce(item, t.Elem())
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range v {
+				v[i] = coerce(item, t.Elem())
+			}
+		}
+	case envValue:
+		s := strings.TrimSpace(string(v))
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s)
+			}
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		}
+		return string(v)
+	}
+	return value
+}
+
+// jsonField finds the struct field a key decodes into, matching names the
+// way encoding/json does
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// secret looks up a referenced secret
+func (e *expander) secret(name, path string) string {
+	if e.secrets == nil {
+		e.add(path, "secret %s is referenced but no secrets vault is available", name)
+		return ""
+	}
+	value, err := e.secrets.Secret(name)
+	if err != nil {
+		e.add(path, "%v", err)
+	}
+	return value
+}
+
+// add records a reference that can't be resolved
+func (e *expander) add(path, format string, args ...interface{}) {
+	e.problems = append(e.problems, ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// normalize converts YAML mappings with non-string keys, e.g. 18: ..., to
+// string-keyed maps so the tree can be encoded as JSON
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	}
+	return value
+}
+
+// decodeJSON decodes JSON, keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+// decodeYAML decodes YAML
+func decodeYAML(data []byte) (interface{}, error) {
+	var value interface{}
+	err := yaml.Unmarshal(data, &value)
+	return value, err
+}
+
+// decodeTOML decodes TOML
+func decodeTOML(data []byte) (interface{}, error) {
+	var value interface{}
+	err := toml.Unmarshal(data, &value)
+	return value, err
+}
+
+// FindConfig returns the first default config file in dir that exists,
+// or config.json if there is none
+func FindConfig(dir string) string {
+	for _, name := range ConfigFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, ConfigFiles[0])
 }
 
 // SaveConfig saves configuration to a JSON file
@@ -0,0 +1,319 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files under a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{
+  "devices": {
+    "lights": {"bep": {"type": "mqtt", "topic": "home/kitchen/light", "name": "Đèn Bếp", "aliases": ["kitchen"]}},
+    "ir_devices": {"tv": {"type": "broadlink", "device_ip": "192.168.1.30", "commands": {"power": "2600cc"}}}
+  },
+  "claude": {"max_tokens": 1024, "temperature": 0.7},
+  "mqtt": {"host": "broker.local", "port": 1884}
+}`,
+		"config.yaml": `
+devices:
+  lights:
+    bep:
+      type: mqtt
+      topic: home/kitchen/light
+      name: Đèn Bếp
+      aliases: [kitchen]
+  ir_devices:
+    tv:
+      type: broadlink
+      device_ip: 192.168.1.30
+      commands:
+        power: "2600cc"
+claude:
+  max_tokens: 1024
+  temperature: 0.7
+mqtt:
+  host: broker.local
+  port: 1884
+`,
+		"config.toml": `
+[devices.lights.bep]
+type = "mqtt"
+topic = "home/kitchen/light"
+name = "Đèn Bếp"
+aliases = ["kitchen"]
+
+[devices.ir_devices.tv]
+type = "broadlink"
+device_ip = "192.168.1.30"
+commands = { power = "2600cc" }
+
+[claude]
+max_tokens = 1024
+temperature = 0.7
+
+[mqtt]
+host = "broker.local"
+port = 1884
+`,
+	})
+
+	want, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig(json) error = %v", err)
+	}
+	if want.MQTT.Port != 1884 || want.Devices.IRDevices["tv"].Commands["power"] != "2600cc" {
+		t.Fatalf("LoadConfig(json) = %+v", want)
+	}
+
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		config, err := LoadConfig(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("LoadConfig(%s) error = %v", name, err)
+		}
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("LoadConfig(%s) = %+v\nwant %+v", name, config, want)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "config.ini")); err == nil || !strings.Contains(err.Error(), "unsupported config format") {
+		t.Errorf("LoadConfig(ini) error = %v", err)
+	}
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+include:
+  - rooms/*.yaml
+  - mqtt.toml
+claude:
+  model: claude-3-5-sonnet-20241022
+`,
+		"rooms/bep.yaml": `
+devices:
+  lights:
+    bep: {type: mqtt, topic: home/kitchen/light}
+`,
+		"rooms/phong_khach.yaml": `
+include: ../ir.json
+devices:
+  lights:
+    phong_khach: {type: tapo, ip: 192.168.1.10}
+`,
+		"ir.json":   `{"devices": {"ir_devices": {"tv": {"type": "broadlink", "device_ip": "192.168.1.30"}}}}`,
+		"mqtt.toml": `mqtt = { host = "broker.local" }`,
+	})
+
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(config.Devices.Lights) != 2 || config.Devices.IRDevices["tv"].DeviceIP != "192.168.1.30" {
+		t.Errorf("Devices = %+v", config.Devices)
+	}
+	if config.MQTT.Host != "broker.local" || config.Claude.Model == "" {
+		t.Errorf("Included settings missing: mqtt %+v, claude %+v", config.MQTT, config.Claude)
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"duplicate device", map[string]string{
+			"config.yaml": "include: [a.yaml, b.yaml]",
+			"a.yaml":      "devices: {lights: {bep: {type: mqtt}}}",
+			"b.yaml":      "devices: {lights: {bep: {type: tapo}}}",
+		}, "b.yaml: devices.lights.bep is already defined"},
+		{"cycle", map[string]string{
+			"config.yaml": "include: a.yaml",
+			"a.yaml":      "include: config.yaml",
+		}, "include cycle"},
+		{"missing file", map[string]string{
+			"config.yaml": "include: rooms.yaml",
+		}, "no such file"},
+		{"not a path", map[string]string{
+			"config.yaml": "include: [1]",
+		}, "include[0] must be a path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigExpandsEnv(t *testing.T) {
+	t.Setenv("TEST_XIAOMI_TOKEN", "00112233445566778899aabbccddeeff")
+	t.Setenv("TEST_MQTT_HOST", "broker.local")
+	t.Setenv("TEST_EMPTY", "")
+
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+devices:
+  vacuum:
+    robot: {type: xiaomi, ip: 192.168.1.40, token: "${TEST_XIAOMI_TOKEN}"}
+mqtt:
+  host: ${TEST_MQTT_HOST}
+  username: ${TEST_EMPTY}
+  password: ${TEST_UNSET:-guest}
+  client_id: jarvis-$${HOST}
+`,
+		"unset.yaml": `
+mqtt:
+  host: ${TEST_UNSET}
+claude:
+  system_prompt: "${1BAD}"
+`,
+	})
+
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	want := MQTTConfig{Host: "broker.local", Password: "guest", ClientID: "jarvis-${HOST}"}
+	if config.MQTT != want {
+		t.Errorf("MQTT = %+v, want %+v", config.MQTT, want)
+	}
+	if config.Devices.Vacuum["robot"].Token != "00112233445566778899aabbccddeeff" {
+		t.Errorf("Token = %q", config.Devices.Vacuum["robot"].Token)
+	}
+
+	_, err = LoadConfig(filepath.Join(dir, "unset.yaml"))
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 2 {
+		t.Fatalf("LoadConfig() error = %v, want 2 problems", err)
+	}
+	if got := problems[0].Error(); got != "claude.system_prompt: invalid environment reference ${1BAD}" {
+		t.Errorf("problems[0] = %s", got)
+	}
+	if got := problems[1].Error(); got != "mqtt.host: environment variable TEST_UNSET is not set" {
+		t.Errorf("problems[1] = %s", got)
+	}
+}
+
+func TestLoadConfigTypedEnv(t *testing.T) {
+	t.Setenv("TEST_MQTT_PORT", "1884")
+	t.Setenv("TEST_HEALTH", "true")
+	t.Setenv("TEST_DIGITS", "12345")
+
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+mqtt:
+  port: ${TEST_MQTT_PORT}
+  password: ${TEST_DIGITS}
+health:
+  enabled: ${TEST_HEALTH}
+  interval_seconds: ${TEST_UNSET:-45}
+`,
+		"config.json": `{
+  "mqtt": {"port": "${TEST_MQTT_PORT}", "password": "${TEST_DIGITS}"},
+  "health": {"enabled": "${TEST_HEALTH}", "interval_seconds": "${TEST_UNSET:-45}"}
+}`,
+		"mixed.json": `{"mqtt": {"port": "1${TEST_MQTT_PORT}"}}`,
+	})
+
+	for _, name := range []string{"config.yaml", "config.json"} {
+		config, err := LoadConfig(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: LoadConfig() error = %v", name, err)
+		}
+		if config.MQTT.Port != 1884 || config.MQTT.Password != "12345" {
+			t.Errorf("%s: MQTT = %+v", name, config.MQTT)
+		}
+		if !config.Health.Enabled || config.Health.IntervalSeconds != 45 {
+			t.Errorf("%s: Health = %+v", name, config.Health)
+		}
+	}
+
+	// Only a value that is a single reference takes the field's type
+	if _, err := LoadConfig(filepath.Join(dir, "mixed.json")); err == nil {
+		t.Error("Expected a string with text around a reference to stay a string")
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.toml": ""})
+	if got := FindConfig(dir); got != filepath.Join(dir, "config.toml") {
+		t.Errorf("FindConfig() = %s", got)
+	}
+	if got := FindConfig(t.TempDir()); filepath.Base(got) != "config.json" {
+		t.Errorf("FindConfig() of an empty directory = %s", got)
+	}
+}
+
+// fakeSecrets is a SecretStore backed by a map
+type fakeSecrets map[string]string
+
+func (s fakeSecrets) Secret(name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", errors.New("secret not found: " + name)
+	}
+	return value, nil
+}
+
+func TestLoadConfigResolvesSecrets(t *testing.T) {
+	t.Setenv("TEST_TOKEN_SECRET", "robot_token")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+devices:
+  vacuum:
+    robot: {type: xiaomi, ip: 192.168.1.40, token: "secret:${TEST_TOKEN_SECRET}"}
+claude:
+  api_key: secret:claude
+tapo:
+  email: me@example.com
+  password: secret:tapo
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+	store := fakeSecrets{"robot_token": "00112233445566778899aabbccddeeff", "claude": "sk-123"}
+
+	_, err := LoadConfigWithSecrets(path, store)
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Error() != "tapo.password: secret not found: tapo" {
+		t.Fatalf("LoadConfigWithSecrets() error = %v", err)
+	}
+
+	store["tapo"] = "s3cret"
+	config, err := LoadConfigWithSecrets(path, store)
+	if err != nil {
+		t.Fatalf("LoadConfigWithSecrets() error = %v", err)
+	}
+	if config.Devices.Vacuum["robot"].Token != store["robot_token"] || config.Claude.APIKey != "sk-123" || config.Tapo.Password != "s3cret" {
+		t.Errorf("Secrets not resolved: %+v %+v %+v", config.Devices.Vacuum, config.Claude, config.Tapo)
+	}
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "no secrets vault") {
+		t.Errorf("LoadConfig() without a store error = %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfirmPolicy lists the commands that need a spoken "yes" before they run
+type ConfirmPolicy struct {
+	// Actions always need confirmation, e.g. "switch.off" for the fridge plug
+	Actions []string
+
+	// MinDevicesOff confirms batches turning off at least this many devices
+	MinDevicesOff int
+
+	// NightMinTemp confirms AC temperatures below it between NightStart and
+	// NightEnd (hours of the day)
+	NightStart   int
+	NightEnd     int
+	NightMinTemp float64
+}
+
+// offActions turn a device off
+var offActions = map[string]bool{
+	"light.off":  true,
+	"switch.off": true,
+	"ac.off":     true,
+}
+
+// reason returns why a batch needs confirmation, or "" if it doesn't. The
+// reason completes the question "Bạn có chắc muốn ... không?".
+func (p ConfirmPolicy) reason(batch *Batch, now time.Time) string {
+	off := 0
+	for _, cmd := range batch.Commands {
+		if offActions[cmd.Action] {
+			off++
+		}
+	}
+	if p.MinDevicesOff > 0 && off >= p.MinDevicesOff {
+		return fmt.Sprintf("tắt %d thiết bị", off)
+	}
+
+	for _, cmd := range batch.Commands {
+		if containsString(p.Actions, cmd.Action) {
+			return fmt.Sprintf("thực hiện %s trên %s", cmd.Action, cmd.Device)
+		}
+		if cmd.Action == "ac.set_temp" && p.isNight(now) {
+			if temp, ok := cmd.Value.(float64); ok && temp < p.NightMinTemp {
+				return fmt.Sprintf("đặt %s %g°C vào ban đêm", cmd.Device, temp)
+			}
+		}
+	}
+	return ""
+}
+
+// isNight reports whether the time falls in the night hours of the policy
+func (p ConfirmPolicy) isNight(now time.Time) bool {
+	if p.NightMinTemp <= 0 || p.NightStart == p.NightEnd {
+		return false
+	}
+	hour := now.Hour()
+	if p.NightStart < p.NightEnd {
+		return hour >= p.NightStart && hour < p.NightEnd
+	}
+	return hour >= p.NightStart || hour < p.NightEnd
+}
+
+// Answer is how a reply relates to a pending action
+type Answer int
+
+const (
+	AnswerUnrelated Answer = iota // not an answer, handle it as a new request
+	AnswerYes                     // confirmed, or a device was picked
+	AnswerNo                      // cancelled
+)
+
+// Confirmation words without diacritics, matched at the start of a reply.
+// "đúng" is left out because it folds to the same word as "dừng".
+var (
+	yesPhrases = []string{"co", "u", "vang", "da", "dong y", "chac chan", "xac nhan", "lam di", "duoc", "ok", "okay", "yes", "yeah", "yep", "sure", "confirm"}
+	noPhrases  = []string{"khong", "thoi", "huy", "dung lam", "no", "nope", "cancel", "never mind"}
+)
+
+// maxAnswerWords is the longest reply taken as a plain yes or no
+const maxAnswerWords = 5
+
+// PendingAction is a batch held back until the user confirms it or picks
+// which device was meant
+type PendingAction struct {
+	Batch *Batch `json:"batch"`
+
+	// Reason is set when the batch needs confirmation, Ambiguous when the
+	// device of one of its commands must be chosen
+	Reason    string                `json:"reason,omitempty"`
+	Ambiguous *AmbiguousDeviceError `json:"ambiguous,omitempty"`
+}
+
+// Question asks the user to confirm or choose
+func (p *PendingAction) Question() string {
+	if p.Ambiguous != nil {
+		return p.Ambiguous.Question()
+	}
+	return fmt.Sprintf("Bạn có chắc muốn %s không?", p.Reason)
+}
+
+// status describes the pending action in command results
+func (p *PendingAction) status() string {
+	if p.Ambiguous != nil {
+		return p.Ambiguous.Error()
+	}
+	return "awaiting confirmation, ask the user: " + p.Question()
+}
+
+// Answer interprets the user's reply. A yes or no must start the reply, so
+// "có, không sao" confirms and "không, đừng" cancels. When a device is
+// picked, the pending command is updated to use it.
+func (p *PendingAction) Answer(text string) Answer {
+	tokens := tokenize(text)
+
+	if p.Ambiguous != nil {
+		if id := p.Ambiguous.choose(tokens); id != "" {
+			p.Ambiguous.Command.Device = id
+			return AnswerYes
+		}
+	}
+
+	if len(tokens) == 0 || len(tokens) > maxAnswerWords {
+		return AnswerUnrelated
+	}
+	for _, phrase := range noPhrases {
+		if startsWithPhrase(tokens, strings.Fields(phrase)) {
+			return AnswerNo
+		}
+	}
+	if p.Ambiguous == nil {
+		for _, phrase := range yesPhrases {
+			if startsWithPhrase(tokens, strings.Fields(phrase)) {
+				return AnswerYes
+			}
+		}
+	}
+	return AnswerUnrelated
+}
+
+// startsWithPhrase reports whether the tokens start with a phrase
+func startsWithPhrase(tokens, phrase []string) bool {
+	return len(tokens) >= len(phrase) && equalTokens(tokens[:len(phrase)], phrase)
+}
+
+// choose returns the candidate a reply such as "phòng ngủ" refers to. Only
+// words that tell the candidates apart count.
+func (e *AmbiguousDeviceError) choose(tokens []string) string {
+	if e.Command == nil {
+		return ""
+	}
+
+	words := make([]map[string]bool, len(e.Candidates))
+	count := make(map[string]int)
+	for i, entry := range e.Candidates {
+		words[i] = make(map[string]bool)
+		names := append([]string{strings.ReplaceAll(entry.ID, "_", " "), entry.Name, entry.Area}, entry.Aliases...)
+		for _, name := range names {
+			for _, word := range tokenize(name) {
+				if !words[i][word] {
+					words[i][word] = true
+					count[word]++
+				}
+			}
+		}
+	}
+
+	best, choice := 0, ""
+	for i, entry := range e.Candidates {
+		score := 0
+		for _, token := range tokens {
+			if words[i][token] && count[token] < len(e.Candidates) {
+				score++
+			}
+		}
+		switch {
+		case score > best:
+			best, choice = score, entry.ID
+		case score == best:
+			choice = ""
+		}
+	}
+	return choice
+}
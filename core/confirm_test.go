@@ -0,0 +1,175 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfirmPolicy(t *testing.T) {
+	policy := ConfirmPolicy{
+		Actions:       []string{"vacuum.start"},
+		MinDevicesOff: 3,
+		NightStart:    22,
+		NightEnd:      6,
+		NightMinTemp:  20,
+	}
+	night := time.Date(2026, 1, 1, 23, 30, 0, 0, time.Local)
+	morning := time.Date(2026, 1, 1, 6, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		commands []*Command
+		now      time.Time
+		want     string
+	}{
+		{"single light", []*Command{{Action: "light.off", Device: "bep"}}, night, ""},
+		{"everything off", []*Command{
+			{Action: "light.off", Device: "bep"},
+			{Action: "light.off", Device: "phong_khach"},
+			{Action: "ac.off", Device: "dieu_hoa"},
+		}, morning, "tắt 3 thiết bị"},
+		{"sensitive action", []*Command{{Action: "vacuum.start", Device: "robot"}}, morning, "thực hiện vacuum.start trên robot"},
+		{"cold at night", []*Command{{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(16)}}, night, "đặt dieu_hoa 16°C vào ban đêm"},
+		{"early morning", []*Command{{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(16)}}, morning.Add(-time.Minute), "đặt dieu_hoa 16°C vào ban đêm"},
+		{"cold in the day", []*Command{{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(16)}}, morning, ""},
+		{"warm at night", []*Command{{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(26)}}, night, ""},
+	}
+
+	for _, tt := range tests {
+		if got := policy.reason(&Batch{Commands: tt.commands}, tt.now); got != tt.want {
+			t.Errorf("%s: reason() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := (ConfirmPolicy{}).reason(&Batch{Commands: tests[1].commands}, night); got != "" {
+		t.Errorf("Empty policy reason() = %q", got)
+	}
+}
+
+func TestPendingActionAnswer(t *testing.T) {
+	pending := &PendingAction{Reason: "tắt 3 thiết bị"}
+	if question := pending.Question(); question != "Bạn có chắc muốn tắt 3 thiết bị không?" {
+		t.Errorf("Question() = %q", question)
+	}
+
+	tests := []struct {
+		text string
+		want Answer
+	}{
+		{"Có", AnswerYes},
+		{"ừ, làm đi", AnswerYes},
+		{"yes please", AnswerYes},
+		{"không", AnswerNo},
+		{"thôi khỏi", AnswerNo},
+		{"cancel", AnswerNo},
+		{"có, không sao", AnswerYes},
+		{"ok không vấn đề", AnswerYes},
+		{"yes, no problem", AnswerYes},
+		{"không, có việc khác", AnswerNo},
+		{"no, not yet okay", AnswerNo},
+		{"tôi nghĩ là có", AnswerUnrelated},
+		{"mấy giờ rồi", AnswerUnrelated},
+		{"có ai đang ở trong phòng khách không nhỉ", AnswerUnrelated},
+		{"", AnswerUnrelated},
+	}
+	for _, tt := range tests {
+		if got := pending.Answer(tt.text); got != tt.want {
+			t.Errorf("Answer(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPendingActionChooseDevice(t *testing.T) {
+	config := newTestConfig(t)
+	light := config.Devices.Lights["phong_ngu"]
+	light.Aliases = []string{"bedroom"}
+	config.Devices.Lights["phong_ngu"] = light
+
+	var lights []CatalogEntry
+	for _, entry := range DeviceCatalog(config) {
+		if entry.Category == "light" {
+			lights = append(lights, entry)
+		}
+	}
+
+	newPending := func() *PendingAction {
+		cmd := &Command{Action: "light.on", Device: "den"}
+		return &PendingAction{
+			Batch: &Batch{Commands: []*Command{cmd}},
+			Ambiguous: &AmbiguousDeviceError{
+				Reference:  "den",
+				Candidates: lights,
+				Command:    cmd,
+			},
+		}
+	}
+
+	tests := []struct {
+		text   string
+		want   Answer
+		device string
+	}{
+		{"phòng ngủ", AnswerYes, "phong_ngu"},
+		{"the bedroom one", AnswerYes, "phong_ngu"},
+		{"cái ở bếp", AnswerYes, "bep"},
+		{"đèn phòng khách", AnswerYes, "phong_khach"},
+		{"phòng", AnswerUnrelated, "den"}, // fits two of them
+		{"có", AnswerUnrelated, "den"},
+		{"không", AnswerNo, "den"},
+	}
+	for _, tt := range tests {
+		pending := newPending()
+		if got := pending.Answer(tt.text); got != tt.want {
+			t.Errorf("Answer(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+		if device := pending.Batch.Commands[0].Device; device != tt.device {
+			t.Errorf("Answer(%q) device = %s, want %s", tt.text, device, tt.device)
+		}
+	}
+}
+
+func TestExecutorHoldsForConfirmation(t *testing.T) {
+	security := NewSecurityManager()
+	security.SetConfirmPolicy(ConfirmPolicy{MinDevicesOff: 2})
+	executor := NewExecutor(security, newDryRunRouter(t))
+
+	result := executor.Execute(&Batch{Commands: []*Command{
+		{Action: "light.off", Device: "phong_khach"},
+		{Action: "light.off", Device: "Đèn Bếp"},
+	}})
+	if result.Pending == nil || result.Pending.Reason != "tắt 2 thiết bị" {
+		t.Fatalf("Expected a pending confirmation, got %+v", result)
+	}
+	if result.Success || len(security.GetCommandLog(0)) != 0 {
+		t.Error("Held batch must not run")
+	}
+	if result.Results[1].Command.Device != "bep" {
+		t.Errorf("Held command device = %s, want the canonical ID", result.Results[1].Command.Device)
+	}
+
+	resumed := executor.Resume(result.Pending)
+	if !resumed.Success || resumed.Pending != nil || len(security.GetCommandLog(0)) != 2 {
+		t.Errorf("Resume() = %+v", resumed)
+	}
+}
+
+func TestExecutorHoldsAmbiguousDevice(t *testing.T) {
+	security := NewSecurityManager()
+	executor := NewExecutor(security, newDryRunRouter(t))
+
+	result := executor.Execute(&Batch{Commands: []*Command{{Action: "light.on", Device: "den"}}})
+	if result.Pending == nil || result.Pending.Ambiguous == nil {
+		t.Fatalf("Expected a pending device choice, got %+v", result)
+	}
+	if question := result.Pending.Question(); question != "Bạn muốn nói Đèn Bếp, Đèn Phòng Khách hay Đèn Phòng Ngủ?" {
+		t.Errorf("Question() = %q", question)
+	}
+
+	if answer := result.Pending.Answer("đèn bếp"); answer != AnswerYes {
+		t.Fatalf("Answer() = %v", answer)
+	}
+	resumed := executor.Resume(result.Pending)
+	if !resumed.Success || resumed.Results[0].Command.Device != "bep" {
+		t.Errorf("Resume() = %s", resumed.Summary())
+	}
+}
@@ -0,0 +1,571 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/devices"
+)
+
+// Default health check settings
+const (
+	DefaultHealthInterval = 60 * time.Second
+	DefaultHealthTimeout  = 5 * time.Second
+)
+
+// availabilityWait is how long CheckOnce waits for retained MQTT
+// availability messages
+const availabilityWait = 2 * time.Second
+
+// HealthStatus describes the last known availability of a device
+type HealthStatus struct {
+	DeviceID    string    `json:"device_id"`
+	Name        string    `json:"name"`
+	Protocol    string    `json:"protocol"`
+	Online      bool      `json:"online"`
+	Checked     bool      `json:"checked"`
+	LatencyMs   int64     `json:"latency_ms"`
+	LastChecked time.Time `json:"last_checked"`
+	LastSeen    time.Time `json:"last_seen"`
+	Since       time.Time `json:"since"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// HealthEvent is emitted when a device goes online or offline
+type HealthEvent struct {
+	DeviceID  string
+	Online    bool
+	Timestamp time.Time
+	Status    HealthStatus
+}
+
+// probeFunc checks that a device is reachable, giving up when ctx is done
+type probeFunc func(ctx context.Context) error
+
+// errAvailabilityUnknown is returned by probes that have nothing to go on yet,
+// e.g. an MQTT device that never sent an availability message
+var errAvailabilityUnknown = errors.New("no availability message received")
+
+// healthTarget is a device the monitor knows how to probe
+type healthTarget struct {
+	id       string
+	name     string
+	protocol string
+	probe    probeFunc
+}
+
+// HealthMonitor periodically probes devices and tracks their availability
+type HealthMonitor struct {
+	router    *CommandRouter
+	interval  time.Duration
+	timeout   time.Duration
+	status    map[string]*HealthStatus
+	events    chan HealthEvent
+	mqttState map[string]bool
+	topics    map[string]string // availability topic subscribed per MQTT device
+	stopChan  chan struct{}
+	isRunning bool
+	mu        sync.RWMutex
+}
+
+// NewHealthMonitor creates a new health monitor for the router's devices
+func NewHealthMonitor(router *CommandRouter, config HealthConfig) *HealthMonitor {
+	interval := time.Duration(config.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+
+	return &HealthMonitor{
+		router:    router,
+		interval:  interval,
+		timeout:   timeout,
+		status:    make(map[string]*HealthStatus),
+		events:    make(chan HealthEvent, 32),
+		mqttState: make(map[string]bool),
+		topics:    make(map[string]string),
+	}
+}
+
+// Start begins periodic health checks in the background
+func (m *HealthMonitor) Start() error {
+	m.mu.Lock()
+	if m.isRunning {
+		m.mu.Unlock()
+		return fmt.Errorf("health monitor is already running")
+	}
+	m.isRunning = true
+	stop := make(chan struct{})
+	m.stopChan = stop
+	m.mu.Unlock()
+
+	m.subscribeAvailability()
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		m.CheckAll()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckAll()
+			}
+		}
+	}()
+
+	log.Printf("Health monitor started (interval: %v, timeout: %v)", m.interval, m.timeout)
+	return nil
+}
+
+// Stop stops the background health checks
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isRunning {
+		return
+	}
+
+	close(m.stopChan)
+	m.isRunning = false
+	log.Println("Health monitor stopped")
+}
+
+// Events returns the channel of online/offline transitions
+func (m *HealthMonitor) Events() <-chan HealthEvent {
+	return m.events
+}
+
+// CheckOnce subscribes to MQTT availability, waits up to
+// availabilityWait for the retained messages, then probes every device.
+// It is meant for one-off checks that don't Start the monitor.
+func (m *HealthMonitor) CheckOnce() {
+	m.subscribeAvailability()
+	m.waitForAvailability(availabilityWait)
+	m.CheckAll()
+}
+
+// waitForAvailability waits until every subscribed MQTT device has sent an
+// availability message, or the timeout expires
+func (m *HealthMonitor) waitForAvailability(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && !m.availabilityReceived() {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// availabilityReceived reports whether every subscribed MQTT device has
+// sent an availability message
+func (m *HealthMonitor) availabilityReceived() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for id := range m.topics {
+		if _, ok := m.mqttState[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckAll probes every device concurrently and waits for the results
+func (m *HealthMonitor) CheckAll() {
+	targets := m.targets()
+
+	// Forget devices removed from the configuration
+	m.mu.Lock()
+	for id := range m.status {
+		found := false
+		for _, target := range targets {
+			found = found || target.id == id
+		}
+		if !found {
+			delete(m.status, id)
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target healthTarget) {
+			defer wg.Done()
+			m.check(target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// GetStatus returns the status of a single device
+func (m *HealthMonitor) GetStatus(deviceID string) (HealthStatus, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status, ok := m.status[deviceID]
+	if !ok {
+		return HealthStatus{}, false
+	}
+	return *status, true
+}
+
+// GetAllStatus returns the status of all devices sorted by device ID
+func (m *HealthMonitor) GetAllStatus() []HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]HealthStatus, 0, len(m.status))
+	for _, status := range m.status {
+		result = append(result, *status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeviceID < result[j].DeviceID
+	})
+
+	return result
+}
+
+// Summary returns a short human-readable availability report
+func (m *HealthMonitor) Summary() string {
+	var sb strings.Builder
+	sb.WriteString("Device status:\n")
+
+	for _, status := range m.GetAllStatus() {
+		state := "offline"
+		if !status.Checked {
+			state = "unknown"
+		} else if status.Online {
+			state = "online"
+		}
+
+		fmt.Fprintf(&sb, "- %s (%s): %s", status.DeviceID, status.Name, state)
+		if status.Online && status.LatencyMs > 0 {
+			fmt.Fprintf(&sb, ", %dms", status.LatencyMs)
+		}
+		if !status.Online && !status.LastSeen.IsZero() {
+			fmt.Fprintf(&sb, ", last seen %s", status.LastSeen.Format("15:04:05"))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// check probes a single device and records the result
+func (m *HealthMonitor) check(target healthTarget) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	// The probe stops when ctx is done, so its goroutine doesn't outlive the check
+	result := make(chan error, 1)
+	go func() {
+		result <- target.probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("probe timed out after %v", m.timeout)
+	}
+
+	latency := time.Since(start)
+	if errors.Is(err, errAvailabilityUnknown) {
+		m.recordUnknown(target, err)
+		return
+	}
+
+	// A Tapo error code means the device answered, just not successfully
+	online := err == nil
+	var tapoErr *devices.TapoError
+	if errors.As(err, &tapoErr) {
+		online = true
+	}
+
+	m.record(target, online, latency, err)
+}
+
+// record updates a device status and emits an event on transitions
+func (m *HealthMonitor) record(target healthTarget, online bool, latency time.Duration, err error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	status, ok := m.status[target.id]
+	if !ok {
+		status = &HealthStatus{DeviceID: target.id}
+		m.status[target.id] = status
+	}
+	status.Name = target.name
+	status.Protocol = target.protocol
+
+	changed := !status.Checked || status.Online != online
+	if changed {
+		status.Since = now
+	}
+
+	status.Checked = true
+	status.Online = online
+	status.LastChecked = now
+	status.LatencyMs = latency.Milliseconds()
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if online {
+		status.LastSeen = now
+	}
+	snapshot := *status
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if online {
+		log.Printf("[HEALTH] %s (%s) is online (%v)", target.id, target.protocol, latency.Round(time.Millisecond))
+	} else {
+		log.Printf("[HEALTH] %s (%s) is offline: %v", target.id, target.protocol, err)
+	}
+
+	select {
+	case m.events <- HealthEvent{DeviceID: target.id, Online: online, Timestamp: now, Status: snapshot}:
+	default:
+		log.Println("Warning: Health event channel is full, dropping event")
+	}
+}
+
+// recordUnknown notes a check that couldn't tell whether the device is
+// online. The device stays unknown rather than being reported online.
+func (m *HealthMonitor) recordUnknown(target healthTarget, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok := m.status[target.id]
+	if !ok {
+		status = &HealthStatus{DeviceID: target.id}
+		m.status[target.id] = status
+	}
+	status.Name = target.name
+	status.Protocol = target.protocol
+	status.Checked = false
+	status.Online = false
+	status.LastChecked = time.Now()
+	status.LatencyMs = 0
+	status.Error = err.Error()
+}
+
+// Reload follows a reload of the router's configuration: availability
+// topics of added and changed MQTT devices are subscribed, removed devices
+// are forgotten, and every device is checked again if the monitor runs.
+func (m *HealthMonitor) Reload() {
+	m.subscribeAvailability()
+
+	targets := make(map[string]bool)
+	for _, target := range m.targets() {
+		targets[target.id] = true
+	}
+
+	m.mu.Lock()
+	for id := range m.status {
+		if !targets[id] {
+			delete(m.status, id)
+		}
+	}
+	running := m.isRunning
+	m.mu.Unlock()
+
+	if running {
+		go m.CheckAll()
+	}
+}
+
+// subscribeAvailability listens to the MQTT availability topics of the
+// configured devices, dropping the subscriptions of removed or changed ones
+func (m *HealthMonitor) subscribeAvailability() {
+	topics := make(map[string]string)
+	m.router.mu.RLock()
+	for _, section := range []map[string]DeviceInfo{m.router.config.Devices.Lights, m.router.config.Devices.Switches} {
+		for id, info := range section {
+			if info.Type == "mqtt" {
+				topics[id] = availabilityTopic(info)
+			}
+		}
+	}
+	m.router.mu.RUnlock()
+
+	client := m.router.mqttClient
+	connected := client != nil && client.IsConnected()
+
+	// The client isn't called with the lock held, since its handlers take it
+	var stale, added []string
+	m.mu.Lock()
+	for id, topic := range m.topics {
+		if topics[id] != topic {
+			stale = append(stale, topic)
+			delete(m.topics, id)
+			delete(m.mqttState, id)
+		}
+	}
+	if connected {
+		for id, topic := range topics {
+			if _, ok := m.topics[id]; !ok {
+				m.topics[id] = topic
+				added = append(added, id)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if !connected {
+		return
+	}
+	for _, topic := range stale {
+		if err := client.Unsubscribe(topic); err != nil {
+			log.Printf("Warning: Failed to unsubscribe from %s: %v", topic, err)
+		}
+	}
+	for _, id := range added {
+		topic := topics[id]
+		err := client.SubscribeAvailability(topic, func(online bool) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			// Ignore messages still arriving after the topic changed
+			if m.topics[id] == topic {
+				m.mqttState[id] = online
+			}
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to subscribe to availability of %s: %v", id, err)
+			m.mu.Lock()
+			delete(m.topics, id)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// probeMQTT reports the device's last availability message. Without one
+// the device's state is unknown, even if the broker is connected.
+func (m *HealthMonitor) probeMQTT(id string) probeFunc {
+	return func(ctx context.Context) error {
+		client := m.router.mqttClient
+		if client == nil || !client.IsConnected() {
+			return fmt.Errorf("MQTT broker not connected")
+		}
+
+		m.mu.RLock()
+		online, known := m.mqttState[id]
+		m.mu.RUnlock()
+
+		switch {
+		case !known:
+			return errAvailabilityUnknown
+		case !online:
+			return fmt.Errorf("device reported offline")
+		}
+		return nil
+	}
+}
+
+// probeTimeout returns the time left before ctx's deadline, for probes
+// that take a timeout
+func probeTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return DefaultHealthTimeout
+	}
+	return time.Until(deadline)
+}
+
+// targets lists the probes for every configured device
+func (m *HealthMonitor) targets() []healthTarget {
+	r := m.router
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	targets := make([]healthTarget, 0)
+
+	addDeviceInfo := func(id string, info DeviceInfo) {
+		switch info.Type {
+		case "tapo":
+			device, ok := r.tapoDevices[id]
+			if !ok {
+				return
+			}
+			targets = append(targets, healthTarget{
+				id:       id,
+				name:     info.Name,
+				protocol: "tapo",
+				probe: func(ctx context.Context) error {
+					_, err := device.GetDeviceInfoContext(ctx)
+					return err
+				},
+			})
+		case "mqtt":
+			targets = append(targets, healthTarget{
+				id:       id,
+				name:     info.Name,
+				protocol: "mqtt",
+				probe:    m.probeMQTT(id),
+			})
+		case "xiaomi":
+			targets = append(targets, healthTarget{
+				id:       id,
+				name:     info.Name,
+				protocol: "miio",
+				probe: func(ctx context.Context) error {
+					_, err := devices.MiioHello(info.IP, probeTimeout(ctx))
+					return err
+				},
+			})
+		}
+	}
+
+	for id, info := range r.config.Devices.Lights {
+		addDeviceInfo(id, info)
+	}
+	for id, info := range r.config.Devices.Switches {
+		addDeviceInfo(id, info)
+	}
+	for id, info := range r.config.Devices.Vacuum {
+		addDeviceInfo(id, info)
+	}
+
+	for id, info := range r.config.Devices.IRDevices {
+		device, ok := r.broadlink[id]
+		if !ok {
+			continue
+		}
+		targets = append(targets, healthTarget{
+			id:       id,
+			name:     info.Name,
+			protocol: "broadlink",
+			probe: func(ctx context.Context) error {
+				return device.Hello(probeTimeout(ctx))
+			},
+		})
+	}
+
+	return targets
+}
+
+// availabilityTopic returns the availability topic of an MQTT device
+func availabilityTopic(info DeviceInfo) string {
+	if info.AvailabilityTopic != "" {
+		return info.AvailabilityTopic
+	}
+	return info.Topic + "/availability"
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthMonitorTransitions(t *testing.T) {
+	router := NewCommandRouter(&Config{})
+	monitor := NewHealthMonitor(router, HealthConfig{TimeoutSeconds: 1})
+
+	online := true
+	target := healthTarget{
+		id:       "phong_khach",
+		name:     "Đèn Phòng Khách",
+		protocol: "tapo",
+		probe: func(ctx context.Context) error {
+			if online {
+				return nil
+			}
+			return fmt.Errorf("connection refused")
+		},
+	}
+
+	monitor.check(target)
+	monitor.check(target)
+	online = false
+	monitor.check(target)
+
+	var events []HealthEvent
+	for len(monitor.Events()) > 0 {
+		events = append(events, <-monitor.Events())
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 transition events, got %d", len(events))
+	}
+	if !events[0].Online || events[1].Online {
+		t.Errorf("Expected online then offline, got %v then %v", events[0].Online, events[1].Online)
+	}
+
+	status, ok := monitor.GetStatus("phong_khach")
+	if !ok {
+		t.Fatal("Expected status for phong_khach")
+	}
+	if status.Online || status.Error != "connection refused" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if status.LastSeen.IsZero() {
+		t.Error("Expected LastSeen to be kept from the last successful probe")
+	}
+
+	if !strings.Contains(monitor.Summary(), "phong_khach (Đèn Phòng Khách): offline") {
+		t.Errorf("Unexpected summary: %s", monitor.Summary())
+	}
+}
+
+func TestHealthMonitorTimeout(t *testing.T) {
+	monitor := NewHealthMonitor(NewCommandRouter(&Config{}), HealthConfig{})
+	monitor.timeout = 10 * time.Millisecond
+	stopped := make(chan struct{})
+
+	monitor.check(healthTarget{
+		id:       "slow",
+		protocol: "http",
+		probe: func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return ctx.Err()
+		},
+	})
+
+	status, _ := monitor.GetStatus("slow")
+	if status.Online {
+		t.Error("Expected timed out probe to mark device offline")
+	}
+
+	// The probe is told to stop rather than left running
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Probe still running after the check timed out")
+	}
+}
+
+func TestHealthMonitorMQTTUnknown(t *testing.T) {
+	monitor := NewHealthMonitor(NewCommandRouter(&Config{}), HealthConfig{})
+	target := healthTarget{
+		id:       "bep",
+		protocol: "mqtt",
+		probe: func(ctx context.Context) error {
+			return errAvailabilityUnknown
+		},
+	}
+	monitor.check(target)
+
+	status, ok := monitor.GetStatus("bep")
+	if !ok || status.Checked || status.Online {
+		t.Errorf("Expected unknown status, got %+v", status)
+	}
+	if len(monitor.Events()) != 0 {
+		t.Error("An unknown status should not emit a transition event")
+	}
+	if !strings.Contains(monitor.Summary(), "bep (): unknown") {
+		t.Errorf("Unexpected summary: %s", monitor.Summary())
+	}
+}
+
+func TestHealthStatusLatencyJSON(t *testing.T) {
+	data, err := json.Marshal(HealthStatus{DeviceID: "bep", LatencyMs: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"latency_ms":42`) {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+}
+
+func TestHealthMonitorReload(t *testing.T) {
+	router := newDryRunRouter(t)
+	monitor := NewHealthMonitor(router, HealthConfig{})
+	for _, id := range []string{"phong_khach", "bep", "quat"} {
+		monitor.check(healthTarget{id: id, probe: func(ctx context.Context) error { return nil }})
+	}
+	monitor.topics["bep"] = "home/kitchen/light/availability"
+	monitor.topics["quat"] = "home/fan/availability"
+	monitor.mqttState["bep"] = true
+	monitor.mqttState["quat"] = true
+
+	config := *router.config
+	config.Devices.Lights = map[string]DeviceInfo{
+		"phong_khach": router.config.Devices.Lights["phong_khach"],
+	}
+	config.Devices.Switches = map[string]DeviceInfo{
+		"quat": {Type: "mqtt", Topic: "home/fan2", Name: "Quạt"},
+	}
+	router.Reload(&config)
+	monitor.Reload()
+
+	if _, ok := monitor.GetStatus("bep"); ok {
+		t.Error("Expected the status of a removed device to be dropped")
+	}
+	if _, ok := monitor.GetStatus("phong_khach"); !ok {
+		t.Error("Expected the status of a kept device to remain")
+	}
+	if _, ok := monitor.topics["quat"]; ok {
+		t.Error("Expected the old availability topic of a changed device to be dropped")
+	}
+	if monitor.mqttState["quat"] || monitor.mqttState["bep"] {
+		t.Error("Expected availability of removed and changed devices to be forgotten")
+	}
+}
+
+func TestHealthMonitorRestart(t *testing.T) {
+	monitor := NewHealthMonitor(NewCommandRouter(&Config{}), HealthConfig{})
+	for i := 0; i < 2; i++ {
+		if err := monitor.Start(); err != nil {
+			t.Fatalf("Start() #%d error = %v", i+1, err)
+		}
+		select {
+		case <-monitor.stopChan:
+			t.Fatalf("Start() #%d: monitor stopped right away", i+1)
+		default:
+		}
+		monitor.Stop()
+	}
+}
+
+func TestHealthMonitorWaitForAvailability(t *testing.T) {
+	monitor := NewHealthMonitor(NewCommandRouter(&Config{}), HealthConfig{})
+	monitor.topics["bep"] = "home/kitchen/light/availability"
+
+	// The retained message arrives shortly after subscribing
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		monitor.mu.Lock()
+		monitor.mqttState["bep"] = true
+		monitor.mu.Unlock()
+	}()
+
+	start := time.Now()
+	monitor.waitForAvailability(5 * time.Second)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("waitForAvailability() took %v, expected it to return once availability arrived", elapsed)
+	}
+
+	// A device that never answers only delays the check by the timeout
+	monitor.topics["quat"] = "home/fan/availability"
+	start = time.Now()
+	monitor.waitForAvailability(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("waitForAvailability() took %v, expected the timeout", elapsed)
+	}
+}
@@ -0,0 +1,710 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrNotUnderstood is returned when a request is not a simple device command
+var ErrNotUnderstood = errors.New("request not understood")
+
+// Intents recognized in a clause
+const (
+	intentOn      = "on"
+	intentOff     = "off"
+	intentToggle  = "toggle"
+	intentPause   = "pause"
+	intentHome    = "home"
+	intentVolUp   = "vol_up"
+	intentVolDown = "vol_down"
+)
+
+// intentPhrases maps spoken verbs (without diacritics) to intents, checked in order
+var intentPhrases = []struct {
+	intent  string
+	phrases []string
+}{
+	{intentVolUp, []string{"tang am luong", "tang tieng", "to len", "volume up", "louder"}},
+	{intentVolDown, []string{"giam am luong", "giam tieng", "bot tieng", "nho lai", "volume down", "quieter"}},
+	{intentPause, []string{"tam dung", "tam ngung", "pause"}},
+	{intentHome, []string{"ve sac", "ve dock", "ve tram", "sac pin", "go home", "return home", "dock"}},
+	{intentToggle, []string{"toggle"}},
+	{intentOn, []string{"bat dau", "khoi dong", "turn on", "switch on", "bat", "mo", "start", "on"}},
+	{intentOff, []string{"turn off", "switch off", "tat", "dung", "ngung", "stop", "off"}},
+}
+
+// categoryPhrases are generic words for each device category
+var categoryPhrases = map[string][]string{
+	"light":  {"den", "light", "lights", "lamp", "bulb"},
+	"switch": {"cong tac", "o cam", "switch", "plug"},
+	"ac":     {"dieu hoa", "may lanh", "dieu hoa nhiet do", "air conditioner", "ac"},
+	"vacuum": {"robot", "hut bui", "may hut", "vacuum"},
+	"tv":     {"tv", "tivi", "ti vi", "television"},
+}
+
+// clauseSeparators split a request into several commands
+var clauseSeparators = []string{"va", "roi", "and", "then"}
+
+// fillerPhrases carry no meaning in a command and may be left over after the
+// intent, device and value. Any other word left over makes the request more
+// than a simple command.
+var fillerPhrases = []string{
+	"ngay bay gio", "bay gio", "lam on", "cho toi", "giup toi", "o muc",
+	"cho", "giup", "gium", "hay", "nhe", "nha", "di", "voi", "a", "oi", "chinh", "dat", "de", "len", "o", "muc",
+	"right now", "for me", "can you", "could you", "would you",
+	"please", "now", "turn", "set", "the", "an", "my", "to", "in", "at", "of",
+	"jarvis", "hey",
+}
+
+// roomPhrases translate English room names to the Vietnamese words used in
+// device names, so "kitchen light" finds "Đèn Bếp"
+var roomPhrases = map[string]string{
+	"living room": "phong khach",
+	"bedroom":     "phong ngu",
+	"kitchen":     "bep",
+}
+
+// questionWords start a question rather than a command
+var questionWords = map[string]bool{
+	"is": true, "are": true, "was": true, "were": true, "does": true, "did": true,
+	"what": true, "which": true, "how": true, "why": true, "when": true, "where": true, "who": true,
+}
+
+// negationWords, without diacritics, negate a command or end a question
+// ("đèn có bật không"). "đừng" folds to "dung" like "dừng" (stop), so it is
+// told apart by its "đ". Words of a device name, e.g. "không" in "Máy Lọc
+// Không Khí", don't count.
+var negationWords = map[string]bool{
+	"khong": true, "chang": true, "chua": true, "gi": true, "nao": true,
+	"not": true, "dont": true, "never": true,
+}
+
+// IntentParser maps simple spoken requests such as "bật đèn phòng khách" or
+// "điều hòa 26 độ" to commands without a language model. Matching ignores
+// case and Vietnamese diacritics; numbers may be digits or spoken words.
+type IntentParser struct {
+	devices   []intentDevice
+	nameWords map[string]bool // words of every device name
+}
+
+// intentDevice is a catalog entry with its name tokens
+type intentDevice struct {
+	entry CatalogEntry
+	words map[string]bool
+}
+
+// NewIntentParser creates a parser for the devices in the configuration
+func NewIntentParser(config *Config) *IntentParser {
+	parser := &IntentParser{nameWords: make(map[string]bool)}
+	for _, entry := range DeviceCatalog(config) {
+		words := make(map[string]bool)
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			for _, word := range tokenize(name) {
+				words[word] = true
+				parser.nameWords[word] = true
+			}
+		}
+		for _, word := range strings.Split(entry.ID, "_") {
+			words[word] = true
+		}
+		parser.devices = append(parser.devices, intentDevice{entry: entry, words: words})
+	}
+	return parser
+}
+
+// Parse converts a request to a batch of commands. It returns an error
+// wrapping ErrNotUnderstood unless every part of the request is understood.
+func (p *IntentParser) Parse(text string) (*Batch, error) {
+	if p.isNegationOrQuestion(text) {
+		return nil, fmt.Errorf("%w: negation or question", ErrNotUnderstood)
+	}
+
+	var clauses [][]string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' }) {
+		tokens := tokenize(strings.ReplaceAll(part, "%", " percent "))
+		for phrase, replacement := range roomPhrases {
+			tokens = replacePhrase(tokens, strings.Fields(phrase), strings.Fields(replacement))
+		}
+		clauses = append(clauses, splitClauses(tokens)...)
+	}
+	if len(clauses) == 0 {
+		return nil, ErrNotUnderstood
+	}
+
+	var commands []*Command
+	var ambiguous *AmbiguousDeviceError
+	previous := ""
+	for _, clause := range clauses {
+		if questionWords[clause[0]] {
+			return nil, fmt.Errorf("%w: question", ErrNotUnderstood)
+		}
+
+		intent, rest := findIntent(clause)
+		if intent == "" {
+			// "bật đèn phòng khách và phòng ngủ" repeats the verb
+			intent = previous
+		}
+
+		targets, rest, err := p.findDevices(rest)
+		var unsure *AmbiguousDeviceError
+		if errors.As(err, &unsure) {
+			if ambiguous != nil {
+				return nil, fmt.Errorf("%w: %w", ErrNotUnderstood, err)
+			}
+			// Parse the rest of the request, so the user only has to pick the device
+			ambiguous = unsure
+			targets = targets[:1]
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Whatever isn't the intent, the device or filler must be the value
+		value, rest, err := parseValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("%w: unexpected %q", ErrNotUnderstood, strings.Join(rest, " "))
+		}
+
+		for _, device := range targets {
+			cmd, err := intentCommand(device.entry, intent, value)
+			if err != nil {
+				return nil, err
+			}
+			if unsure != nil {
+				ambiguous.Command = cmd
+			}
+			commands = append(commands, cmd)
+		}
+		previous = intent
+	}
+
+	batch, err := newBatch(commands, false)
+	if err != nil {
+		return nil, err
+	}
+	if ambiguous != nil {
+		ambiguous.Batch = batch
+		return nil, fmt.Errorf("%w: %w", ErrNotUnderstood, ambiguous)
+	}
+	return batch, nil
+}
+
+// findDevices returns the devices a clause refers to and the remaining
+// tokens. "tất cả đèn" refers to every device of the category. When several
+// devices match equally, they are returned with an *AmbiguousDeviceError.
+func (p *IntentParser) findDevices(tokens []string) ([]intentDevice, []string, error) {
+	all := false
+	if index := indexPhrase(tokens, []string{"tat", "ca"}); index >= 0 {
+		all = true
+		tokens = removeTokens(tokens, index, 2)
+	} else if index := indexPhrase(tokens, []string{"all"}); index >= 0 {
+		all = true
+		tokens = removeTokens(tokens, index, 1)
+	}
+
+	// Score devices by the words of their name and category in the clause
+	best := 0
+	var matches []intentDevice
+	for _, device := range p.devices {
+		score := 0
+		for _, token := range tokens {
+			if device.words[token] {
+				score++
+			}
+		}
+		for _, phrase := range categoryPhrases[device.entry.Category] {
+			if indexPhrase(tokens, strings.Fields(phrase)) >= 0 {
+				score++
+			}
+		}
+
+		switch {
+		case score == 0 || score < best:
+		case score > best:
+			best = score
+			matches = []intentDevice{device}
+		default:
+			matches = append(matches, device)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, nil, fmt.Errorf("%w: no device named in %q", ErrNotUnderstood, strings.Join(tokens, " "))
+	}
+
+	if all {
+		category := matches[0].entry.Category
+		matches = matches[:0:0]
+		for _, device := range p.devices {
+			if device.entry.Category == category {
+				matches = append(matches, device)
+			}
+		}
+	}
+
+	// Leave the tokens that aren't part of the device name or category, e.g. the value
+	remaining := tokens
+	for _, phrase := range categoryPhrases[matches[0].entry.Category] {
+		words := strings.Fields(phrase)
+		for index := indexPhrase(remaining, words); index >= 0; index = indexPhrase(remaining, words) {
+			remaining = removeTokens(remaining, index, len(words))
+		}
+	}
+	rest := make([]string, 0, len(remaining))
+	for _, token := range remaining {
+		if !matches[0].words[token] {
+			rest = append(rest, token)
+		}
+	}
+
+	if !all && len(matches) > 1 {
+		ambiguous := &AmbiguousDeviceError{Reference: strings.Join(tokens, " ")}
+		for _, device := range matches {
+			ambiguous.Candidates = append(ambiguous.Candidates, device.entry)
+		}
+		return matches, rest, ambiguous
+	}
+	return matches, rest, nil
+}
+
+// intentCommand builds the command for an intent on a device, with the
+// value given in the clause, if any
+func intentCommand(entry CatalogEntry, intent string, value *intentValue) (*Command, error) {
+	hasValue := value != nil
+
+	var action string
+	switch entry.Category {
+	case "light":
+		switch {
+		case hasValue && value.unit == unitPercent && intent != intentOff:
+			action = "light.brightness"
+		case intent == intentOn || intent == intentOff:
+			action = "light." + intent
+		}
+	case "switch":
+		switch intent {
+		case intentOn, intentOff, intentToggle:
+			action = "switch." + intent
+		}
+	case "ac":
+		switch {
+		case hasValue && value.unit == unitDegrees && intent != intentOff:
+			action = "ac.set_temp"
+		case intent == intentOn || intent == intentOff:
+			action = "ac." + intent
+		}
+	case "vacuum":
+		switch intent {
+		case intentOn:
+			action = "vacuum.start"
+		case intentOff:
+			action = "vacuum.stop"
+		case intentPause, intentHome:
+			action = "vacuum." + intent
+		}
+	case "tv":
+		switch intent {
+		case intentOn, intentOff, intentToggle:
+			action = "tv.power"
+		case intentVolUp, intentVolDown:
+			action = "tv." + intent
+		}
+	}
+
+	if action == "" {
+		return nil, fmt.Errorf("%w: no action for %s", ErrNotUnderstood, entry.ID)
+	}
+	if !containsString(entry.Actions, action) {
+		return nil, fmt.Errorf("%w: %s does not support %s", ErrNotUnderstood, entry.ID, action)
+	}
+
+	cmd := &Command{Action: action, Device: entry.ID}
+	if action == "light.brightness" || action == "ac.set_temp" {
+		cmd.Value = value.number
+	} else if hasValue {
+		return nil, fmt.Errorf("%w: %s takes no value", ErrNotUnderstood, action)
+	}
+	return cmd, nil
+}
+
+// isNegationOrQuestion reports whether a request negates a command ("đừng
+// bật đèn", "don't turn on the light") or asks a question ("đèn có bật
+// không?"), which must not be run as a command
+func (p *IntentParser) isNegationOrQuestion(text string) bool {
+	if strings.Contains(text, "?") {
+		return true
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+	for i, word := range words {
+		folded := FoldText(word)
+		switch {
+		case p.nameWords[folded]:
+		case negationWords[folded]:
+			return true
+		case folded == "dung" && strings.HasPrefix(word, "đ"):
+			return true
+		case i+1 < len(words) && words[i+1] == "t" && strings.HasSuffix(folded, "n"):
+			// don't, doesn't, can't, won't
+			return true
+		}
+	}
+	return false
+}
+
+// findIntent returns the first intent phrase in a clause and the clause without it
+func findIntent(tokens []string) (string, []string) {
+	for _, group := range intentPhrases {
+		for _, phrase := range group.phrases {
+			words := strings.Fields(phrase)
+			if index := indexPhrase(tokens, words); index >= 0 {
+				return group.intent, removeTokens(tokens, index, len(words))
+			}
+		}
+	}
+	return "", tokens
+}
+
+// splitClauses splits tokens at conjunctions such as "và" or "rồi"
+func splitClauses(tokens []string) [][]string {
+	var clauses [][]string
+	start := 0
+	for i := 0; i < len(tokens); i++ {
+		for _, separator := range clauseSeparators {
+			words := strings.Fields(separator)
+			if i+len(words) <= len(tokens) && equalTokens(tokens[i:i+len(words)], words) {
+				if i > start {
+					clauses = append(clauses, tokens[start:i])
+				}
+				start = i + len(words)
+				i = start - 1
+				break
+			}
+		}
+	}
+	if start < len(tokens) {
+		clauses = append(clauses, tokens[start:])
+	}
+	return clauses
+}
+
+// Spoken numbers, without diacritics
+var (
+	vietnameseDigits = map[string]int{
+		"khong": 0, "mot": 1, "hai": 2, "ba": 3, "bon": 4, "tu": 4, "nam": 5, "lam": 5,
+		"sau": 6, "bay": 7, "tam": 8, "chin": 9,
+	}
+	englishNumbers = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+		"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13,
+		"fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18,
+		"nineteen": 19, "twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60,
+		"seventy": 70, "eighty": 80, "ninety": 90,
+	}
+)
+
+// numberUnits mark the preceding words as a value rather than other words
+var numberUnits = map[string]bool{"do": true, "phan": true, "percent": true, "degrees": true, "degree": true}
+
+// Kinds of value a number can give
+const (
+	unitPercent = "percent"
+	unitDegrees = "degrees"
+)
+
+// valueUnits follow a number and tell what it is ("26 độ", "50%")
+var valueUnits = []struct{ phrase, unit string }{
+	{"phan tram", unitPercent}, {"percent", unitPercent},
+	{"do", unitDegrees}, {"degrees", unitDegrees}, {"degree", unitDegrees},
+}
+
+// valueKeywords come before a number and tell what it is ("độ sáng 50")
+var valueKeywords = []struct{ phrase, unit string }{
+	{"do sang", unitPercent}, {"sang", unitPercent}, {"brightness", unitPercent},
+	{"nhiet do", unitDegrees}, {"temperature", unitDegrees},
+}
+
+// timeMarkers come before a clock time ("lúc bảy giờ", "at 7")
+var timeMarkers = map[string]bool{"luc": true, "at": true}
+
+// timeUnits follow a number that is a time or a delay ("lúc 7 giờ", "in 10
+// minutes"); scheduled commands are left to the assistant
+var timeUnits = map[string]bool{
+	"gio": true, "phut": true, "giay": true, "tieng": true,
+	"hour": true, "hours": true, "minute": true, "minutes": true, "second": true, "seconds": true,
+	"am": true, "pm": true, "clock": true,
+}
+
+// intentValue is a number with the kind of value it gives
+type intentValue struct {
+	number float64
+	unit   string
+}
+
+// parseValue finds the value in the tokens left over from a clause. A number
+// is only a value with a unit after it or a keyword before it; any other
+// number, e.g. a time, is not understood. The tokens not used, other than
+// filler, are returned.
+func parseValue(tokens []string) (*intentValue, []string, error) {
+	for i, token := range tokens[:max(len(tokens)-1, 0)] {
+		if timeMarkers[token] && isNumberWord(tokens[i+1]) {
+			return nil, nil, fmt.Errorf("%w: times are not supported", ErrNotUnderstood)
+		}
+	}
+
+	tokens = removeFillers(tokens)
+	number, index, length, ok := findNumber(tokens)
+	if !ok {
+		return nil, tokens, nil
+	}
+
+	end := index + length
+	if end < len(tokens) && timeUnits[tokens[end]] {
+		return nil, nil, fmt.Errorf("%w: times are not supported", ErrNotUnderstood)
+	}
+
+	unit := ""
+	for _, u := range valueUnits {
+		words := strings.Fields(u.phrase)
+		if end+len(words) <= len(tokens) && equalTokens(tokens[end:end+len(words)], words) {
+			unit, end = u.unit, end+len(words)
+			break
+		}
+	}
+	for _, k := range valueKeywords {
+		words := strings.Fields(k.phrase)
+		if index >= len(words) && equalTokens(tokens[index-len(words):index], words) {
+			if unit != "" && unit != k.unit {
+				return nil, nil, fmt.Errorf("%w: %s with %s", ErrNotUnderstood, k.phrase, unit)
+			}
+			unit, index = k.unit, index-len(words)
+			break
+		}
+	}
+	if unit == "" {
+		return nil, nil, fmt.Errorf("%w: number %v without a unit", ErrNotUnderstood, number)
+	}
+
+	rest := append(append([]string{}, tokens[:index]...), tokens[end:]...)
+	if _, _, _, another := findNumber(rest); another {
+		return nil, nil, fmt.Errorf("%w: more than one number", ErrNotUnderstood)
+	}
+	return &intentValue{number: number, unit: unit}, rest, nil
+}
+
+// removeFillers returns the tokens without filler words
+func removeFillers(tokens []string) []string {
+	for _, phrase := range fillerPhrases {
+		words := strings.Fields(phrase)
+		for index := indexPhrase(tokens, words); index >= 0; index = indexPhrase(tokens, words) {
+			tokens = removeTokens(tokens, index, len(words))
+		}
+	}
+	return tokens
+}
+
+// findNumber returns the first number in the tokens with its position and
+// length. Numbers may be digits or Vietnamese or English words ("hai mươi
+// sáu", "twenty six"); short spoken numbers such as "hai sáu" must be
+// followed by a unit like "độ", since words like "bảy" or "tư" are also
+// ordinary words.
+func findNumber(tokens []string) (float64, int, int, bool) {
+	for i, token := range tokens {
+		if n, err := strconv.Atoi(token); err == nil {
+			return float64(n), i, 1, true
+		}
+
+		n, length, explicit := 0, 0, false
+		if _, ok := vietnameseDigits[token]; ok || token == "muoi" || token == "tram" {
+			n, length, explicit = parseVietnameseNumber(tokens[i:])
+		} else if _, ok := englishNumbers[token]; ok || token == "hundred" {
+			n, length = parseEnglishNumber(tokens[i:])
+			explicit = true
+		}
+		if length == 0 {
+			continue
+		}
+		if explicit || (i+length < len(tokens) && numberUnits[tokens[i+length]]) {
+			return float64(n), i, length, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// isNumberWord reports whether a token is a number in digits or a number word
+func isNumberWord(token string) bool {
+	if _, err := strconv.Atoi(token); err == nil {
+		return true
+	}
+	_, vietnamese := vietnameseDigits[token]
+	_, english := englishNumbers[token]
+	return vietnamese || english || token == "muoi"
+}
+
+// parseVietnameseNumber reads a spoken Vietnamese number at the start of the
+// tokens, returning its value, the tokens used and whether it contains
+// "mươi" or "trăm"
+func parseVietnameseNumber(tokens []string) (int, int, bool) {
+	total, current := 0, 0
+	lastDigit, explicit := false, false
+	length := 0
+	for _, token := range tokens {
+		if digit, ok := vietnameseDigits[token]; ok {
+			if lastDigit {
+				// Colloquial "hai sáu" for 26
+				current = current*10 + digit
+			} else {
+				current += digit
+			}
+			lastDigit = true
+			length++
+			continue
+		}
+
+		lastDigit = false
+		switch token {
+		case "muoi":
+			if current == 0 {
+				current = 10
+			} else {
+				current *= 10
+			}
+			explicit = true
+		case "tram":
+			if current == 0 {
+				current = 1
+			}
+			total += current * 100
+			current = 0
+			explicit = true
+		case "linh", "le":
+		default:
+			return total + current, length, explicit
+		}
+		length++
+	}
+	return total + current, length, explicit
+}
+
+// parseEnglishNumber reads a spoken English number at the start of the
+// tokens, returning its value and the tokens used
+func parseEnglishNumber(tokens []string) (int, int) {
+	total, current := 0, 0
+	length := 0
+	for _, token := range tokens {
+		if n, ok := englishNumbers[token]; ok {
+			current += n
+			length++
+			continue
+		}
+		if token != "hundred" {
+			break
+		}
+		if current == 0 {
+			current = 1
+		}
+		total += current * 100
+		current = 0
+		length++
+	}
+	return total + current, length
+}
+
+// foldTable maps Vietnamese letters to their base letter
+var foldTable = func() map[rune]rune {
+	table := make(map[rune]rune)
+	for base, letters := range map[rune]string{
+		'a': "àáảãạăằắẳẵặâầấẩẫậ",
+		'e': "èéẻẽẹêềếểễệ",
+		'i': "ìíỉĩị",
+		'o': "òóỏõọôồốổỗộơờớởỡợ",
+		'u': "ùúủũụưừứửữự",
+		'y': "ỳýỷỹỵ",
+		'd': "đ",
+	} {
+		for _, letter := range letters {
+			table[letter] = base
+		}
+	}
+	return table
+}()
+
+// FoldText lowercases text and removes Vietnamese diacritics, so "Đèn Phòng
+// Khách" and "den phong khach" compare equal
+func FoldText(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.Is(unicode.Mn, r) {
+			// Combining marks from decomposed input
+			continue
+		}
+		if base, ok := foldTable[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// tokenize folds text and splits it into words and numbers
+func tokenize(text string) []string {
+	return strings.FieldsFunc(FoldText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// indexPhrase returns the position of a phrase in the tokens, or -1
+func indexPhrase(tokens, phrase []string) int {
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		if equalTokens(tokens[i:i+len(phrase)], phrase) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replacePhrase returns the tokens with every occurrence of a phrase replaced
+func replacePhrase(tokens, phrase, replacement []string) []string {
+	for index := indexPhrase(tokens, phrase); index >= 0; index = indexPhrase(tokens, phrase) {
+		replaced := append(append([]string{}, tokens[:index]...), replacement...)
+		tokens = append(replaced, tokens[index+len(phrase):]...)
+	}
+	return tokens
+}
+
+// removeTokens returns the tokens without n tokens at index
+func removeTokens(tokens []string, index, n int) []string {
+	rest := make([]string, 0, len(tokens)-n)
+	rest = append(rest, tokens[:index]...)
+	return append(rest, tokens[index+n:]...)
+}
+
+// equalTokens reports whether two token slices are equal
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether a slice contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
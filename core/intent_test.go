@@ -0,0 +1,182 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe formats commands as "action device [value]" for comparison
+func describe(batch *Batch) string {
+	parts := make([]string, len(batch.Commands))
+	for i, cmd := range batch.Commands {
+		parts[i] = cmd.Action + " " + cmd.Device
+		if cmd.Value != nil {
+			parts[i] += fmt.Sprintf(" %v", cmd.Value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestIntentParser(t *testing.T) {
+	parser := NewIntentParser(newTestConfig(t))
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"bật đèn phòng khách", "light.on phong_khach"},
+		{"Tắt đèn bếp.", "light.off bep"},
+		{"tat den phong ngu", "light.off phong_ngu"},
+		{"tắt quạt", "switch.off quat"},
+		{"điều hòa 26 độ", "ac.set_temp dieu_hoa 26"},
+		{"chỉnh điều hòa hai mươi sáu độ", "ac.set_temp dieu_hoa 26"},
+		{"máy lạnh mười tám độ", "ac.set_temp dieu_hoa 18"},
+		{"điều hòa hai sáu độ", "ac.set_temp dieu_hoa 26"},
+		{"tắt điều hòa", "ac.off dieu_hoa"},
+		{"đèn phòng khách sáng 50%", "light.brightness phong_khach 50"},
+		{"đèn bếp năm mươi phần trăm", "light.brightness bep 50"},
+		{"bật đèn phòng khách bây giờ", "light.on phong_khach"},
+		{"bật đèn phòng khách và phòng ngủ", "light.on phong_khach; light.on phong_ngu"},
+		{"bật đèn bếp, tắt quạt", "light.on bep; switch.off quat"},
+		{"tắt tất cả đèn", "light.off bep; light.off phong_khach; light.off phong_ngu"},
+		{"cho robot hút bụi về sạc", "vacuum.home robot"},
+		{"bắt đầu hút bụi", "vacuum.start robot"},
+		{"tạm dừng robot", "vacuum.pause robot"},
+		{"tăng âm lượng tivi", "tv.vol_up tv"},
+		{"turn off the kitchen light bep", "light.off bep"},
+		{"set the air conditioner to twenty six degrees", "ac.set_temp dieu_hoa 26"},
+		{"turn off the kitchen light", "light.off bep"},
+		{"set the living room light brightness to 40", "light.brightness phong_khach 40"},
+		{"đèn phòng ngủ độ sáng 30", "light.brightness phong_ngu 30"},
+		{"dừng robot", "vacuum.stop robot"},
+		{"làm ơn bật đèn bếp nhé", "light.on bep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			batch, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := describe(batch); got != tt.want {
+				t.Errorf("Parse() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntentParserNotUnderstood(t *testing.T) {
+	parser := NewIntentParser(newTestConfig(t))
+
+	for _, input := range []string{
+		"",
+		"hôm nay thời tiết thế nào",
+		"bật đèn",                    // ambiguous
+		"bật đèn phòng khách và hát", // second clause has no device
+		"tăng âm lượng đèn bếp",      // unsupported action
+		"phòng khách",                // no action
+
+		// Negations and questions
+		"đừng bật đèn phòng khách",
+		"don't turn on the living room light",
+		"do not turn on the living room light",
+		"is the living room light on",
+		"đèn phòng khách có bật không",
+		"bật đèn phòng khách?",
+
+		// Times and delays are not values
+		"bật đèn phòng khách lúc 7 giờ",
+		"bật đèn phòng khách lúc bảy giờ",
+		"bật đèn phòng khách trong 10 phút",
+		"tắt đèn phòng khách sau 10 phút",
+		"turn off the living room light in 10 minutes",
+		"turn on the living room light at 7",
+
+		// Values need their unit or keyword
+		"bật đèn phòng khách 7",
+		"đèn phòng khách 26 độ",
+		"điều hòa 50%",
+		"đèn phòng khách sáng 50% 60%",
+
+		// Words that aren't understood
+		"bật đèn phòng khách thật đẹp",
+		"bật đèn phòng khách tối nay",
+	} {
+		if _, err := parser.Parse(input); !errors.Is(err, ErrNotUnderstood) {
+			t.Errorf("Parse(%q) error = %v, want ErrNotUnderstood", input, err)
+		}
+	}
+}
+
+func TestIntentParserAliasesAndAmbiguity(t *testing.T) {
+	config := newTestConfig(t)
+	light := config.Devices.Lights["phong_khach"]
+	light.Aliases = []string{"living room"}
+	config.Devices.Lights["phong_khach"] = light
+	parser := NewIntentParser(config)
+
+	batch, err := parser.Parse("turn on the living room light")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := describe(batch); got != "light.on phong_khach" {
+		t.Errorf("Parse() = %s", got)
+	}
+
+	_, err = parser.Parse("bật đèn rồi tắt quạt")
+	var ambiguous *AmbiguousDeviceError
+	if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 3 {
+		t.Fatalf("Parse() error = %v, want AmbiguousDeviceError with 3 lights", err)
+	}
+	if !errors.Is(err, ErrNotUnderstood) {
+		t.Errorf("Parse() error = %v, want ErrNotUnderstood", err)
+	}
+
+	// The rest of the request is kept, waiting for the device to be picked
+	if ambiguous.Batch == nil || len(ambiguous.Batch.Commands) != 2 || ambiguous.Command != ambiguous.Batch.Commands[0] {
+		t.Fatalf("Ambiguous request = %+v", ambiguous)
+	}
+	if ambiguous.Command.Action != "light.on" || ambiguous.Batch.Commands[1].Device != "quat" {
+		t.Errorf("Ambiguous batch = %s", describe(ambiguous.Batch))
+	}
+}
+
+func TestFoldText(t *testing.T) {
+	if got := FoldText("Điều Hòa Phòng Khách"); got != "dieu hoa phong khach" {
+		t.Errorf("FoldText() = %q", got)
+	}
+	// Decomposed input: "e" followed by combining marks
+	if got := FoldText("Đèn"); got != "den" {
+		t.Errorf("FoldText() = %q", got)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		ok    bool
+	}{
+		{"26", 26, true},
+		{"hai muoi sau", 26, true},
+		{"ba muoi mot", 31, true},
+		{"hai muoi lam", 25, true},
+		{"mot tram", 100, true},
+		{"mot tram linh nam", 105, true},
+		{"muoi", 10, true},
+		{"hai sau do", 26, true},
+		{"hai sau", 0, false},
+		{"bay gio", 0, false},
+		{"twenty one", 21, true},
+		{"one hundred", 100, true},
+	}
+
+	for _, tt := range tests {
+		got, _, _, ok := findNumber(strings.Fields(tt.input))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("findNumber(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
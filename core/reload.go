@@ -0,0 +1,277 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the config file is checked for changes
+const DefaultReloadInterval = 2 * time.Second
+
+// ConfigDiff lists the devices a new configuration adds, removes or changes
+type ConfigDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether no device changed
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String describes the diff, e.g. "added bep; changed phong_khach"
+func (d ConfigDiff) String() string {
+	if d.Empty() {
+		return "no device changes"
+	}
+	parts := make([]string, 0, 3)
+	for _, group := range []struct {
+		name string
+		ids  []string
+	}{{"added", d.Added}, {"removed", d.Removed}, {"changed", d.Changed}} {
+		if len(group.ids) > 0 {
+			parts = append(parts, group.name+" "+strings.Join(group.ids, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// DiffDevices compares the devices of two configurations. A device moved
+// to another section counts as changed.
+func DiffDevices(old, new *Config) ConfigDiff {
+	before, after := deviceSettings(old), deviceSettings(new)
+
+	var diff ConfigDiff
+	for _, id := range sortedKeys(after) {
+		previous, ok := before[id]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, id)
+		case !reflect.DeepEqual(previous, after[id]):
+			diff.Changed = append(diff.Changed, id)
+		}
+	}
+	for _, id := range sortedKeys(before) {
+		if _, ok := after[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	return diff
+}
+
+// deviceSettings maps every device ID to its section and settings
+func deviceSettings(config *Config) map[string]interface{} {
+	settings := make(map[string]interface{})
+	for id, info := range config.Devices.Lights {
+		settings[id] = [2]interface{}{"lights", info}
+	}
+	for id, info := range config.Devices.Switches {
+		settings[id] = [2]interface{}{"switches", info}
+	}
+	for id, info := range config.Devices.IRDevices {
+		settings[id] = [2]interface{}{"ir_devices", info}
+	}
+	for id, info := range config.Devices.Vacuum {
+		settings[id] = [2]interface{}{"vacuum", info}
+	}
+	return settings
+}
+
+// RestartRequired lists the changed sections that are only read at startup
+func RestartRequired(old, new *Config) []string {
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"audio", old.Audio, new.Audio},
+		{"capture", old.Capture, new.Capture},
+		{"claude", old.Claude, new.Claude},
+		{"health", old.Health, new.Health},
+		{"mqtt", old.MQTT, new.MQTT},
+		{"stt", old.STT, new.STT},
+		{"tapo", old.Tapo, new.Tapo},
+		{"tts", old.TTS, new.TTS},
+		{"vad", old.VAD, new.VAD},
+		{"wake_word", old.WakeWord, new.WakeWord},
+	}
+
+	var changed []string
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.new) {
+			changed = append(changed, section.name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// ConfigWatcher polls a config file and the files it includes, and
+// delivers each new, valid configuration. An invalid file is reported and
+// ignored, so the current configuration stays in effect until it is fixed.
+type ConfigWatcher struct {
+	path     string
+	interval time.Duration
+	secrets  SecretStore
+	current  *Config              // last configuration loaded
+	stamps   map[string]fileStamp // files read by the last load
+	patterns []string             // include patterns, to notice new files
+	changes  chan *Config
+	reload   chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// fileStamp identifies a version of a file. The zero value is a missing file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewConfigWatcher creates a watcher for a config file. Its current
+// contents are taken as already loaded. Secret references are resolved
+// from secrets, which may be nil.
+func NewConfigWatcher(path string, interval time.Duration, secrets SecretStore) *ConfigWatcher {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	w := &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		secrets:  secrets,
+		changes:  make(chan *Config, 1),
+		reload:   make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+	config, loader, _ := loadConfigFiles(path, secrets)
+	w.current = config
+	w.record(loader)
+	return w
+}
+
+// Start begins watching the files in the background
+func (w *ConfigWatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		defer close(w.changes)
+
+		for {
+			select {
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+				w.poll(false)
+			case <-w.reload:
+				w.poll(true)
+			}
+		}
+	}()
+	log.Printf("Watching %s for changes", w.path)
+}
+
+// Stop stops watching and closes the changes channel
+func (w *ConfigWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+}
+
+// Reload loads the files now even if they look unchanged, e.g. on SIGHUP
+func (w *ConfigWatcher) Reload() {
+	select {
+	case w.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Changes returns the channel of new configurations
+func (w *ConfigWatcher) Changes() <-chan *Config {
+	return w.changes
+}
+
+// poll loads the configuration if a file changed, or unconditionally when
+// forced. A configuration equal to the current one isn't delivered.
+func (w *ConfigWatcher) poll(force bool) {
+	if !force && !w.modified() {
+		return
+	}
+
+	config, loader, err := loadConfigFiles(w.path, w.secrets)
+	w.record(loader)
+	if err == nil {
+		err = validateReloaded(config)
+	}
+	if err != nil {
+		log.Printf("Warning: Keeping the current configuration, %s is invalid: %v", w.path, err)
+		return
+	}
+	if !force && reflect.DeepEqual(config, w.current) {
+		return
+	}
+	w.current = config
+
+	select {
+	case w.changes <- config:
+	case <-w.stopChan:
+	}
+}
+
+// record remembers the files and include patterns of a load
+func (w *ConfigWatcher) record(loader *configLoader) {
+	w.stamps = make(map[string]fileStamp)
+	w.stamps[w.path] = stamp(w.path)
+	for _, file := range loader.files {
+		w.stamps[file] = stamp(file)
+	}
+	w.patterns = loader.patterns
+}
+
+// modified reports whether a file changed, disappeared or appeared since
+// the last load
+func (w *ConfigWatcher) modified() bool {
+	for file, previous := range w.stamps {
+		if stamp(file) != previous {
+			return true
+		}
+	}
+	for _, pattern := range w.patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if _, ok := w.stamps[match]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stamp returns the current version of a file
+func stamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// validateReloaded validates a reloaded configuration, logging each problem
+func validateReloaded(config *Config) error {
+	var problems ConfigErrors
+	if err := config.Validate(); errors.As(err, &problems) {
+		for _, problem := range problems {
+			log.Printf("Config error: %s", problem)
+		}
+		return fmt.Errorf("%d configuration errors", len(problems))
+	}
+	return nil
+}
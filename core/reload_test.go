@@ -0,0 +1,235 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiffDevices(t *testing.T) {
+	old := newValidConfig(t)
+	new := newValidConfig(t)
+	new.Devices.Lights["phong_tam"] = DeviceInfo{Type: "tapo", IP: "192.168.1.12", Name: "Đèn Phòng Tắm"}
+	delete(new.Devices.Vacuum, "robot")
+	new.Devices.IRDevices["dieu_hoa"].Commands["temp_18"] = "2600ee"
+	new.Devices.Switches["bep"] = new.Devices.Lights["bep"]
+	delete(new.Devices.Lights, "bep")
+
+	diff := DiffDevices(old, new)
+	if got := diff.String(); got != "added phong_tam; removed robot; changed bep, dieu_hoa" {
+		t.Errorf("DiffDevices() = %s", got)
+	}
+	if !DiffDevices(old, newValidConfig(t)).Empty() {
+		t.Error("Expected no changes between identical configs")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := newValidConfig(t)
+	new := newValidConfig(t)
+	new.Devices.Lights["phong_tam"] = DeviceInfo{Type: "tapo", IP: "192.168.1.12"}
+	new.Confirm.MinDevicesOff = 5
+	if sections := RestartRequired(old, new); len(sections) != 0 {
+		t.Errorf("RestartRequired() = %v, want none", sections)
+	}
+
+	new.VAD.Mode = 3
+	new.Claude.Model = "other"
+	sections := RestartRequired(old, new)
+	if len(sections) != 2 || sections[0] != "claude" || sections[1] != "vad" {
+		t.Errorf("RestartRequired() = %v", sections)
+	}
+}
+
+func TestRouterReload(t *testing.T) {
+	router := newDryRunRouter(t)
+	kept := router.tapoDevices["phong_khach"]
+
+	config := *router.config
+	config.Devices = DevicesConfig{
+		Lights: map[string]DeviceInfo{
+			"phong_khach": router.config.Devices.Lights["phong_khach"],
+			"phong_tam":   {Type: "tapo", Model: "L530", IP: "192.168.1.12", Name: "Đèn Phòng Tắm", Aliases: []string{"bathroom"}},
+		},
+		IRDevices: router.config.Devices.IRDevices,
+	}
+
+	diff := router.Reload(&config)
+	if diff.String() != "added phong_tam; removed bep, phong_ngu, quat, robot" {
+		t.Errorf("Reload() = %s", diff)
+	}
+	if router.tapoDevices["phong_khach"] != kept {
+		t.Error("Unchanged device should keep its driver")
+	}
+	if _, err := router.Resolve(&Command{Action: "light.on", Device: "bathroom"}); err != nil {
+		t.Errorf("Resolve() of an added device error = %v", err)
+	}
+	if _, err := router.Resolve(&Command{Action: "vacuum.start", Device: "robot"}); err == nil {
+		t.Error("Expected a removed device to be unknown")
+	}
+
+	moved := config
+	moved.Devices.Lights = map[string]DeviceInfo{
+		"phong_khach": {Type: "tapo", Model: "L530", IP: "192.168.1.50", Name: "Đèn Phòng Khách"},
+	}
+	router.Reload(&moved)
+	op, err := router.Resolve(&Command{Action: "light.on", Device: "phong_khach"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if router.tapoDevices["phong_khach"] == kept || op.Address != "192.168.1.50" {
+		t.Errorf("Changed device should get a new driver, got address %s", op.Address)
+	}
+}
+
+func TestRouterReloadKeepsDriverOnFailure(t *testing.T) {
+	router := newDryRunRouter(t)
+	config := *router.config
+	config.Devices.Vacuum = map[string]DeviceInfo{
+		"robot": {Type: "xiaomi", IP: "192.168.1.40", Token: "00112233445566778899aabbccddeeff", Name: "Robot Hút Bụi"},
+	}
+	router.Reload(&config)
+	kept, ok := router.xiaomiDevices["robot"]
+	if !ok {
+		t.Fatal("Expected a driver for a device with a valid token")
+	}
+
+	for _, token := range []string{"not-hex", ""} {
+		broken := config
+		broken.Devices.Vacuum = map[string]DeviceInfo{
+			"robot": {Type: "xiaomi", IP: "192.168.1.41", Token: token, Name: "Robot Hút Bụi"},
+		}
+		router.Reload(&broken)
+		if router.xiaomiDevices["robot"] != kept {
+			t.Errorf("Token %q: expected the previous driver to be kept", token)
+		}
+	}
+}
+
+func TestRouterReloadWhileExecuting(t *testing.T) {
+	router := newDryRunRouter(t)
+	executor := NewExecutor(NewSecurityManager(), router)
+	executor.security.rateLimit = NewRateLimiter(1000, time.Minute)
+
+	original := router.config
+	changed := *original
+	changed.Devices.Lights = map[string]DeviceInfo{
+		"phong_khach": {Type: "tapo", Model: "L530", IP: "192.168.1.50", Name: "Đèn Phòng Khách"},
+		"bep":         original.Devices.Lights["bep"],
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := executor.ExecuteCommand(&Command{Action: "light.on", Device: "phong khach"}); err != nil {
+					t.Errorf("ExecuteCommand() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if i%2 == 0 {
+			router.Reload(&changed)
+		} else {
+			router.Reload(original)
+		}
+	}
+	wg.Wait()
+}
+
+func TestConfigWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(config *Config) {
+		data, err := json.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(newValidConfig(t))
+	w := NewConfigWatcher(path, 10*time.Millisecond, nil)
+	w.Start()
+	defer w.Stop()
+
+	next := func(timeout time.Duration) *Config {
+		select {
+		case config := <-w.Changes():
+			return config
+		case <-time.After(timeout):
+			return nil
+		}
+	}
+
+	// An unchanged file isn't reloaded
+	if config := next(50 * time.Millisecond); config != nil {
+		t.Fatal("Unexpected reload of an unchanged file")
+	}
+
+	updated := newValidConfig(t)
+	updated.Devices.Lights["phong_tam"] = DeviceInfo{Type: "tapo", IP: "192.168.1.12", Name: "Đèn Phòng Tắm"}
+	write(updated)
+	config := next(time.Second)
+	if config == nil || config.Devices.Lights["phong_tam"].IP != "192.168.1.12" {
+		t.Fatalf("Expected the updated config, got %+v", config)
+	}
+
+	// An invalid file is ignored, even when a reload is forced
+	invalid := newValidConfig(t)
+	invalid.Devices.Lights["bep"] = DeviceInfo{Type: "tapoo"}
+	write(invalid)
+	w.Reload()
+	if config := next(100 * time.Millisecond); config != nil {
+		t.Fatal("Invalid config should not be delivered")
+	}
+
+	write(updated)
+	w.Reload()
+	if config := next(time.Second); config == nil {
+		t.Fatal("Expected the fixed config to be delivered")
+	}
+}
+
+func TestConfigWatcherIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":    "include: rooms/*.yaml",
+		"rooms/bep.yaml": "devices: {lights: {bep: {type: mqtt, topic: home/kitchen/light}}}",
+	})
+	w := NewConfigWatcher(filepath.Join(dir, "config.yaml"), 10*time.Millisecond, nil)
+	w.Start()
+	defer w.Stop()
+
+	next := func() *Config {
+		select {
+		case config := <-w.Changes():
+			return config
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+
+	// Editing an included file reloads
+	if err := os.WriteFile(filepath.Join(dir, "rooms/bep.yaml"), []byte("devices: {lights: {bep: {type: mqtt, topic: home/bep/light}}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if config := next(); config == nil || config.Devices.Lights["bep"].Topic != "home/bep/light" {
+		t.Fatalf("Expected the edited include, got %+v", config)
+	}
+
+	// So does a new file matching an include pattern
+	if err := os.WriteFile(filepath.Join(dir, "rooms/ngu.yaml"), []byte("devices: {lights: {phong_ngu: {type: tapo, ip: 192.168.1.11}}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if config := next(); config == nil || config.Devices.Lights["phong_ngu"].IP != "192.168.1.11" {
+		t.Fatalf("Expected the new room file, got %+v", config)
+	}
+}
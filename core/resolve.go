@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AmbiguousDeviceError is returned when a device reference matches several
+// devices, so the user can be asked which one was meant instead of guessing
+type AmbiguousDeviceError struct {
+	Reference  string         `json:"reference"`
+	Candidates []CatalogEntry `json:"candidates"`
+
+	// Batch and Command, when set, are the request to finish once the
+	// user has picked a device
+	Batch   *Batch   `json:"-"`
+	Command *Command `json:"-"`
+}
+
+// Error lists the candidates, e.g. for Claude to ask the user
+func (e *AmbiguousDeviceError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, entry := range e.Candidates {
+		names[i] = fmt.Sprintf("%s (%s)", entry.ID, entry.Name)
+	}
+	return fmt.Sprintf("device %q is ambiguous, ask which one: %s", e.Reference, strings.Join(names, ", "))
+}
+
+// Question asks the user which device was meant
+func (e *AmbiguousDeviceError) Question() string {
+	names := make([]string, len(e.Candidates))
+	for i, entry := range e.Candidates {
+		names[i] = entry.Name
+		if names[i] == "" {
+			names[i] = entry.ID
+		}
+	}
+	if len(names) == 1 {
+		return fmt.Sprintf("Ý bạn là %s?", names[0])
+	}
+	return fmt.Sprintf("Bạn muốn nói %s hay %s?", strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// DeviceResolver matches device references such as "phong khach",
+// "living room" or "dieu hoa" to configured device IDs
+type DeviceResolver struct {
+	devices []resolverDevice
+}
+
+// resolverDevice is a catalog entry with its normalized names
+type resolverDevice struct {
+	entry CatalogEntry
+	names []string // folded ID, name and aliases
+	words map[string]bool
+}
+
+// NewDeviceResolver creates a resolver for the devices in the configuration
+func NewDeviceResolver(config *Config) *DeviceResolver {
+	resolver := &DeviceResolver{}
+	for _, entry := range DeviceCatalog(config) {
+		device := resolverDevice{entry: entry, words: make(map[string]bool)}
+		for _, name := range append([]string{entry.ID, entry.Name}, entry.Aliases...) {
+			tokens := tokenize(name)
+			if len(tokens) == 0 {
+				continue
+			}
+			device.names = append(device.names, strings.Join(tokens, " "))
+			for _, token := range tokens {
+				device.words[token] = true
+			}
+		}
+		resolver.devices = append(resolver.devices, device)
+	}
+	return resolver
+}
+
+// Resolve returns the ID of the device a reference means. Devices supporting
+// the action are preferred. The reference is compared with IDs, names and
+// aliases ignoring case, separators and diacritics, then by words, then with
+// a small edit distance. It returns an *AmbiguousDeviceError when several
+// devices match equally well.
+func (r *DeviceResolver) Resolve(reference, action string) (string, error) {
+	for _, device := range r.devices {
+		if device.entry.ID == reference {
+			return reference, nil
+		}
+	}
+
+	tokens := tokenize(reference)
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("device not found: %s", reference)
+	}
+	normalized := strings.Join(tokens, " ")
+
+	candidates := make([]resolverDevice, 0, len(r.devices))
+	for _, device := range r.devices {
+		if containsString(device.entry.Actions, action) {
+			candidates = append(candidates, device)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = r.devices
+	}
+
+	tiers := []func(device resolverDevice) int{
+		// Same name once normalized
+		func(device resolverDevice) int {
+			if containsString(device.names, normalized) {
+				return 0
+			}
+			return -1
+		},
+		// Every word of the reference appears in the device's names
+		func(device resolverDevice) int {
+			for _, token := range tokens {
+				if !device.words[token] {
+					return -1
+				}
+			}
+			return 0
+		},
+		// Close spelling, e.g. "phong khac"
+		func(device resolverDevice) int {
+			best := -1
+			limit := len([]rune(normalized)) / 4
+			if limit < 1 {
+				limit = 1
+			}
+			for _, name := range device.names {
+				if d := editDistance(normalized, name); d <= limit && (best < 0 || d < best) {
+					best = d
+				}
+			}
+			return best
+		},
+	}
+
+	for _, score := range tiers {
+		best := -1
+		var matches []CatalogEntry
+		for _, device := range candidates {
+			d := score(device)
+			switch {
+			case d < 0 || (best >= 0 && d > best):
+			case best < 0 || d < best:
+				best = d
+				matches = []CatalogEntry{device.entry}
+			default:
+				matches = append(matches, device.entry)
+			}
+		}
+
+		if len(matches) == 1 {
+			return matches[0].ID, nil
+		}
+		if len(matches) > 1 {
+			return "", &AmbiguousDeviceError{Reference: reference, Candidates: matches}
+		}
+	}
+
+	return "", fmt.Errorf("device not found: %s", reference)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func newResolverTestConfig(t *testing.T) *Config {
+	config := newTestConfig(t)
+	light := config.Devices.Lights["phong_khach"]
+	light.Aliases = []string{"living room", "living_room_light"}
+	config.Devices.Lights["phong_khach"] = light
+	return config
+}
+
+func TestDeviceResolver(t *testing.T) {
+	resolver := NewDeviceResolver(newResolverTestConfig(t))
+
+	tests := []struct {
+		reference string
+		action    string
+		want      string
+	}{
+		{"phong_khach", "light.on", "phong_khach"},
+		{"Đèn Phòng Khách", "light.on", "phong_khach"},
+		{"den phong khach", "light.on", "phong_khach"},
+		{"living_room", "light.on", "phong_khach"},
+		{"Living Room Light", "light.off", "phong_khach"},
+		{"phong khach", "light.on", "phong_khach"}, // only one light in the living room
+		{"phong khach", "switch.off", "quat"},
+		{"dieu hoa", "ac.set_temp", "dieu_hoa"},
+		{"dieu-hoa-phong-khach", "ac.on", "dieu_hoa"},
+		{"den phong khac", "light.on", "phong_khach"}, // typo
+		{"robot", "vacuum.start", "robot"},
+	}
+
+	for _, tt := range tests {
+		got, err := resolver.Resolve(tt.reference, tt.action)
+		if err != nil {
+			t.Errorf("Resolve(%q, %s) error = %v", tt.reference, tt.action, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q, %s) = %s, want %s", tt.reference, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceResolverAmbiguous(t *testing.T) {
+	resolver := NewDeviceResolver(newResolverTestConfig(t))
+
+	_, err := resolver.Resolve("den", "light.on")
+	var ambiguous *AmbiguousDeviceError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Resolve() error = %v, want AmbiguousDeviceError", err)
+	}
+	if len(ambiguous.Candidates) != 3 {
+		t.Errorf("Candidates = %v", ambiguous.Candidates)
+	}
+	if question := ambiguous.Question(); question != "Bạn muốn nói Đèn Bếp, Đèn Phòng Khách hay Đèn Phòng Ngủ?" {
+		t.Errorf("Question() = %q", question)
+	}
+
+	// Without a matching action, "khach" fits the light, fan and AC
+	if _, err := resolver.Resolve("khach", "scene.activate"); !errors.As(err, &ambiguous) {
+		t.Errorf("Resolve() error = %v, want AmbiguousDeviceError", err)
+	}
+}
+
+func TestDeviceResolverNotFound(t *testing.T) {
+	resolver := NewDeviceResolver(newResolverTestConfig(t))
+
+	for _, reference := range []string{"", "garage door", "xyz"} {
+		if _, err := resolver.Resolve(reference, "light.on"); err == nil {
+			t.Errorf("Resolve(%q) expected an error", reference)
+		}
+	}
+}
+
+func TestRouterResolvesAliases(t *testing.T) {
+	router := newDryRunRouter(t)
+	router.config.Devices.Lights["phong_khach"] = DeviceInfo{
+		Type: "tapo", Model: "L530", IP: "192.168.1.10", Name: "Đèn Phòng Khách", Aliases: []string{"living room"},
+	}
+	router.resolver = NewDeviceResolver(router.config)
+
+	op, err := router.Resolve(&Command{Action: "light.on", Device: "living_room"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if op.Device != "phong_khach" {
+		t.Errorf("Operation device = %s, want phong_khach", op.Device)
+	}
+
+	executor := NewExecutor(NewSecurityManager(), router)
+	cmd := &Command{Action: "light.off", Device: "Đèn phòng khách"}
+	if err := executor.ExecuteCommand(cmd); err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+	if cmd.Device != "phong_khach" {
+		t.Errorf("Command device = %s, want the canonical ID", cmd.Device)
+	}
+}
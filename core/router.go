@@ -2,18 +2,30 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/truong-nautilus/smart-home-ai/devices"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Devices DevicesConfig `json:"devices"`
-	Claude  ClaudeConfig  `json:"claude"`
-	Audio   AudioConfig   `json:"audio"`
+	Devices  DevicesConfig  `json:"devices"`
+	Claude   ClaudeConfig   `json:"claude"`
+	Audio    AudioConfig    `json:"audio"`
+	Health   HealthConfig   `json:"health"`
+	STT      STTConfig      `json:"stt"`
+	TTS      TTSConfig      `json:"tts"`
+	WakeWord WakeWordConfig `json:"wake_word"`
+	VAD      VADConfig      `json:"vad"`
+	Capture  CaptureConfig  `json:"capture"`
+	Intent   IntentConfig   `json:"intent"`
+	Confirm  ConfirmConfig  `json:"confirm"`
+	MQTT     MQTTConfig     `json:"mqtt"`
+	Tapo     TapoConfig     `json:"tapo"`
 }
 
 // DevicesConfig holds all device configurations
@@ -31,6 +43,16 @@ type DeviceInfo struct {
 	IP    string `json:"ip"`
 	Topic string `json:"topic,omitempty"`
 	Name  string `json:"name"`
+
+	// Aliases are other names the device may be called, e.g. "living room"
+	Aliases []string `json:"aliases,omitempty"`
+	Area    string   `json:"area,omitempty"`
+
+	// Token is the 32-character hex miIO token of Xiaomi devices
+	Token string `json:"token,omitempty"`
+
+	// AvailabilityTopic overrides the MQTT availability topic (default: <topic>/availability)
+	AvailabilityTopic string `json:"availability_topic,omitempty"`
 }
 
 // IRDeviceInfo holds IR device information
@@ -39,15 +61,23 @@ type IRDeviceInfo struct {
 	DeviceIP string            `json:"device_ip"`
 	Commands map[string]string `json:"commands"`
 	Name     string            `json:"name"`
+	Aliases  []string          `json:"aliases,omitempty"`
+	Area     string            `json:"area,omitempty"`
 }
 
 // ClaudeConfig holds Claude configuration
 type ClaudeConfig struct {
 	Model        string  `json:"model"`
-	WebSocketURL string  `json:"websocket_url"`
+	APIURL       string  `json:"api_url"`
+	APIKey       string  `json:"api_key,omitempty"` // default: CLAUDE_API_KEY
 	MaxTokens    int     `json:"max_tokens"`
 	Temperature  float64 `json:"temperature"`
 	SystemPrompt string  `json:"system_prompt"`
+
+	// Conversation memory: the session resets after IdleResetSeconds without
+	// requests, and turns beyond MaxTurns are summarized
+	IdleResetSeconds int `json:"idle_reset_seconds,omitempty"`
+	MaxTurns         int `json:"max_turns,omitempty"`
 }
 
 // AudioConfig holds audio configuration
@@ -56,6 +86,134 @@ type AudioConfig struct {
 	Channels   int `json:"channels"`
 	BitDepth   int `json:"bit_depth"`
 	BufferSize int `json:"buffer_size"`
+
+	// Float captures 32-bit float samples; Native captures in the device's
+	// native format. Audio is converted to 16-bit PCM at SampleRate/Channels.
+	Float  bool `json:"float,omitempty"`
+	Native bool `json:"native,omitempty"`
+
+	Source AudioSourceConfig `json:"source"`
+	DSP    DSPConfig         `json:"dsp"`
+}
+
+// DSPConfig holds the audio preprocessing chain configuration. Zero values disable a stage.
+type DSPConfig struct {
+	EchoCancellation bool    `json:"echo_cancellation,omitempty"`
+	EchoTailMs       int     `json:"echo_tail_ms,omitempty"`
+	HighPassHz       float64 `json:"high_pass_hz,omitempty"`
+	NoiseSuppression bool    `json:"noise_suppression,omitempty"`
+	NoiseReductionDB float64 `json:"noise_reduction_db,omitempty"`
+	AGC              bool    `json:"agc,omitempty"`
+	AGCTargetDB      float64 `json:"agc_target_db,omitempty"`
+	AGCMaxGainDB     float64 `json:"agc_max_gain_db,omitempty"`
+	NoiseGateDB      float64 `json:"noise_gate_db,omitempty"`
+}
+
+// AudioSourceConfig selects where audio comes from
+type AudioSourceConfig struct {
+	Type     string `json:"type"` // mic, file, stdin, rtp or websocket
+	Path     string `json:"path,omitempty"`
+	Listen   string `json:"listen,omitempty"`
+	Token    string `json:"token,omitempty"` // websocket: token satellites must send
+	Realtime bool   `json:"realtime,omitempty"`
+}
+
+// STTConfig holds speech-to-text configuration
+type STTConfig struct {
+	Engine         string  `json:"engine"`
+	Binary         string  `json:"binary,omitempty"`
+	Model          string  `json:"model,omitempty"`
+	Language       string  `json:"language"`
+	Threads        int     `json:"threads,omitempty"`
+	SegmentSeconds float64 `json:"segment_seconds,omitempty"`
+}
+
+// TTSConfig holds text-to-speech configuration
+type TTSConfig struct {
+	Engine string  `json:"engine"`
+	Binary string  `json:"binary,omitempty"`
+	Model  string  `json:"model,omitempty"`
+	Voice  string  `json:"voice,omitempty"`
+	Speed  float64 `json:"speed,omitempty"`
+
+	// DuckGain is the microphone gain while speaking (0 mutes it)
+	DuckGain float64 `json:"duck_gain"`
+}
+
+// VADConfig holds voice activity detection configuration
+type VADConfig struct {
+	Engine          string  `json:"engine"`
+	Mode            int     `json:"mode"`
+	EnergyThreshold float64 `json:"energy_threshold,omitempty"`
+	PreRollMs       int     `json:"pre_roll_ms,omitempty"`
+	SilenceMs       int     `json:"silence_ms,omitempty"`
+	MinSpeechMs     int     `json:"min_speech_ms,omitempty"`
+	MaxUtteranceMs  int     `json:"max_utterance_ms,omitempty"`
+}
+
+// WakeWordConfig holds wake word configuration
+type WakeWordConfig struct {
+	Engine          string   `json:"engine"`
+	Command         []string `json:"command,omitempty"`
+	Keywords        []string `json:"keywords,omitempty"`
+	EnergyThreshold float64  `json:"energy_threshold,omitempty"`
+	WindowSeconds   float64  `json:"window_seconds"`
+
+	// Acknowledgement when the wake word is heard
+	AckSound   bool   `json:"ack_sound"`
+	AckTopic   string `json:"ack_topic,omitempty"`
+	AckPayload string `json:"ack_payload,omitempty"`
+}
+
+// IntentConfig controls the local intent parser. FastPath handles simple
+// device commands without Claude; Fallback uses the parser when Claude fails.
+type IntentConfig struct {
+	FastPath bool `json:"fast_path"`
+	Fallback bool `json:"fallback"`
+}
+
+// ConfirmConfig lists the risky commands Jarvis asks about before running.
+// A pending question is dropped after TimeoutSeconds.
+type ConfirmConfig struct {
+	Enabled        bool     `json:"enabled"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	Actions        []string `json:"actions,omitempty"`
+	MinDevicesOff  int      `json:"min_devices_off,omitempty"`
+	NightStart     int      `json:"night_start,omitempty"`
+	NightEnd       int      `json:"night_end,omitempty"`
+	NightMinTemp   float64  `json:"night_min_temp,omitempty"`
+}
+
+// MQTTConfig holds the MQTT broker connection. Empty fields fall back to
+// the MQTT_HOST, MQTT_PORT, MQTT_USER and MQTT_PASS environment variables.
+type MQTTConfig struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// TapoConfig holds the Tapo cloud account. Empty fields fall back to the
+// TAPO_USER and TAPO_PASS environment variables.
+type TapoConfig struct {
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// CaptureConfig holds utterance capture configuration, for debugging recognition
+type CaptureConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Dir        string `json:"dir,omitempty"`
+	MaxFiles   int    `json:"max_files,omitempty"`
+	MaxAgeDays int    `json:"max_age_days,omitempty"`
+}
+
+// HealthConfig holds device health monitoring configuration
+type HealthConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval_seconds"`
+	TimeoutSeconds  int  `json:"timeout_seconds"`
 }
 
 // Command represents a parsed command
@@ -63,33 +221,24 @@ type Command struct {
 	Action string      `json:"action"`
 	Device string      `json:"device"`
 	Value  interface{} `json:"value,omitempty"`
+
+	// DelayMs and Condition are used when the command is part of a batch
+	DelayMs   int    `json:"delay_ms,omitempty"`
+	Condition string `json:"condition,omitempty"`
 }
 
-// ParseCommand parses a command from text or JSON
+// ParseCommand parses a single command from text or JSON
 func ParseCommand(text string) (*Command, error) {
-	text = strings.TrimSpace(text)
-
-	// Try parsing as JSON
-	var cmd Command
-	if err := json.Unmarshal([]byte(text), &cmd); err == nil {
-		if cmd.Action != "" {
-			return &cmd, nil
-		}
+	batch, err := ParseBatch(text)
+	if err != nil {
+		return nil, err
 	}
 
-	// Try extracting JSON from text
-	start := strings.Index(text, "{")
-	end := strings.LastIndex(text, "}")
-	if start >= 0 && end > start {
-		jsonStr := text[start : end+1]
-		if err := json.Unmarshal([]byte(jsonStr), &cmd); err == nil {
-			if cmd.Action != "" {
-				return &cmd, nil
-			}
-		}
+	if len(batch.Commands) > 1 {
+		return nil, fmt.Errorf("text contains %d commands, use ParseBatch", len(batch.Commands))
 	}
 
-	return nil, fmt.Errorf("not a valid command")
+	return batch.Commands[0], nil
 }
 
 // CommandRouter routes commands to appropriate device controllers
@@ -99,7 +248,12 @@ type CommandRouter struct {
 	broadlink     map[string]*devices.BroadlinkDevice
 	mqttClient    *devices.MQTTClient
 	xiaomiDevices map[string]interface{}
-	httpDevices   map[string]*devices.HTTPDevice
+	resolver      *DeviceResolver
+	tapoConfig    devices.TapoConfig
+	dryRun        bool
+
+	// mu guards the configuration and drivers, which Reload replaces
+	mu sync.RWMutex
 }
 
 // NewCommandRouter creates a new command router
@@ -109,7 +263,7 @@ func NewCommandRouter(config *Config) *CommandRouter {
 		tapoDevices:   make(map[string]*devices.TapoDevice),
 		broadlink:     make(map[string]*devices.BroadlinkDevice),
 		xiaomiDevices: make(map[string]interface{}),
-		httpDevices:   make(map[string]*devices.HTTPDevice),
+		resolver:      NewDeviceResolver(config),
 	}
 }
 
@@ -117,34 +271,16 @@ func NewCommandRouter(config *Config) *CommandRouter {
 func (r *CommandRouter) Initialize(tapoConfig devices.TapoConfig, mqttConfig devices.MQTTConfig) error {
 	log.Println("Initializing device connections...")
 
-	// Initialize Tapo devices
-	for id, info := range r.config.Devices.Lights {
-		if info.Type == "tapo" {
-			device := devices.NewTapoDevice(info.IP, info.Model, tapoConfig)
-			r.tapoDevices[id] = device
-			log.Printf("Initialized Tapo device: %s (%s)", info.Name, id)
-		}
-	}
-
-	for id, info := range r.config.Devices.Switches {
-		if info.Type == "tapo" {
-			device := devices.NewTapoDevice(info.IP, info.Model, tapoConfig)
-			r.tapoDevices[id] = device
-			log.Printf("Initialized Tapo device: %s (%s)", info.Name, id)
-		}
-	}
-
-	// Initialize Broadlink devices
-	for id, info := range r.config.Devices.IRDevices {
-		if info.Type == "broadlink" {
-			device := devices.NewBroadlinkDevice(info.DeviceIP, 80)
-			r.broadlink[id] = device
-			log.Printf("Initialized Broadlink device: %s (%s)", info.Name, id)
-		}
-	}
+	// Initialize Tapo, Broadlink and Xiaomi devices
+	r.mu.Lock()
+	r.tapoConfig = tapoConfig
+	r.updateDrivers(r.config)
+	r.mu.Unlock()
 
 	// Initialize MQTT client
-	if mqttConfig.Host != "" {
+	if r.dryRun {
+		log.Println("Dry-run mode: skipping MQTT broker connection")
+	} else if mqttConfig.Host != "" {
 		r.mqttClient = devices.NewMQTTClient(mqttConfig)
 		if err := r.mqttClient.Connect(); err != nil {
 			log.Printf("Warning: Failed to connect to MQTT broker: %v", err)
@@ -157,134 +293,304 @@ func (r *CommandRouter) Initialize(tapoConfig devices.TapoConfig, mqttConfig dev
 	return nil
 }
 
+// updateDrivers creates the Tapo, Broadlink and Xiaomi drivers for a
+// configuration. A device whose connection settings are unchanged keeps
+// its current driver. The caller holds the lock.
+func (r *CommandRouter) updateDrivers(config *Config) {
+	tapo := make(map[string]*devices.TapoDevice)
+	addTapo := func(id string, info DeviceInfo, current map[string]DeviceInfo) {
+		if info.Type != "tapo" {
+			return
+		}
+		old, ok := current[id]
+		if device, exists := r.tapoDevices[id]; exists && ok && old.IP == info.IP && old.Model == info.Model {
+			tapo[id] = device
+			return
+		}
+		tapo[id] = devices.NewTapoDevice(info.IP, info.Model, r.tapoConfig)
+		log.Printf("Initialized Tapo device: %s (%s)", info.Name, id)
+	}
+
+	for id, info := range config.Devices.Lights {
+		addTapo(id, info, r.config.Devices.Lights)
+	}
+	for id, info := range config.Devices.Switches {
+		addTapo(id, info, r.config.Devices.Switches)
+	}
+
+	broadlink := make(map[string]*devices.BroadlinkDevice)
+	for id, info := range config.Devices.IRDevices {
+		if info.Type != "broadlink" {
+			continue
+		}
+		old, ok := r.config.Devices.IRDevices[id]
+		if device, exists := r.broadlink[id]; exists && ok && old.DeviceIP == info.DeviceIP {
+			broadlink[id] = device
+			continue
+		}
+		broadlink[id] = devices.NewBroadlinkDevice(info.DeviceIP, 80)
+		log.Printf("Initialized Broadlink device: %s (%s)", info.Name, id)
+	}
+
+	xiaomi := make(map[string]interface{})
+	for id, info := range config.Devices.Vacuum {
+		if info.Type != "xiaomi" {
+			continue
+		}
+		old, ok := r.config.Devices.Vacuum[id]
+		previous, exists := r.xiaomiDevices[id]
+		if exists && ok && old.IP == info.IP && old.Token == info.Token {
+			xiaomi[id] = previous
+			continue
+		}
+		if info.Token == "" {
+			if exists {
+				log.Printf("Warning: Xiaomi device %s has no token, keeping the previous driver", id)
+				xiaomi[id] = previous
+				continue
+			}
+			log.Printf("Warning: Xiaomi device %s has no token, its commands will fail", id)
+			continue
+		}
+		device, err := devices.NewVacuumRobot(info.IP, info.Token)
+		if err != nil {
+			if exists {
+				log.Printf("Warning: Failed to initialize Xiaomi device %s, keeping the previous driver: %v", id, err)
+				xiaomi[id] = previous
+				continue
+			}
+			log.Printf("Warning: Failed to initialize Xiaomi device %s: %v", id, err)
+			continue
+		}
+		xiaomi[id] = device
+		log.Printf("Initialized Xiaomi device: %s (%s)", info.Name, id)
+	}
+
+	r.tapoDevices, r.broadlink, r.xiaomiDevices = tapo, broadlink, xiaomi
+}
+
+// Reload switches to a new configuration, adding, removing and updating
+// device drivers. Commands already resolved finish on the driver they
+// resolved to. The MQTT connection is kept.
+func (r *CommandRouter) Reload(config *Config) ConfigDiff {
+	resolver := NewDeviceResolver(config)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	diff := DiffDevices(r.config, config)
+	r.updateDrivers(config)
+	r.config = config
+	r.resolver = resolver
+	return diff
+}
+
+// Operation is the exact wire-level operation a command resolves to
+type Operation struct {
+	Protocol string      `json:"protocol"`
+	Device   string      `json:"device"`
+	Address  string      `json:"address,omitempty"`
+	Method   string      `json:"method,omitempty"`
+	Params   interface{} `json:"params,omitempty"`
+	Topic    string      `json:"topic,omitempty"`
+	Payload  string      `json:"payload,omitempty"`
+
+	execute func() error
+}
+
+// String returns the operation as JSON
+func (op *Operation) String() string {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Sprintf("%s operation on %s", op.Protocol, op.Device)
+	}
+	return string(data)
+}
+
+// SetDryRun enables or disables dry-run mode. In dry-run mode commands are
+// resolved and logged but never sent to devices.
+func (r *CommandRouter) SetDryRun(dryRun bool) {
+	r.dryRun = dryRun
+}
+
+// IsDryRun returns whether the router is in dry-run mode
+func (r *CommandRouter) IsDryRun() bool {
+	return r.dryRun
+}
+
 // ExecuteCommand executes a command
 func (r *CommandRouter) ExecuteCommand(cmd *Command) error {
 	log.Printf("Executing command: action=%s, device=%s, value=%v", cmd.Action, cmd.Device, cmd.Value)
 
+	op, err := r.Resolve(cmd)
+	if err != nil {
+		return err
+	}
+
+	if r.dryRun {
+		log.Printf("[DRY-RUN] %s", op)
+		return nil
+	}
+
+	return op.execute()
+}
+
+// ResolveDevice returns the ID of the device a reference means, matching IDs,
+// names and aliases loosely. See DeviceResolver.Resolve.
+func (r *CommandRouter) ResolveDevice(reference, action string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resolver.Resolve(reference, action)
+}
+
+// Resolve validates a command and resolves it to the wire-level operation
+// without touching the network
+func (r *CommandRouter) Resolve(cmd *Command) (*Operation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	parts := strings.Split(cmd.Action, ".")
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid action format: %s", cmd.Action)
+		return nil, fmt.Errorf("invalid action format: %s", cmd.Action)
 	}
 
 	deviceType := parts[0]
 	action := parts[1]
 
+	// Unknown devices are left to the type-specific resolvers to report
+	deviceID, err := r.resolver.Resolve(cmd.Device, cmd.Action)
+	var ambiguous *AmbiguousDeviceError
+	if errors.As(err, &ambiguous) {
+		return nil, err
+	} else if err != nil {
+		deviceID = cmd.Device
+	}
+	if deviceID != cmd.Device {
+		log.Printf("Resolved device %q to %s", cmd.Device, deviceID)
+	}
+
 	switch deviceType {
 	case "light":
-		return r.executeLight(cmd.Device, action, cmd.Value)
+		return r.resolveLight(deviceID, action, cmd.Value)
 	case "switch":
-		return r.executeSwitch(cmd.Device, action, cmd.Value)
+		return r.resolveSwitch(deviceID, action, cmd.Value)
 	case "ac":
-		return r.executeAC(cmd.Device, action, cmd.Value)
+		return r.resolveAC(deviceID, action, cmd.Value)
 	case "vacuum":
-		return r.executeVacuum(cmd.Device, action, cmd.Value)
+		return r.resolveVacuum(deviceID, action, cmd.Value)
 	case "tv":
-		return r.executeTV(cmd.Device, action, cmd.Value)
+		return r.resolveTV(deviceID, action, cmd.Value)
 	default:
-		return fmt.Errorf("unknown device type: %s", deviceType)
+		return nil, fmt.Errorf("unknown device type: %s", deviceType)
 	}
 }
 
-// executeLight executes light commands
-func (r *CommandRouter) executeLight(deviceID, action string, value interface{}) error {
+// resolveLight resolves light commands
+func (r *CommandRouter) resolveLight(deviceID, action string, value interface{}) (*Operation, error) {
 	// Check if it's a Tapo device
 	if device, ok := r.tapoDevices[deviceID]; ok {
+		var req devices.TapoRequest
+		var err error
+
 		switch action {
 		case "on":
-			return device.TurnOn()
+			req = devices.TapoPowerRequest(true)
 		case "off":
-			return device.TurnOff()
+			req = devices.TapoPowerRequest(false)
 		case "brightness":
-			if brightness, ok := value.(float64); ok {
-				return device.SetBrightness(int(brightness))
+			brightness, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid brightness value")
 			}
-			return fmt.Errorf("invalid brightness value")
+			req, err = devices.TapoBrightnessRequest(int(brightness))
 		case "color":
-			if colorMap, ok := value.(map[string]interface{}); ok {
-				hue := int(colorMap["hue"].(float64))
-				sat := int(colorMap["saturation"].(float64))
-				return device.SetColor(hue, sat)
+			colorMap, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid color value")
 			}
-			return fmt.Errorf("invalid color value")
+			hue, hueOK := colorMap["hue"].(float64)
+			sat, satOK := colorMap["saturation"].(float64)
+			if !hueOK || !satOK {
+				return nil, fmt.Errorf("invalid color value")
+			}
+			req, err = devices.TapoColorRequest(int(hue), int(sat))
 		case "color_temp":
-			if temp, ok := value.(float64); ok {
-				return device.SetColorTemp(int(temp))
+			temp, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid color temperature value")
 			}
-			return fmt.Errorf("invalid color temperature value")
+			req, err = devices.TapoColorTempRequest(int(temp))
 		default:
-			return fmt.Errorf("unknown light action: %s", action)
+			return nil, fmt.Errorf("unknown light action: %s", action)
+		}
+
+		if err != nil {
+			return nil, err
 		}
+		return tapoOperation(deviceID, device, req), nil
 	}
 
 	// Check if it's an MQTT device
 	if info, ok := r.config.Devices.Lights[deviceID]; ok && info.Type == "mqtt" {
-		if r.mqttClient == nil {
-			return fmt.Errorf("MQTT client not initialized")
-		}
+		var msg devices.MQTTMessage
 
 		switch action {
 		case "on":
-			return r.mqttClient.TurnOnLight(info.Topic)
+			msg = devices.LightPowerMessage(info.Topic, true)
 		case "off":
-			return r.mqttClient.TurnOffLight(info.Topic)
+			msg = devices.LightPowerMessage(info.Topic, false)
 		case "brightness":
-			if brightness, ok := value.(float64); ok {
-				return r.mqttClient.SetBrightness(info.Topic, int(brightness))
+			brightness, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid brightness value")
 			}
-			return fmt.Errorf("invalid brightness value")
+			msg = devices.BrightnessMessage(info.Topic, int(brightness))
 		default:
-			return fmt.Errorf("unknown light action: %s", action)
+			return nil, fmt.Errorf("unknown light action: %s", action)
 		}
+
+		return r.mqttOperation(deviceID, msg), nil
 	}
 
-	return fmt.Errorf("device not found: %s", deviceID)
+	return nil, fmt.Errorf("device not found: %s", deviceID)
 }
 
-// executeSwitch executes switch commands
-func (r *CommandRouter) executeSwitch(deviceID, action string, value interface{}) error {
+// resolveSwitch resolves switch commands
+func (r *CommandRouter) resolveSwitch(deviceID, action string, value interface{}) (*Operation, error) {
 	// Check if it's a Tapo device
 	if device, ok := r.tapoDevices[deviceID]; ok {
 		switch action {
 		case "on":
-			return device.TurnOn()
+			return tapoOperation(deviceID, device, devices.TapoPowerRequest(true)), nil
 		case "off":
-			return device.TurnOff()
+			return tapoOperation(deviceID, device, devices.TapoPowerRequest(false)), nil
 		default:
-			return fmt.Errorf("unknown switch action: %s", action)
+			return nil, fmt.Errorf("unknown switch action: %s", action)
 		}
 	}
 
 	// Check if it's an MQTT device
 	if info, ok := r.config.Devices.Switches[deviceID]; ok && info.Type == "mqtt" {
-		if r.mqttClient == nil {
-			return fmt.Errorf("MQTT client not initialized")
-		}
-
 		switch action {
 		case "on":
-			return r.mqttClient.TurnOnSwitch(info.Topic)
+			return r.mqttOperation(deviceID, devices.SwitchPowerMessage(info.Topic, true)), nil
 		case "off":
-			return r.mqttClient.TurnOffSwitch(info.Topic)
+			return r.mqttOperation(deviceID, devices.SwitchPowerMessage(info.Topic, false)), nil
 		case "toggle":
-			return r.mqttClient.ToggleSwitch(info.Topic)
+			return r.mqttOperation(deviceID, devices.SwitchToggleMessage(info.Topic)), nil
 		default:
-			return fmt.Errorf("unknown switch action: %s", action)
+			return nil, fmt.Errorf("unknown switch action: %s", action)
 		}
 	}
 
-	return fmt.Errorf("device not found: %s", deviceID)
+	return nil, fmt.Errorf("device not found: %s", deviceID)
 }
 
-// executeAC executes air conditioner commands
-func (r *CommandRouter) executeAC(deviceID, action string, value interface{}) error {
+// resolveAC resolves air conditioner commands
+func (r *CommandRouter) resolveAC(deviceID, action string, value interface{}) (*Operation, error) {
 	info, ok := r.config.Devices.IRDevices[deviceID]
 	if !ok {
-		return fmt.Errorf("AC device not found: %s", deviceID)
-	}
-
-	device, ok := r.broadlink[deviceID]
-	if !ok {
-		return fmt.Errorf("Broadlink device not initialized: %s", deviceID)
+		return nil, fmt.Errorf("AC device not found: %s", deviceID)
 	}
 
 	var irCode string
@@ -298,83 +604,135 @@ func (r *CommandRouter) executeAC(deviceID, action string, value interface{}) er
 			tempKey := fmt.Sprintf("temp_%d", int(temp))
 			irCode = info.Commands[tempKey]
 		} else {
-			return fmt.Errorf("invalid temperature value")
+			return nil, fmt.Errorf("invalid temperature value")
 		}
 	default:
 		// Try to find command in device commands
 		if cmd, ok := info.Commands[action]; ok {
 			irCode = cmd
 		} else {
-			return fmt.Errorf("unknown AC action: %s", action)
+			return nil, fmt.Errorf("unknown AC action: %s", action)
 		}
 	}
 
 	if irCode == "" {
-		return fmt.Errorf("IR code not found for action: %s", action)
+		return nil, fmt.Errorf("IR code not found for action: %s", action)
 	}
 
-	return device.SendIRCommand(irCode)
+	return r.irOperation(deviceID, info, irCode), nil
 }
 
-// executeVacuum executes vacuum commands
-func (r *CommandRouter) executeVacuum(deviceID, action string, value interface{}) error {
+// resolveVacuum resolves vacuum commands
+func (r *CommandRouter) resolveVacuum(deviceID, action string, value interface{}) (*Operation, error) {
 	info, ok := r.config.Devices.Vacuum[deviceID]
 	if !ok {
-		return fmt.Errorf("vacuum device not found: %s", deviceID)
+		return nil, fmt.Errorf("vacuum device not found: %s", deviceID)
 	}
 
 	if info.Type != "xiaomi" {
-		return fmt.Errorf("unsupported vacuum type: %s", info.Type)
+		return nil, fmt.Errorf("unsupported vacuum type: %s", info.Type)
 	}
 
-	// Get or create Xiaomi vacuum device
-	var vacuum *devices.VacuumRobot
-	if dev, ok := r.xiaomiDevices[deviceID]; ok {
-		vacuum = dev.(*devices.VacuumRobot)
+	var miio devices.MiioCommand
+	if action == "fan_speed" {
+		speed, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("invalid fan speed value")
+		}
+		miio = devices.VacuumFanSpeedCommand(int(speed))
 	} else {
-		// Note: Token should be from environment or config
-		return fmt.Errorf("xiaomi device not initialized: %s", deviceID)
-	}
-
-	switch action {
-	case "start":
-		return vacuum.Start()
-	case "stop":
-		return vacuum.Stop()
-	case "pause":
-		return vacuum.Pause()
-	case "home":
-		return vacuum.Home()
-	case "spot":
-		return vacuum.Spot()
-	case "fan_speed":
-		if speed, ok := value.(float64); ok {
-			return vacuum.SetFanSpeed(int(speed))
+		var err error
+		if miio, err = devices.VacuumActionCommand(action); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("invalid fan speed value")
-	default:
-		return fmt.Errorf("unknown vacuum action: %s", action)
 	}
+
+	dev, ok := r.xiaomiDevices[deviceID]
+	return &Operation{
+		Protocol: "miio",
+		Device:   deviceID,
+		Address:  info.IP,
+		Method:   miio.Method,
+		Params:   miio.Params,
+		execute: func() error {
+			if !ok {
+				return fmt.Errorf("xiaomi device not initialized: %s (check its token)", deviceID)
+			}
+			return dev.(*devices.VacuumRobot).Execute(miio)
+		},
+	}, nil
 }
 
-// executeTV executes TV commands via IR
-func (r *CommandRouter) executeTV(deviceID, action string, value interface{}) error {
+// resolveTV resolves TV commands via IR
+func (r *CommandRouter) resolveTV(deviceID, action string, value interface{}) (*Operation, error) {
 	info, ok := r.config.Devices.IRDevices[deviceID]
 	if !ok {
-		return fmt.Errorf("TV device not found: %s", deviceID)
+		return nil, fmt.Errorf("TV device not found: %s", deviceID)
 	}
 
-	device, ok := r.broadlink[deviceID]
+	irCode, ok := info.Commands[action]
 	if !ok {
-		return fmt.Errorf("Broadlink device not initialized: %s", deviceID)
+		return nil, fmt.Errorf("IR code not found for action: %s", action)
 	}
 
-	irCode, ok := info.Commands[action]
-	if !ok {
-		return fmt.Errorf("IR code not found for action: %s", action)
+	return r.irOperation(deviceID, info, irCode), nil
+}
+
+// tapoOperation wraps a Tapo request
+func tapoOperation(deviceID string, device *devices.TapoDevice, req devices.TapoRequest) *Operation {
+	return &Operation{
+		Protocol: "tapo",
+		Device:   deviceID,
+		Address:  device.IP,
+		Method:   req.Method,
+		Params:   req.Params,
+		execute: func() error {
+			return device.Execute(req)
+		},
 	}
+}
 
-	return device.SendIRCommand(irCode)
+// mqttOperation wraps an MQTT publish
+func (r *CommandRouter) mqttOperation(deviceID string, msg devices.MQTTMessage) *Operation {
+	return &Operation{
+		Protocol: "mqtt",
+		Device:   deviceID,
+		Topic:    msg.Topic,
+		Payload:  msg.Payload,
+		execute: func() error {
+			if r.mqttClient == nil {
+				return fmt.Errorf("MQTT client not initialized")
+			}
+			return r.mqttClient.PublishMessage(msg)
+		},
+	}
+}
+
+// irOperation wraps a Broadlink IR transmission
+func (r *CommandRouter) irOperation(deviceID string, info IRDeviceInfo, irCode string) *Operation {
+	device, ok := r.broadlink[deviceID]
+	return &Operation{
+		Protocol: "broadlink",
+		Device:   deviceID,
+		Address:  info.DeviceIP,
+		Payload:  irCode,
+		execute: func() error {
+			if !ok {
+				return fmt.Errorf("Broadlink device not initialized: %s", deviceID)
+			}
+			return device.SendIRCommand(irCode)
+		},
+	}
+}
+
+// Publish sends a raw MQTT message, e.g. to drive a status LED
+func (r *CommandRouter) Publish(topic string, payload string) error {
+	op := r.mqttOperation("", devices.MQTTMessage{Topic: topic, Payload: payload})
+	if r.dryRun {
+		log.Printf("[DRY-RUN] %s", op)
+		return nil
+	}
+	return op.execute()
 }
 
 // Close closes all device connections
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"path/filepath"
 	"testing"
+
+	"github.com/truong-nautilus/smart-home-ai/devices"
 )
 
 func TestParseCommand(t *testing.T) {
@@ -33,12 +36,143 @@ func TestParseCommand(t *testing.T) {
 }
 
 func TestSecurityManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     Command
+		wantErr bool
+	}{
+		{"Light on", Command{Action: "light.on", Device: "test"}, false},
+		{"Brightness 1", Command{Action: "light.brightness", Device: "test", Value: float64(1)}, false},
+		{"Brightness 0", Command{Action: "light.brightness", Device: "test", Value: float64(0)}, true},
+		{"Vacuum spot", Command{Action: "vacuum.spot", Device: "test"}, false},
+		{"Fan speed", Command{Action: "vacuum.fan_speed", Device: "test", Value: float64(60)}, false},
+		{"Fan speed 0", Command{Action: "vacuum.fan_speed", Device: "test", Value: float64(0)}, true},
+		{"Fan speed 101", Command{Action: "vacuum.fan_speed", Device: "test", Value: float64(101)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSecurityManager().ValidateCommand(&tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCatalogActionsAllowed(t *testing.T) {
 	security := NewSecurityManager()
-	cmd := &Command{
-		Action: "light.on",
-		Device: "test",
+	for _, actions := range [][]string{tapoLightActions, mqttLightActions, tapoSwitchActions, mqttSwitchActions, xiaomiVacuumActions} {
+		for _, action := range actions {
+			if !security.IsCommandAllowed(action) {
+				t.Errorf("Catalogue action %s is not allowed", action)
+			}
+		}
+	}
+}
+
+// newTestConfig loads the device fixture shared by the core tests. Each
+// call returns a fresh copy that a test may change.
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	config, err := LoadConfig(filepath.Join("testdata", "devices.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return config
+}
+
+func newDryRunRouter(t *testing.T) *CommandRouter {
+	t.Helper()
+
+	config := newTestConfig(t)
+	router := NewCommandRouter(config)
+	router.SetDryRun(true)
+	if err := router.Initialize(devices.TapoConfig{}, devices.MQTTConfig{}); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	return router
+}
+
+func TestResolveOperations(t *testing.T) {
+	router := newDryRunRouter(t)
+
+	tests := []struct {
+		name     string
+		cmd      Command
+		protocol string
+		method   string
+		topic    string
+		payload  string
+	}{
+		{"Tapo on", Command{Action: "light.on", Device: "phong_khach"}, "tapo", "set_device_info", "", ""},
+		{"Tapo brightness", Command{Action: "light.brightness", Device: "phong_khach", Value: float64(80)}, "tapo", "set_device_info", "", ""},
+		{"MQTT light off", Command{Action: "light.off", Device: "bep"}, "mqtt", "", "home/kitchen/light/set", "OFF"},
+		{"MQTT switch toggle", Command{Action: "switch.toggle", Device: "quat"}, "mqtt", "", "home/fan/relay/0/command", "toggle"},
+		{"IR set temp", Command{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(26)}, "broadlink", "", "", "2600bb"},
+		{"IR tv power", Command{Action: "tv.power", Device: "tv"}, "broadlink", "", "", "2600cc"},
+		{"Vacuum start", Command{Action: "vacuum.start", Device: "robot"}, "miio", "app_start", "", ""},
+		{"Vacuum home", Command{Action: "vacuum.home", Device: "robot"}, "miio", "app_charge", "", ""},
+		{"Vacuum spot", Command{Action: "vacuum.spot", Device: "robot"}, "miio", "app_spot", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := router.Resolve(&tt.cmd)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if op.Protocol != tt.protocol || op.Method != tt.method || op.Topic != tt.topic || op.Payload != tt.payload {
+				t.Errorf("Resolve() = %s", op)
+			}
+		})
 	}
-	if err := security.ValidateCommand(cmd); err != nil {
-		t.Errorf("ValidateCommand() error = %v", err)
+}
+
+func TestResolveTapoBody(t *testing.T) {
+	router := newDryRunRouter(t)
+
+	op, err := router.Resolve(&Command{Action: "light.color", Device: "phong_khach", Value: map[string]interface{}{"hue": float64(120), "saturation": float64(50)}})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	want := `{"protocol":"tapo","device":"phong_khach","address":"192.168.1.10","method":"set_device_info","params":{"device_on":true,"hue":120,"saturation":50}}`
+	if op.String() != want {
+		t.Errorf("Resolve() = %s, want %s", op, want)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	router := newDryRunRouter(t)
+
+	tests := []struct {
+		name string
+		cmd  Command
+	}{
+		{"Unknown device", Command{Action: "light.on", Device: "missing"}},
+		{"Missing IR code", Command{Action: "ac.set_temp", Device: "dieu_hoa", Value: float64(22)}},
+		{"Brightness out of range", Command{Action: "light.brightness", Device: "phong_khach", Value: float64(0)}},
+		{"Color without saturation", Command{Action: "light.color", Device: "phong_khach", Value: map[string]interface{}{"hue": float64(10)}}},
+		{"Unknown vacuum action", Command{Action: "vacuum.dance", Device: "robot"}},
+		{"Bad action format", Command{Action: "light", Device: "phong_khach"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := router.Resolve(&tt.cmd); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestDryRunExecuteDoesNotSend(t *testing.T) {
+	router := newDryRunRouter(t)
+
+	// The MQTT client is never connected in dry-run mode, so a real send would fail
+	if err := router.ExecuteCommand(&Command{Action: "light.on", Device: "bep"}); err != nil {
+		t.Errorf("ExecuteCommand() error = %v", err)
 	}
 }
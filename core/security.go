@@ -12,11 +12,20 @@ import (
 // SecurityManager handles security features
 type SecurityManager struct {
 	allowedCommands map[string]bool
+	valueRanges     map[string]ValueRange
 	rateLimit       *RateLimiter
+	confirm         ConfirmPolicy
 	commandLog      []CommandLog
 	mu              sync.RWMutex
 }
 
+// ValueRange is the accepted numeric range of a command value
+type ValueRange struct {
+	Min  float64
+	Max  float64
+	Unit string
+}
+
 // CommandLog logs executed commands
 type CommandLog struct {
 	Timestamp time.Time
@@ -53,10 +62,17 @@ func NewSecurityManager() *SecurityManager {
 			"vacuum.stop":      true,
 			"vacuum.pause":     true,
 			"vacuum.home":      true,
+			"vacuum.spot":      true,
+			"vacuum.fan_speed": true,
 			"tv.power":         true,
 			"tv.vol_up":        true,
 			"tv.vol_down":      true,
 		},
+		valueRanges: map[string]ValueRange{
+			"light.brightness": {Min: 1, Max: 100, Unit: "%"},
+			"ac.set_temp":      {Min: 16, Max: 30, Unit: "°C"},
+			"vacuum.fan_speed": {Min: 1, Max: 100, Unit: "%"},
+		},
 		rateLimit:  NewRateLimiter(10, 1*time.Minute),
 		commandLog: make([]CommandLog, 0),
 	}
@@ -123,25 +139,62 @@ func (sm *SecurityManager) ValidateCommand(cmd *Command) error {
 
 // validateDeviceCommand validates device-specific commands
 func (sm *SecurityManager) validateDeviceCommand(cmd *Command) error {
-	// Validate brightness range
-	if cmd.Action == "light.brightness" {
-		if brightness, ok := cmd.Value.(float64); ok {
-			if brightness < 0 || brightness > 100 {
-				return fmt.Errorf("brightness must be between 0 and 100")
+	// Validate value ranges (brightness, temperature, ...)
+	if r, ok := sm.valueRanges[cmd.Action]; ok {
+		if value, ok := cmd.Value.(float64); ok {
+			if value < r.Min || value > r.Max {
+				return fmt.Errorf("%s must be between %g and %g", valueName(cmd.Action), r.Min, r.Max)
 			}
 		}
 	}
 
-	// Validate temperature range
-	if cmd.Action == "ac.set_temp" {
-		if temp, ok := cmd.Value.(float64); ok {
-			if temp < 16 || temp > 30 {
-				return fmt.Errorf("temperature must be between 16 and 30")
-			}
-		}
+	return nil
+}
+
+// valueName returns a readable name for the value of an action
+func valueName(action string) string {
+	switch action {
+	case "light.brightness":
+		return "brightness"
+	case "ac.set_temp":
+		return "temperature"
+	case "vacuum.fan_speed":
+		return "fan speed"
+	default:
+		return "value"
 	}
+}
 
-	return nil
+// ValueRange returns the accepted value range of an action, if it has one
+func (sm *SecurityManager) ValueRange(action string) (ValueRange, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	r, ok := sm.valueRanges[action]
+	return r, ok
+}
+
+// SetValueRange sets the accepted value range of an action
+func (sm *SecurityManager) SetValueRange(action string, r ValueRange) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.valueRanges[action] = r
+	log.Printf("[SECURITY] Value range for %s: %g-%g", action, r.Min, r.Max)
+}
+
+// SetConfirmPolicy sets which commands need the user's confirmation
+func (sm *SecurityManager) SetConfirmPolicy(policy ConfirmPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.confirm = policy
+	log.Printf("[SECURITY] Confirmation required for: actions %v, %d+ devices off, AC below %g°C at night", policy.Actions, policy.MinDevicesOff, policy.NightMinTemp)
+}
+
+// ConfirmationReason returns why a batch must be confirmed before it runs,
+// or "" if it may run right away. ValidateCommand still applies afterwards.
+func (sm *SecurityManager) ConfirmationReason(batch *Batch, now time.Time) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.confirm.reason(batch, now)
 }
 
 // LogCommand logs a command execution
@@ -0,0 +1,342 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigError is a problem at a path in the configuration, e.g.
+// devices.lights.bep.type
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+// Error returns the path and the problem
+func (e ConfigError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ConfigErrors lists every problem found in a configuration
+type ConfigErrors []ConfigError
+
+// Error joins the problems into one message
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	parts := make([]string, len(e))
+	for i, err := range e {
+		parts[i] = err.Error()
+	}
+	return fmt.Sprintf("%d configuration errors: %s", len(e), strings.Join(parts, "; "))
+}
+
+// deviceSchemas lists the supported types of each device section and the
+// fields each type requires, mirroring CommandRouter.Initialize and Resolve
+var deviceSchemas = map[string]map[string][]string{
+	"lights":     {"tapo": {"ip"}, "mqtt": {"topic"}},
+	"switches":   {"tapo": {"ip"}, "mqtt": {"topic"}},
+	"ir_devices": {"broadlink": {"device_ip", "commands"}},
+	"vacuum":     {"xiaomi": {"ip"}},
+}
+
+// Accepted values of the enumerated settings. An empty engine disables the stage.
+var (
+	audioSourceTypes = []string{"", "mic", "file", "stdin", "rtp", "websocket"}
+	sttEngines       = []string{"", "whisper", "vosk", "fake"}
+	ttsEngines       = []string{"", "piper", "espeak", "fake"}
+	wakeWordEngines  = []string{"", "external", "keyword"}
+	vadEngines       = []string{"", "energy", "adaptive", "webrtc"}
+)
+
+// hostnamePattern matches DNS host names
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// Validate checks the configuration for mistakes that would otherwise only
+// show up at runtime: unknown device types, missing addresses, device IDs
+// used twice, unusable IR codes and out-of-range settings. It returns
+// ConfigErrors listing every problem, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.devices(&c.Devices)
+	v.claude(&c.Claude)
+	v.audio(&c.Audio)
+	v.engines(c)
+	v.confirm(&c.Confirm)
+	if c.MQTT.Port < 0 || c.MQTT.Port > 65535 {
+		v.add("mqtt.port", "must be a port number")
+	}
+
+	v.nonNegative("capture.max_files", c.Capture.MaxFiles)
+	v.nonNegative("capture.max_age_days", c.Capture.MaxAgeDays)
+	v.nonNegative("health.interval_seconds", c.Health.IntervalSeconds)
+	v.nonNegative("health.timeout_seconds", c.Health.TimeoutSeconds)
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Path < v.errors[j].Path
+	})
+	return v.errors
+}
+
+// validator collects configuration errors
+type validator struct {
+	errors ConfigErrors
+}
+
+// add records a problem at a path
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// devices checks every device section against its schema and looks for
+// device IDs and aliases used more than once
+func (v *validator) devices(config *DevicesConfig) {
+	owners := make(map[string]string) // device ID -> path of its definition
+
+	define := func(section, id string) string {
+		path := "devices." + section + "." + id
+		if other, ok := owners[id]; ok {
+			v.add(path, "duplicate device ID, also defined at %s", other)
+		} else {
+			owners[id] = path
+		}
+		if strings.TrimSpace(id) == "" {
+			v.add(path, "device ID must not be empty")
+		}
+		return path
+	}
+
+	for _, section := range []string{"lights", "switches", "vacuum"} {
+		var infos map[string]DeviceInfo
+		switch section {
+		case "lights":
+			infos = config.Lights
+		case "switches":
+			infos = config.Switches
+		case "vacuum":
+			infos = config.Vacuum
+		}
+		for _, id := range sortedKeys(infos) {
+			info := infos[id]
+			path := define(section, id)
+			fields := map[string]string{"ip": info.IP, "topic": info.Topic}
+			v.deviceType(path, section, info.Type, fields)
+			if token, err := hex.DecodeString(info.Token); info.Token != "" && (err != nil || len(token) != 16) {
+				v.add(path+".token", "must be 32 hex characters")
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(config.IRDevices) {
+		info := config.IRDevices[id]
+		path := define("ir_devices", id)
+		fields := map[string]string{"device_ip": info.DeviceIP}
+		if len(info.Commands) > 0 {
+			fields["commands"] = "set"
+		}
+		if v.deviceType(path, "ir_devices", info.Type, fields) {
+			v.irCommands(path, info.Commands)
+		}
+	}
+
+	// Aliases must not point at two devices
+	aliases := make(map[string]string)
+	for _, entry := range DeviceCatalog(&Config{Devices: *config}) {
+		aliases[strings.Join(tokenize(entry.ID), " ")] = entry.ID
+	}
+	for _, entry := range DeviceCatalog(&Config{Devices: *config}) {
+		for i, alias := range entry.Aliases {
+			path := fmt.Sprintf("%s.aliases[%d]", owners[entry.ID], i)
+			key := strings.Join(tokenize(alias), " ")
+			if key == "" {
+				v.add(path, "alias must not be empty")
+				continue
+			}
+			if other, ok := aliases[key]; ok && other != entry.ID {
+				v.add(path, "alias %q already refers to device %s", alias, other)
+				continue
+			}
+			aliases[key] = entry.ID
+		}
+	}
+}
+
+// deviceType checks a device's type and the fields the type requires. It
+// returns false if the type is unknown.
+func (v *validator) deviceType(path, section, deviceType string, fields map[string]string) bool {
+	schema := deviceSchemas[section]
+	required, ok := schema[deviceType]
+	if !ok {
+		v.add(path+".type", "unknown type %q (expected %s)", deviceType, strings.Join(sortedKeys(schema), " or "))
+		return false
+	}
+
+	for _, field := range required {
+		value := fields[field]
+		switch {
+		case value == "":
+			v.add(path+"."+field, "required for %s devices", deviceType)
+		case field == "ip" || field == "device_ip":
+			if net.ParseIP(value) == nil && !hostnamePattern.MatchString(value) {
+				v.add(path+"."+field, "%q is not an IP address or host name", value)
+			}
+		}
+	}
+	return true
+}
+
+// irCommands checks that IR codes are hex and that an AC can be turned on
+func (v *validator) irCommands(path string, commands map[string]string) {
+	needsOn := false
+	for _, key := range sortedKeys(commands) {
+		code := commands[key]
+		keyPath := path + ".commands." + key
+		if _, err := hex.DecodeString(code); err != nil || code == "" {
+			v.add(keyPath, "IR code must be a hex string")
+		}
+
+		if strings.HasPrefix(key, "temp_") {
+			if _, err := strconv.Atoi(strings.TrimPrefix(key, "temp_")); err != nil {
+				v.add(keyPath, "temperature code must be named temp_<degrees>")
+			}
+			needsOn = true
+		}
+		if key == "off" {
+			needsOn = true
+		}
+	}
+
+	if needsOn && commands["on"] == "" {
+		v.add(path+".commands.on", "required when off or temperature codes are configured")
+	}
+}
+
+// claude checks the Claude API settings
+func (v *validator) claude(config *ClaudeConfig) {
+	if config.APIURL != "" {
+		if u, err := url.Parse(config.APIURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			v.add("claude.api_url", "%q is not an HTTP(S) URL", config.APIURL)
+		}
+	}
+	v.nonNegative("claude.max_tokens", config.MaxTokens)
+	if config.Temperature < 0 || config.Temperature > 1 {
+		v.add("claude.temperature", "must be between 0 and 1")
+	}
+	v.nonNegative("claude.idle_reset_seconds", config.IdleResetSeconds)
+	v.nonNegative("claude.max_turns", config.MaxTurns)
+}
+
+// audio checks the capture format and audio source
+func (v *validator) audio(config *AudioConfig) {
+	v.nonNegative("audio.sample_rate", config.SampleRate)
+	if config.Channels != 0 && config.Channels != 1 && config.Channels != 2 {
+		v.add("audio.channels", "must be 1 or 2")
+	}
+	if config.BitDepth != 0 && config.BitDepth != 16 {
+		v.add("audio.bit_depth", "only 16-bit audio is supported")
+	}
+	v.nonNegative("audio.buffer_size", config.BufferSize)
+
+	source := config.Source
+	if v.oneOf("audio.source.type", source.Type, audioSourceTypes) {
+		switch source.Type {
+		case "file":
+			if source.Path == "" {
+				v.add("audio.source.path", "required for file sources")
+			}
+		case "rtp", "websocket":
+			if source.Listen == "" {
+				v.add("audio.source.listen", "required for %s sources", source.Type)
+			}
+		}
+		if source.Type == "websocket" && source.Token == "" {
+			v.add("audio.source.token", "required for websocket sources")
+		}
+	}
+}
+
+// engines checks the speech engines and what they depend on
+func (v *validator) engines(c *Config) {
+	v.oneOf("stt.engine", c.STT.Engine, sttEngines)
+	v.oneOf("tts.engine", c.TTS.Engine, ttsEngines)
+
+	if v.oneOf("wake_word.engine", c.WakeWord.Engine, wakeWordEngines) {
+		switch c.WakeWord.Engine {
+		case "external":
+			if len(c.WakeWord.Command) == 0 {
+				v.add("wake_word.command", "required for the external engine")
+			}
+		case "keyword":
+			if c.STT.Engine == "" {
+				v.add("wake_word.engine", "keyword detection requires stt.engine")
+			}
+			if len(c.WakeWord.Keywords) == 0 {
+				v.add("wake_word.keywords", "required for the keyword engine")
+			}
+		}
+	}
+
+	if v.oneOf("vad.engine", c.VAD.Engine, vadEngines) && (c.VAD.Engine == "adaptive" || c.VAD.Engine == "webrtc") {
+		if c.VAD.Mode < 0 || c.VAD.Mode > 3 {
+			v.add("vad.mode", "must be between 0 and 3")
+		}
+	}
+}
+
+// confirm checks the confirmation policy
+func (v *validator) confirm(config *ConfirmConfig) {
+	v.nonNegative("confirm.timeout_seconds", config.TimeoutSeconds)
+	v.nonNegative("confirm.min_devices_off", config.MinDevicesOff)
+	for _, field := range []struct {
+		name string
+		hour int
+	}{{"night_start", config.NightStart}, {"night_end", config.NightEnd}} {
+		if field.hour < 0 || field.hour > 23 {
+			v.add("confirm."+field.name, "must be an hour between 0 and 23")
+		}
+	}
+
+	security := NewSecurityManager()
+	for i, action := range config.Actions {
+		if !security.IsCommandAllowed(action) {
+			v.add(fmt.Sprintf("confirm.actions[%d]", i), "unknown action %q", action)
+		}
+	}
+}
+
+// oneOf checks an enumerated value, returning false if it isn't accepted
+func (v *validator) oneOf(path, value string, accepted []string) bool {
+	if containsString(accepted, value) {
+		return true
+	}
+	v.add(path, "unknown value %q (expected %s)", value, strings.Join(accepted[1:], ", "))
+	return false
+}
+
+// nonNegative checks that a count or duration isn't negative
+func (v *validator) nonNegative(path string, value int) {
+	if value < 0 {
+		v.add(path, "must not be negative")
+	}
+}
+
+// sortedKeys returns the keys of a map in order, so errors are reported
+// in the same order every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
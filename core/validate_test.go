@@ -0,0 +1,144 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newValidConfig adds the settings validation requires to the device fixture
+func newValidConfig(t *testing.T) *Config {
+	config := newTestConfig(t)
+	config.Audio = AudioConfig{SampleRate: 16000, Channels: 1, BitDepth: 16, Source: AudioSourceConfig{Type: "mic"}}
+	config.STT = STTConfig{Engine: "whisper"}
+	config.WakeWord = WakeWordConfig{Engine: "keyword", Keywords: []string{"jarvis"}}
+	config.VAD = VADConfig{Engine: "adaptive", Mode: 2}
+	config.Confirm = ConfirmConfig{Enabled: true, Actions: []string{"switch.off"}, NightStart: 22, NightEnd: 6}
+	return config
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := newValidConfig(t).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	config, err := LoadConfig("../config.json")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Sample config.json is invalid: %v", err)
+	}
+}
+
+func TestValidateReportsPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"unknown type", func(c *Config) {
+			light := c.Devices.Lights["bep"]
+			light.Type = "tapoo"
+			c.Devices.Lights["bep"] = light
+		}, `devices.lights.bep.type: unknown type "tapoo" (expected mqtt or tapo)`},
+		{"duplicate ID", func(c *Config) {
+			c.Devices.Switches["bep"] = DeviceInfo{Type: "mqtt", Topic: "home/plug"}
+		}, "devices.switches.bep: duplicate device ID, also defined at devices.lights.bep"},
+		{"missing IP", func(c *Config) {
+			c.Devices.Vacuum["robot"] = DeviceInfo{Type: "xiaomi"}
+		}, "devices.vacuum.robot.ip: required for xiaomi devices"},
+		{"bad IP", func(c *Config) {
+			c.Devices.Lights["phong_khach"] = DeviceInfo{Type: "tapo", IP: "192.168.1.10:80"}
+		}, `devices.lights.phong_khach.ip: "192.168.1.10:80" is not an IP address or host name`},
+		{"missing topic", func(c *Config) {
+			c.Devices.Lights["bep"] = DeviceInfo{Type: "mqtt"}
+		}, "devices.lights.bep.topic: required for mqtt devices"},
+		{"IR without on", func(c *Config) {
+			delete(c.Devices.IRDevices["dieu_hoa"].Commands, "on")
+		}, "devices.ir_devices.dieu_hoa.commands.on: required when off or temperature codes are configured"},
+		{"IR code not hex", func(c *Config) {
+			c.Devices.IRDevices["dieu_hoa"].Commands["temp_18"] = "2600..."
+		}, "devices.ir_devices.dieu_hoa.commands.temp_18: IR code must be a hex string"},
+		{"IR temperature name", func(c *Config) {
+			c.Devices.IRDevices["dieu_hoa"].Commands["temp_cool"] = "2600dd"
+		}, "devices.ir_devices.dieu_hoa.commands.temp_cool: temperature code must be named temp_<degrees>"},
+		{"IR without commands", func(c *Config) {
+			c.Devices.IRDevices["tv"] = IRDeviceInfo{Type: "broadlink", DeviceIP: "192.168.1.30"}
+		}, "devices.ir_devices.tv.commands: required for broadlink devices"},
+		{"alias clash", func(c *Config) {
+			light := c.Devices.Lights["phong_khach"]
+			light.Aliases = []string{"living room"}
+			c.Devices.Lights["phong_khach"] = light
+			c.Devices.Lights["bep"] = DeviceInfo{Type: "mqtt", Topic: "home/kitchen/light", Aliases: []string{"Living Room"}}
+		}, `devices.lights.phong_khach.aliases[0]: alias "living room" already refers to device bep`},
+		{"xiaomi token", func(c *Config) {
+			c.Devices.Vacuum["robot"] = DeviceInfo{Type: "xiaomi", IP: "192.168.1.40", Token: "abc123"}
+		}, "devices.vacuum.robot.token: must be 32 hex characters"},
+		{"MQTT port", func(c *Config) {
+			c.MQTT.Port = 70000
+		}, "mqtt.port: must be a port number"},
+		{"audio source", func(c *Config) {
+			c.Audio.Source = AudioSourceConfig{Type: "file"}
+		}, "audio.source.path: required for file sources"},
+		{"websocket token", func(c *Config) {
+			c.Audio.Source = AudioSourceConfig{Type: "websocket", Listen: "127.0.0.1:8765"}
+		}, "audio.source.token: required for websocket sources"},
+		{"engine", func(c *Config) {
+			c.TTS.Engine = "festival"
+		}, `tts.engine: unknown value "festival" (expected piper, espeak, fake)`},
+		{"keyword without STT", func(c *Config) {
+			c.STT.Engine = ""
+		}, "wake_word.engine: keyword detection requires stt.engine"},
+		{"VAD mode", func(c *Config) {
+			c.VAD.Mode = 4
+		}, "vad.mode: must be between 0 and 3"},
+		{"temperature", func(c *Config) {
+			c.Claude.Temperature = 1.5
+		}, "claude.temperature: must be between 0 and 1"},
+		{"confirm action", func(c *Config) {
+			c.Confirm.Actions = append(c.Confirm.Actions, "door.unlock")
+		}, `confirm.actions[1]: unknown action "door.unlock"`},
+		{"night hour", func(c *Config) {
+			c.Confirm.NightEnd = 24
+		}, "confirm.night_end: must be an hour between 0 and 23"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newValidConfig(t)
+			tt.modify(config)
+
+			var problems ConfigErrors
+			if !errors.As(config.Validate(), &problems) {
+				t.Fatal("Validate() returned no errors")
+			}
+			messages := make([]string, len(problems))
+			for i, problem := range problems {
+				messages[i] = problem.Error()
+			}
+			if len(problems) != 1 || messages[0] != tt.want {
+				t.Errorf("Validate() = %s\nwant %s", strings.Join(messages, "\n"), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := newValidConfig(t)
+	config.Audio.Channels = 6
+	config.Capture.MaxFiles = -1
+	config.Devices.Lights["bep"] = DeviceInfo{Type: "hue"}
+
+	err := config.Validate()
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 3 {
+		t.Fatalf("Validate() = %v, want 3 errors", err)
+	}
+	if problems[0].Path != "audio.channels" || problems[1].Path != "capture.max_files" || problems[2].Path != "devices.lights.bep.type" {
+		t.Errorf("Errors not sorted by path: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "3 configuration errors: ") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
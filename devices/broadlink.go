@@ -43,11 +43,77 @@ func (b *BroadlinkDevice) Discover(timeout time.Duration) error {
 	// Set timeout
 	conn.SetReadDeadline(time.Now().Add(timeout))
 
-	// Get local IP
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	localIP := localAddr.IP.To4()
-
 	// Build discovery packet
+	packet := buildHelloPacket(conn.LocalAddr().(*net.UDPAddr))
+
+	// Broadcast discovery packet
+	broadcastAddr := &net.UDPAddr{
+		IP:   net.IPv4(255, 255, 255, 255),
+		Port: 80,
+	}
+
+	_, err = conn.WriteToUDP(packet, broadcastAddr)
+	if err != nil {
+		return fmt.Errorf("failed to send discovery packet: %w", err)
+	}
+
+	// Wait for response
+	buffer := make([]byte, 1024)
+	n, remoteAddr, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		return fmt.Errorf("no response from Broadlink device: %w", err)
+	}
+
+	if n < 0x30 {
+		return fmt.Errorf("invalid response length")
+	}
+
+	// Parse response
+	response := buffer[:n]
+	b.IP = remoteAddr.IP.String()
+	b.Port = int(response[0x1c]) | int(response[0x1d])<<8
+	b.MAC = net.HardwareAddr(response[0x3a:0x40])
+	b.DevType = int(response[0x34]) | int(response[0x35])<<8
+
+	return nil
+}
+
+// Hello sends a unicast discovery packet to the configured device and waits for its reply
+func (b *BroadlinkDevice) Hello(timeout time.Duration) error {
+	remoteAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", b.IP, b.Port))
+	if err != nil {
+		return fmt.Errorf("invalid device address: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: 0})
+	if err != nil {
+		return fmt.Errorf("failed to create UDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	packet := buildHelloPacket(conn.LocalAddr().(*net.UDPAddr))
+	if _, err := conn.WriteToUDP(packet, remoteAddr); err != nil {
+		return fmt.Errorf("failed to send hello packet: %w", err)
+	}
+
+	buffer := make([]byte, 1024)
+	n, _, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		return fmt.Errorf("no response from Broadlink device: %w", err)
+	}
+
+	if n < 0x30 {
+		return fmt.Errorf("invalid response length")
+	}
+
+	return nil
+}
+
+// buildHelloPacket builds a Broadlink discovery (hello) packet
+func buildHelloPacket(localAddr *net.UDPAddr) []byte {
+	localIP := localAddr.IP.To4()
 	packet := make([]byte, 0x30)
 
 	// Header
@@ -78,6 +144,9 @@ func (b *BroadlinkDevice) Discover(timeout time.Duration) error {
 	packet[0x1c] = byte(localAddr.Port & 0xff)
 	packet[0x1d] = byte(localAddr.Port >> 8)
 
+	// Hello command
+	packet[0x26] = 0x06
+
 	// Checksum
 	checksum := 0xbeaf
 	for i := 0; i < len(packet); i++ {
@@ -86,36 +155,7 @@ func (b *BroadlinkDevice) Discover(timeout time.Duration) error {
 	packet[0x20] = byte(checksum & 0xff)
 	packet[0x21] = byte(checksum >> 8)
 
-	// Broadcast discovery packet
-	broadcastAddr := &net.UDPAddr{
-		IP:   net.IPv4(255, 255, 255, 255),
-		Port: 80,
-	}
-
-	_, err = conn.WriteToUDP(packet, broadcastAddr)
-	if err != nil {
-		return fmt.Errorf("failed to send discovery packet: %w", err)
-	}
-
-	// Wait for response
-	buffer := make([]byte, 1024)
-	n, remoteAddr, err := conn.ReadFromUDP(buffer)
-	if err != nil {
-		return fmt.Errorf("no response from Broadlink device: %w", err)
-	}
-
-	if n < 0x30 {
-		return fmt.Errorf("invalid response length")
-	}
-
-	// Parse response
-	response := buffer[:n]
-	b.IP = remoteAddr.IP.String()
-	b.Port = int(response[0x1c]) | int(response[0x1d])<<8
-	b.MAC = net.HardwareAddr(response[0x3a:0x40])
-	b.DevType = int(response[0x34]) | int(response[0x35])<<8
-
-	return nil
+	return packet
 }
 
 // Auth authenticates with the Broadlink device
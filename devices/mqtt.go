@@ -2,8 +2,10 @@ package devices
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -143,41 +145,85 @@ func (m *MQTTClient) IsConnected() bool {
 
 // Common MQTT device control methods
 
+// MQTTMessage is a topic and payload pair to publish
+type MQTTMessage struct {
+	Topic   string `json:"topic"`
+	Payload string `json:"payload"`
+}
+
+// LightPowerMessage builds the message that switches a light on or off
+func LightPowerMessage(topic string, on bool) MQTTMessage {
+	payload := "OFF"
+	if on {
+		payload = "ON"
+	}
+	return MQTTMessage{Topic: topic + "/set", Payload: payload}
+}
+
+// BrightnessMessage builds the message that sets light brightness (0-100)
+func BrightnessMessage(topic string, brightness int) MQTTMessage {
+	payload := fmt.Sprintf(`{"state":"ON","brightness":%d}`, brightness)
+	return MQTTMessage{Topic: topic + "/set", Payload: payload}
+}
+
+// ColorMessage builds the message that sets light color (RGB)
+func ColorMessage(topic string, r, g, b int) MQTTMessage {
+	payload := fmt.Sprintf(`{"state":"ON","color":{"r":%d,"g":%d,"b":%d}}`, r, g, b)
+	return MQTTMessage{Topic: topic + "/set", Payload: payload}
+}
+
+// SwitchPowerMessage builds the message that switches a relay on or off
+func SwitchPowerMessage(topic string, on bool) MQTTMessage {
+	payload := "off"
+	if on {
+		payload = "on"
+	}
+	return MQTTMessage{Topic: topic + "/relay/0", Payload: payload}
+}
+
+// SwitchToggleMessage builds the message that toggles a relay
+func SwitchToggleMessage(topic string) MQTTMessage {
+	return MQTTMessage{Topic: topic + "/relay/0/command", Payload: "toggle"}
+}
+
+// PublishMessage publishes a prepared message
+func (m *MQTTClient) PublishMessage(msg MQTTMessage) error {
+	return m.Publish(msg.Topic, msg.Payload)
+}
+
 // TurnOnLight turns on a light via MQTT
 func (m *MQTTClient) TurnOnLight(topic string) error {
-	return m.Publish(topic+"/set", "ON")
+	return m.PublishMessage(LightPowerMessage(topic, true))
 }
 
 // TurnOffLight turns off a light via MQTT
 func (m *MQTTClient) TurnOffLight(topic string) error {
-	return m.Publish(topic+"/set", "OFF")
+	return m.PublishMessage(LightPowerMessage(topic, false))
 }
 
 // SetBrightness sets light brightness (0-100)
 func (m *MQTTClient) SetBrightness(topic string, brightness int) error {
-	payload := fmt.Sprintf(`{"state":"ON","brightness":%d}`, brightness)
-	return m.Publish(topic+"/set", payload)
+	return m.PublishMessage(BrightnessMessage(topic, brightness))
 }
 
 // SetColor sets light color (RGB)
 func (m *MQTTClient) SetColor(topic string, r, g, b int) error {
-	payload := fmt.Sprintf(`{"state":"ON","color":{"r":%d,"g":%d,"b":%d}}`, r, g, b)
-	return m.Publish(topic+"/set", payload)
+	return m.PublishMessage(ColorMessage(topic, r, g, b))
 }
 
 // TurnOnSwitch turns on a switch via MQTT
 func (m *MQTTClient) TurnOnSwitch(topic string) error {
-	return m.Publish(topic+"/relay/0", "on")
+	return m.PublishMessage(SwitchPowerMessage(topic, true))
 }
 
 // TurnOffSwitch turns off a switch via MQTT
 func (m *MQTTClient) TurnOffSwitch(topic string) error {
-	return m.Publish(topic+"/relay/0", "off")
+	return m.PublishMessage(SwitchPowerMessage(topic, false))
 }
 
 // ToggleSwitch toggles a switch via MQTT
 func (m *MQTTClient) ToggleSwitch(topic string) error {
-	return m.Publish(topic+"/relay/0/command", "toggle")
+	return m.PublishMessage(SwitchToggleMessage(topic))
 }
 
 // GetState gets device state
@@ -191,6 +237,34 @@ func (m *MQTTClient) GetState(topic string, callback mqtt.MessageHandler) error
 	return m.Publish(topic+"/get", "")
 }
 
+// SubscribeAvailability subscribes to an availability (LWT) topic and reports
+// online/offline transitions. Accepts plain "online"/"offline" payloads
+// (zigbee2mqtt, Tasmota, ESPHome) and JSON {"state":"online"} payloads.
+func (m *MQTTClient) SubscribeAvailability(topic string, callback func(online bool)) error {
+	return m.Subscribe(topic, func(client mqtt.Client, msg mqtt.Message) {
+		callback(ParseAvailability(msg.Payload()))
+	})
+}
+
+// ParseAvailability interprets an availability payload
+func ParseAvailability(payload []byte) bool {
+	text := strings.ToLower(strings.TrimSpace(string(payload)))
+
+	var state struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(payload, &state); err == nil && state.State != "" {
+		text = strings.ToLower(state.State)
+	}
+
+	switch text {
+	case "online", "on", "true", "1", "connected":
+		return true
+	default:
+		return false
+	}
+}
+
 // ShellyDevice represents a Shelly device
 type ShellyDevice struct {
 	Topic  string
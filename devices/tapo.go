@@ -2,6 +2,7 @@ package devices
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -13,6 +14,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -30,6 +32,7 @@ type TapoDevice struct {
 	Cookie string
 	client *http.Client
 	config TapoConfig
+	mu     sync.Mutex // guards Token and Cookie; commands and health probes share the device
 }
 
 // TapoRequest represents a request to Tapo device
@@ -44,6 +47,15 @@ type TapoResponse struct {
 	Result    map[string]interface{} `json:"result,omitempty"`
 }
 
+// TapoError is returned when a Tapo device answers with a non-zero error code
+type TapoError struct {
+	Code int
+}
+
+func (e *TapoError) Error() string {
+	return fmt.Sprintf("error code: %d", e.Code)
+}
+
 // NewTapoDevice creates a new Tapo device controller
 func NewTapoDevice(ip, model string, config TapoConfig) *TapoDevice {
 	return &TapoDevice{
@@ -76,7 +88,7 @@ func (t *TapoDevice) Handshake() error {
 		},
 	}
 
-	resp, err := t.sendRequest("/app", handshakeReq, false)
+	resp, err := t.sendRequest(context.Background(), "/app", handshakeReq, false)
 	if err != nil {
 		return fmt.Errorf("handshake failed: %w", err)
 	}
@@ -87,7 +99,7 @@ func (t *TapoDevice) Handshake() error {
 
 	// Extract token and cookie
 	if key, ok := resp.Result["key"].(string); ok {
-		t.Token = key
+		t.setToken(key)
 	}
 
 	return nil
@@ -106,7 +118,7 @@ func (t *TapoDevice) Login() error {
 		Params: credentials,
 	}
 
-	resp, err := t.sendSecureRequest(loginReq)
+	resp, err := t.sendSecureRequest(context.Background(), loginReq)
 	if err != nil {
 		return fmt.Errorf("login failed: %w", err)
 	}
@@ -116,156 +128,147 @@ func (t *TapoDevice) Login() error {
 	}
 
 	if token, ok := resp.Result["token"].(string); ok {
-		t.Token = token
+		t.setToken(token)
 	}
 
 	return nil
 }
 
-// TurnOn turns on the device
-func (t *TapoDevice) TurnOn() error {
-	req := TapoRequest{
-		Method: "set_device_info",
-		Params: map[string]interface{}{
-			"device_on": true,
-		},
-	}
-
-	resp, err := t.sendSecureRequest(req)
-	if err != nil {
-		return err
-	}
-
-	if resp.ErrorCode != 0 {
-		return fmt.Errorf("error code: %d", resp.ErrorCode)
-	}
-
-	return nil
-}
-
-// TurnOff turns off the device
-func (t *TapoDevice) TurnOff() error {
-	req := TapoRequest{
+// TapoPowerRequest builds the request that switches a device on or off
+func TapoPowerRequest(on bool) TapoRequest {
+	return TapoRequest{
 		Method: "set_device_info",
 		Params: map[string]interface{}{
-			"device_on": false,
+			"device_on": on,
 		},
 	}
-
-	resp, err := t.sendSecureRequest(req)
-	if err != nil {
-		return err
-	}
-
-	if resp.ErrorCode != 0 {
-		return fmt.Errorf("error code: %d", resp.ErrorCode)
-	}
-
-	return nil
 }
 
-// SetBrightness sets the brightness (1-100) for L530
-func (t *TapoDevice) SetBrightness(brightness int) error {
+// TapoBrightnessRequest builds the request that sets the brightness (1-100)
+func TapoBrightnessRequest(brightness int) (TapoRequest, error) {
 	if brightness < 1 || brightness > 100 {
-		return fmt.Errorf("brightness must be between 1 and 100")
+		return TapoRequest{}, fmt.Errorf("brightness must be between 1 and 100")
 	}
 
-	req := TapoRequest{
+	return TapoRequest{
 		Method: "set_device_info",
 		Params: map[string]interface{}{
 			"device_on":  true,
 			"brightness": brightness,
 		},
-	}
-
-	resp, err := t.sendSecureRequest(req)
-	if err != nil {
-		return err
-	}
-
-	if resp.ErrorCode != 0 {
-		return fmt.Errorf("error code: %d", resp.ErrorCode)
-	}
-
-	return nil
+	}, nil
 }
 
-// SetColor sets the color (hue, saturation) for L530
-func (t *TapoDevice) SetColor(hue, saturation int) error {
+// TapoColorRequest builds the request that sets the color (hue, saturation)
+func TapoColorRequest(hue, saturation int) (TapoRequest, error) {
 	if hue < 0 || hue > 360 {
-		return fmt.Errorf("hue must be between 0 and 360")
+		return TapoRequest{}, fmt.Errorf("hue must be between 0 and 360")
 	}
 	if saturation < 0 || saturation > 100 {
-		return fmt.Errorf("saturation must be between 0 and 100")
+		return TapoRequest{}, fmt.Errorf("saturation must be between 0 and 100")
 	}
 
-	req := TapoRequest{
+	return TapoRequest{
 		Method: "set_device_info",
 		Params: map[string]interface{}{
 			"device_on":  true,
 			"hue":        hue,
 			"saturation": saturation,
 		},
+	}, nil
+}
+
+// TapoColorTempRequest builds the request that sets the color temperature (2500-6500K)
+func TapoColorTempRequest(temp int) (TapoRequest, error) {
+	if temp < 2500 || temp > 6500 {
+		return TapoRequest{}, fmt.Errorf("color temperature must be between 2500 and 6500")
 	}
 
-	resp, err := t.sendSecureRequest(req)
+	return TapoRequest{
+		Method: "set_device_info",
+		Params: map[string]interface{}{
+			"device_on":  true,
+			"color_temp": temp,
+		},
+	}, nil
+}
+
+// Execute sends a request to the device and checks its error code
+func (t *TapoDevice) Execute(req TapoRequest) error {
+	resp, err := t.sendSecureRequest(context.Background(), req)
 	if err != nil {
 		return err
 	}
 
 	if resp.ErrorCode != 0 {
-		return fmt.Errorf("error code: %d", resp.ErrorCode)
+		return &TapoError{Code: resp.ErrorCode}
 	}
 
 	return nil
 }
 
-// SetColorTemp sets the color temperature (2500-6500K) for L530
-func (t *TapoDevice) SetColorTemp(temp int) error {
-	if temp < 2500 || temp > 6500 {
-		return fmt.Errorf("color temperature must be between 2500 and 6500")
-	}
+// TurnOn turns on the device
+func (t *TapoDevice) TurnOn() error {
+	return t.Execute(TapoPowerRequest(true))
+}
 
-	req := TapoRequest{
-		Method: "set_device_info",
-		Params: map[string]interface{}{
-			"device_on":  true,
-			"color_temp": temp,
-		},
-	}
+// TurnOff turns off the device
+func (t *TapoDevice) TurnOff() error {
+	return t.Execute(TapoPowerRequest(false))
+}
 
-	resp, err := t.sendSecureRequest(req)
+// SetBrightness sets the brightness (1-100) for L530
+func (t *TapoDevice) SetBrightness(brightness int) error {
+	req, err := TapoBrightnessRequest(brightness)
 	if err != nil {
 		return err
 	}
+	return t.Execute(req)
+}
 
-	if resp.ErrorCode != 0 {
-		return fmt.Errorf("error code: %d", resp.ErrorCode)
+// SetColor sets the color (hue, saturation) for L530
+func (t *TapoDevice) SetColor(hue, saturation int) error {
+	req, err := TapoColorRequest(hue, saturation)
+	if err != nil {
+		return err
 	}
+	return t.Execute(req)
+}
 
-	return nil
+// SetColorTemp sets the color temperature (2500-6500K) for L530
+func (t *TapoDevice) SetColorTemp(temp int) error {
+	req, err := TapoColorTempRequest(temp)
+	if err != nil {
+		return err
+	}
+	return t.Execute(req)
 }
 
 // GetDeviceInfo gets device information
 func (t *TapoDevice) GetDeviceInfo() (map[string]interface{}, error) {
+	return t.GetDeviceInfoContext(context.Background())
+}
+
+// GetDeviceInfoContext gets device information, giving up when ctx is done
+func (t *TapoDevice) GetDeviceInfoContext(ctx context.Context) (map[string]interface{}, error) {
 	req := TapoRequest{
 		Method: "get_device_info",
 	}
 
-	resp, err := t.sendSecureRequest(req)
+	resp, err := t.sendSecureRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.ErrorCode != 0 {
-		return nil, fmt.Errorf("error code: %d", resp.ErrorCode)
+		return nil, &TapoError{Code: resp.ErrorCode}
 	}
 
 	return resp.Result, nil
 }
 
 // sendSecureRequest sends an encrypted request to Tapo device
-func (t *TapoDevice) sendSecureRequest(req TapoRequest) (*TapoResponse, error) {
+func (t *TapoDevice) sendSecureRequest(ctx context.Context, req TapoRequest) (*TapoResponse, error) {
 	// Marshal request
 	jsonData, err := json.Marshal(req)
 	if err != nil {
@@ -273,7 +276,8 @@ func (t *TapoDevice) sendSecureRequest(req TapoRequest) (*TapoResponse, error) {
 	}
 
 	// Encrypt data
-	encrypted, err := t.encrypt(jsonData)
+	token, _ := t.session()
+	encrypted, err := encrypt(token, jsonData)
 	if err != nil {
 		return nil, err
 	}
@@ -285,25 +289,25 @@ func (t *TapoDevice) sendSecureRequest(req TapoRequest) (*TapoResponse, error) {
 		},
 	}
 
-	return t.sendRequest("/app?token="+t.Token, secureReq, true)
+	return t.sendRequest(ctx, "/app?token="+token, secureReq, true)
 }
 
 // sendRequest sends a request to Tapo device
-func (t *TapoDevice) sendRequest(path string, data interface{}, withCookie bool) (*TapoResponse, error) {
+func (t *TapoDevice) sendRequest(ctx context.Context, path string, data interface{}, withCookie bool) (*TapoResponse, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
 
 	url := fmt.Sprintf("http://%s%s", t.IP, path)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if withCookie && t.Cookie != "" {
-		req.Header.Set("Cookie", t.Cookie)
+	if _, cookie := t.session(); withCookie && cookie != "" {
+		req.Header.Set("Cookie", cookie)
 	}
 
 	resp, err := t.client.Do(req)
@@ -314,7 +318,9 @@ func (t *TapoDevice) sendRequest(path string, data interface{}, withCookie bool)
 
 	// Save cookie
 	if cookies := resp.Cookies(); len(cookies) > 0 {
+		t.mu.Lock()
 		t.Cookie = cookies[0].String()
+		t.mu.Unlock()
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -330,14 +336,28 @@ func (t *TapoDevice) sendRequest(path string, data interface{}, withCookie bool)
 	return &tapoResp, nil
 }
 
-// encrypt encrypts data using AES
-func (t *TapoDevice) encrypt(data []byte) (string, error) {
+// session returns the current token and cookie
+func (t *TapoDevice) session() (token, cookie string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Token, t.Cookie
+}
+
+// setToken stores the token from a handshake or login
+func (t *TapoDevice) setToken(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Token = token
+}
+
+// encrypt encrypts data using AES keyed by a token
+func encrypt(token string, data []byte) (string, error) {
 	// Use SHA256 of token as key
-	hash := sha256.Sum256([]byte(t.Token))
+	hash := sha256.Sum256([]byte(token))
 	key := hash[:16] // Use first 16 bytes for AES-128
 
 	// Use SHA1 of token as IV
-	hashIV := sha1.Sum([]byte(t.Token))
+	hashIV := sha1.Sum([]byte(token))
 	iv := hashIV[:16]
 
 	block, err := aes.NewCipher(key)
@@ -364,12 +384,14 @@ func (t *TapoDevice) decrypt(encryptedData string) ([]byte, error) {
 		return nil, err
 	}
 
+	token, _ := t.session()
+
 	// Use SHA256 of token as key
-	hash := sha256.Sum256([]byte(t.Token))
+	hash := sha256.Sum256([]byte(token))
 	key := hash[:16]
 
 	// Use SHA1 of token as IV
-	hashIV := sha1.Sum([]byte(t.Token))
+	hashIV := sha1.Sum([]byte(token))
 	iv := hashIV[:16]
 
 	block, err := aes.NewCipher(key)
@@ -2,6 +2,7 @@ package devices
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -111,6 +112,42 @@ func (x *XiaomiDevice) Discover() error {
 	return nil
 }
 
+// MiioHello sends a miIO handshake packet and returns the device ID from the reply.
+// The handshake does not require the device token.
+func MiioHello(ip string, timeout time.Duration) (uint32, error) {
+	packet := make([]byte, 32)
+	packet[0] = 0x21
+	packet[1] = 0x31
+	packet[2] = 0x00
+	packet[3] = 0x20
+	for i := 4; i < len(packet); i++ {
+		packet[i] = 0xff
+	}
+
+	conn, err := net.DialTimeout("udp", fmt.Sprintf("%s:54321", ip), timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(packet); err != nil {
+		return 0, fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return 0, fmt.Errorf("no response from device: %w", err)
+	}
+
+	if n < 32 || buffer[0] != 0x21 || buffer[1] != 0x31 {
+		return 0, fmt.Errorf("invalid handshake response")
+	}
+
+	return uint32(buffer[8])<<24 | uint32(buffer[9])<<16 | uint32(buffer[10])<<8 | uint32(buffer[11]), nil
+}
+
 // SendCommand sends a command to Xiaomi device
 func (x *XiaomiDevice) SendCommand(method string, params []interface{}) ([]interface{}, error) {
 	req := XiaomiRequest{
@@ -222,40 +259,69 @@ func NewVacuumRobot(ip, token string) (*VacuumRobot, error) {
 	}, nil
 }
 
+// MiioCommand is a miIO method call
+type MiioCommand struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params,omitempty"`
+}
+
+// vacuumMethods maps vacuum actions to miIO methods
+var vacuumMethods = map[string]string{
+	"start": "app_start",
+	"stop":  "app_stop",
+	"pause": "app_pause",
+	"home":  "app_charge",
+	"spot":  "app_spot",
+}
+
+// VacuumActionCommand builds the miIO call for a simple vacuum action
+func VacuumActionCommand(action string) (MiioCommand, error) {
+	method, ok := vacuumMethods[action]
+	if !ok {
+		return MiioCommand{}, fmt.Errorf("unknown vacuum action: %s", action)
+	}
+	return MiioCommand{Method: method}, nil
+}
+
+// VacuumFanSpeedCommand builds the miIO call that sets the fan speed
+func VacuumFanSpeedCommand(speed int) MiioCommand {
+	return MiioCommand{Method: "set_custom_mode", Params: []interface{}{speed}}
+}
+
+// Execute sends a prepared miIO call to the robot
+func (v *VacuumRobot) Execute(cmd MiioCommand) error {
+	_, err := v.device.SendCommand(cmd.Method, cmd.Params)
+	return err
+}
+
 // Start starts cleaning
 func (v *VacuumRobot) Start() error {
-	_, err := v.device.SendCommand("app_start", nil)
-	return err
+	return v.Execute(MiioCommand{Method: vacuumMethods["start"]})
 }
 
 // Stop stops cleaning
 func (v *VacuumRobot) Stop() error {
-	_, err := v.device.SendCommand("app_stop", nil)
-	return err
+	return v.Execute(MiioCommand{Method: vacuumMethods["stop"]})
 }
 
 // Pause pauses cleaning
 func (v *VacuumRobot) Pause() error {
-	_, err := v.device.SendCommand("app_pause", nil)
-	return err
+	return v.Execute(MiioCommand{Method: vacuumMethods["pause"]})
 }
 
 // Home sends robot to charging dock
 func (v *VacuumRobot) Home() error {
-	_, err := v.device.SendCommand("app_charge", nil)
-	return err
+	return v.Execute(MiioCommand{Method: vacuumMethods["home"]})
 }
 
 // Spot starts spot cleaning
 func (v *VacuumRobot) Spot() error {
-	_, err := v.device.SendCommand("app_spot", nil)
-	return err
+	return v.Execute(MiioCommand{Method: vacuumMethods["spot"]})
 }
 
 // SetFanSpeed sets fan speed (silent=38, standard=60, medium=77, turbo=90)
 func (v *VacuumRobot) SetFanSpeed(speed int) error {
-	_, err := v.device.SendCommand("set_custom_mode", []interface{}{speed})
-	return err
+	return v.Execute(VacuumFanSpeedCommand(speed))
 }
 
 // GetStatus gets vacuum status
@@ -402,9 +468,14 @@ func NewHTTPDevice(baseURL string, headers map[string]string) *HTTPDevice {
 
 // SendRequest sends an HTTP request
 func (h *HTTPDevice) SendRequest(method, path string, body []byte) ([]byte, error) {
+	return h.SendRequestContext(context.Background(), method, path, body)
+}
+
+// SendRequestContext sends an HTTP request, giving up when ctx is done
+func (h *HTTPDevice) SendRequestContext(ctx context.Context, method, path string, body []byte) ([]byte, error) {
 	url := h.BaseURL + path
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
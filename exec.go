@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/core"
+)
+
+// runExec runs one device command without Claude, e.g.
+// jarvis exec ac.set_temp dieu_hoa_phong_khach 26. The command goes through
+// the security manager and router like any other, and the result is
+// printed as JSON. It exits with status 1 if the command fails.
+func runExec(args []string) {
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	confirmed := flags.Bool("yes", false, "run commands that would otherwise ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: jarvis exec [-yes] <action> <device> [value]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 2 || flags.NArg() > 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cmd := &core.Command{Action: flags.Arg(0), Device: flags.Arg(1)}
+	if flags.NArg() == 3 {
+		cmd.Value = execValue(flags.Arg(2))
+	}
+
+	config := loadConfig()
+	security := newSecurityManager(config)
+	router := initRouter(config)
+	executor := core.NewExecutor(security, router)
+
+	result := core.CommandResult{Command: cmd}
+	batch := &core.Batch{Commands: []*core.Command{cmd}}
+	if reason := security.ConfirmationReason(batch, time.Now()); reason != "" && !*confirmed {
+		result.Error = fmt.Sprintf("confirmation required to %s, pass -yes to run it", reason)
+	} else if err := executor.ExecuteCommand(cmd); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+	}
+	router.Close()
+
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write result: %v\n", err)
+	}
+	if !result.Success {
+		os.Exit(1)
+	}
+}
+
+// execValue parses a command value given on the command line. Numbers and
+// JSON such as {"hue":0,"saturation":100} are decoded, anything else is
+// passed as a string.
+func execValue(arg string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arg), &value); err != nil {
+		return arg
+	}
+	return value
+}
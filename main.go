@@ -1,154 +1,305 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/truong-nautilus/smart-home-ai/audio"
-	"github.com/truong-nautilus/smart-home-ai/claude"
+	"github.com/truong-nautilus/smart-home-ai/audio/dsp"
+	"github.com/truong-nautilus/smart-home-ai/audio/vad"
+	"github.com/truong-nautilus/smart-home-ai/capture"
 	"github.com/truong-nautilus/smart-home-ai/core"
 	"github.com/truong-nautilus/smart-home-ai/devices"
+	"github.com/truong-nautilus/smart-home-ai/stt"
+	"github.com/truong-nautilus/smart-home-ai/tts"
+	"github.com/truong-nautilus/smart-home-ai/wakeword"
 )
 
-const (
-	configFile = "config.json"
-	envFile    = ".env"
+const envFile = ".env"
+
+// secretStore resolves secret references in the configuration
+var secretStore = &vaultStore{}
+
+var (
+	dryRun      = flag.Bool("dry-run", false, "resolve and log device operations without sending them")
+	secretsFile = flag.String("secrets", "secrets.vault", "encrypted secrets vault referenced as secret:<name> in the config")
+	configFlag  = flag.String("config", "", "config file, .json, .yaml or .toml (default: the first of "+strings.Join(core.ConfigFiles, ", ")+" found)")
 )
 
 func main() {
-	log.Println("=== Jarvis AI Smart Home System ===")
-	log.Println("Initializing...")
+	flag.Parse()
 
-	// Load environment variables
-	if err := godotenv.Load(envFile); err != nil {
-		log.Printf("Warning: Error loading .env file: %v", err)
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "status":
+			runStatus()
+			return
+		case "replay":
+			runReplay(flag.Args()[1:])
+			return
+		case "chat":
+			runChat()
+			return
+		case "do":
+			runDo(flag.Args()[1:])
+			return
+		case "exec":
+			runExec(flag.Args()[1:])
+			return
+		case "config":
+			runConfig(flag.Args()[1:])
+			return
+		case "secrets":
+			runSecrets(flag.Args()[1:])
+			return
+		default:
+			log.Fatalf("Unknown command: %s (available: status, replay, chat, do, exec, config, secrets)", flag.Arg(0))
+		}
 	}
 
-	// Load configuration
-	config, err := core.LoadConfig(configFile)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
+	log.Println("=== Jarvis AI Smart Home System ===")
+	log.Println("Initializing...")
+
+	config := loadConfig()
 
 	// Initialize security manager
-	security := core.NewSecurityManager()
+	security := newSecurityManager(config)
 	log.Println("Security manager initialized")
 
-	// Initialize command router
-	router := core.NewCommandRouter(config)
+	// Initialize command router and devices
+	router := initRouter(config)
 
-	// Setup device configurations
-	tapoConfig := devices.TapoConfig{
-		Email:    os.Getenv("TAPO_USER"),
-		Password: os.Getenv("TAPO_PASS"),
+	// Start device health monitoring
+	var monitor *core.HealthMonitor
+	if config.Health.Enabled && !*dryRun {
+		monitor = core.NewHealthMonitor(router, config.Health)
+		if err := monitor.Start(); err != nil {
+			log.Printf("Warning: Failed to start health monitor: %v", err)
+		}
+		defer monitor.Stop()
 	}
 
-	mqttConfig := devices.MQTTConfig{
-		Host:     os.Getenv("MQTT_HOST"),
-		Port:     1883,
-		Username: os.Getenv("MQTT_USER"),
-		Password: os.Getenv("MQTT_PASS"),
-		ClientID: "jarvis-ai-" + time.Now().Format("20060102150405"),
+	// Initialize Claude client and the local intent parser
+	jarvis := newAssistant(config, security, router)
+
+	// Apply config changes without restarting
+	watcher := watchConfig(config, router, security, jarvis, monitor)
+	defer watcher.Stop()
+
+	// Keep Claude informed about device availability
+	if monitor != nil {
+		go func() {
+			for event := range monitor.Events() {
+				jarvis.SetContext(monitor.Summary())
+				if !event.Online {
+					log.Printf("Device %s went offline: %s", event.DeviceID, event.Status.Error)
+				}
+			}
+		}()
 	}
 
-	// Initialize devices
-	if err := router.Initialize(tapoConfig, mqttConfig); err != nil {
-		log.Printf("Warning: Some devices failed to initialize: %v", err)
+	// Speak replies aloud
+	var player *audio.Player
+	if config.TTS.Engine != "" {
+		synth, err := tts.New(tts.Config{
+			Engine: config.TTS.Engine,
+			Binary: config.TTS.Binary,
+			Model:  config.TTS.Model,
+			Voice:  config.TTS.Voice,
+			Speed:  config.TTS.Speed,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create text-to-speech engine: %v", err)
+		}
+
+		player, err = audio.NewPlayer()
+		if err != nil {
+			log.Fatalf("Failed to create audio player: %v", err)
+		}
+		defer player.Close()
+
+		jarvis.speaker = tts.NewSpeaker(synth, player)
+		log.Println("Text-to-speech enabled")
 	}
 
-	// Initialize Claude Realtime client
-	claudeConfig := claude.ClaudeConfig{
-		APIKey:       os.Getenv("CLAUDE_API_KEY"),
-		Model:        config.Claude.Model,
-		WebSocketURL: config.Claude.WebSocketURL,
-		SystemPrompt: config.Claude.SystemPrompt,
-		MaxTokens:    config.Claude.MaxTokens,
-		Temperature:  config.Claude.Temperature,
+	// Read requests from standard input, unless it carries audio
+	if config.Audio.Source.Type != "stdin" {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if text := strings.TrimSpace(scanner.Text()); text != "" {
+					jarvis.ask(text)
+				}
+			}
+		}()
 	}
 
-	claudeClient := claude.NewRealtimeClient(claudeConfig)
-	log.Println("Connecting to Claude Realtime API...")
+	// Transcribe audio input and send it as text
+	if config.STT.Engine != "" {
+		transcriber, err := newTranscriber(config)
+		if err != nil {
+			log.Fatalf("Failed to create speech-to-text engine: %v", err)
+		}
 
-	if err := claudeClient.Connect(); err != nil {
-		log.Fatalf("Failed to connect to Claude: %v", err)
-	}
-	defer claudeClient.Disconnect()
+		log.Println("Initializing audio source...")
+		source, err := audio.NewSource(audio.SourceConfig{
+			Type:       config.Audio.Source.Type,
+			Path:       config.Audio.Source.Path,
+			Listen:     config.Audio.Source.Listen,
+			Token:      config.Audio.Source.Token,
+			SampleRate: config.Audio.SampleRate,
+			Channels:   config.Audio.Channels,
+			BufferSize: config.Audio.BufferSize,
+			Realtime:   config.Audio.Source.Realtime,
+			BitDepth:   config.Audio.BitDepth,
+			Float:      config.Audio.Float,
+			Native:     config.Audio.Native,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create audio source: %v", err)
+		}
+		defer source.Close()
 
-	log.Println("Connected to Claude Realtime API")
+		if err := source.Start(); err != nil {
+			log.Fatalf("Failed to start audio source: %v", err)
+		}
+		defer source.Stop()
 
-	// Initialize audio recorder
-	log.Println("Initializing audio recorder...")
-	recorder, err := audio.NewRecorder(
-		uint32(config.Audio.SampleRate),
-		uint32(config.Audio.Channels),
-		uint32(config.Audio.BufferSize),
-	)
-	if err != nil {
-		log.Fatalf("Failed to create audio recorder: %v", err)
-	}
-	defer recorder.Close()
+		log.Println("Audio source started")
 
-	if err := recorder.Start(); err != nil {
-		log.Fatalf("Failed to start audio recorder: %v", err)
-	}
-	defer recorder.Stop()
+		format := stt.Format{SampleRate: config.Audio.SampleRate, Channels: config.Audio.Channels}
+		frames := source.GetAudioChannel()
 
-	log.Println("Audio recorder started")
+		// Clean up the audio before detection and recognition
+		chain := dsp.NewChain(dsp.Config{
+			EchoCancellation: config.Audio.DSP.EchoCancellation,
+			EchoTailMs:       config.Audio.DSP.EchoTailMs,
+			HighPassHz:       config.Audio.DSP.HighPassHz,
+			NoiseSuppression: config.Audio.DSP.NoiseSuppression,
+			NoiseReductionDB: config.Audio.DSP.NoiseReductionDB,
+			AGC:              config.Audio.DSP.AGC,
+			AGCTargetDB:      config.Audio.DSP.AGCTargetDB,
+			AGCMaxGainDB:     config.Audio.DSP.AGCMaxGainDB,
+			NoiseGateDB:      config.Audio.DSP.NoiseGateDB,
+		}, config.Audio.SampleRate)
+		if chain.Enabled() {
+			go chain.Run(context.Background(), frames)
+			frames = chain.Frames()
+		}
 
-	// Setup command processing goroutine
-	go func() {
-		commandChan := claudeClient.GetCommandChannel()
-		for cmd := range commandChan {
-			log.Printf("Received command: %+v", cmd)
-
-			// Validate command
-			if err := security.ValidateCommand(cmd); err != nil {
-				log.Printf("Command validation failed: %v", err)
-				security.LogCommand(cmd, false, err)
-				continue
-			}
+		// Buffer up to 10s of fixed 20ms frames between capture and the slower
+		// speech stages (wake word, VAD, STT), which run one after another and
+		// block rather than drop. If they fall further behind, the oldest
+		// audio is lost and an overrun logged instead of blocking capture.
+		ring := audio.NewRing("speech", format.Bytes(vad.FrameDuration), 500)
+		go ring.WriteFrom(context.Background(), frames)
+		frames = ring.Frames(context.Background())
 
-			// Execute command
-			if err := router.ExecuteCommand(cmd); err != nil {
-				log.Printf("Command execution failed: %v", err)
-				security.LogCommand(cmd, false, err)
+		if player != nil {
+			if config.Audio.DSP.EchoCancellation {
+				// Cancel Jarvis's own voice instead of ducking, so "Jarvis, stop"
+				// can be heard while it is talking
+				player.SetReferenceHandler(chain.Reference)
 			} else {
-				log.Printf("Command executed successfully: %s on %s", cmd.Action, cmd.Device)
-				security.LogCommand(cmd, true, nil)
+				// Duck the microphone while speaking so Jarvis doesn't hear itself
+				player.SetSpeakingHandlers(
+					func() { source.SetGain(config.TTS.DuckGain) },
+					func() { source.SetGain(1) },
+				)
 			}
 		}
-	}()
 
-	// Setup audio streaming goroutine
-	go func() {
-		audioChan := recorder.GetAudioChannel()
-		lastCommit := time.Now()
-
-		for audioData := range audioChan {
-			// Send audio to Claude
-			if err := claudeClient.SendAudio(audioData); err != nil {
-				log.Printf("Error sending audio: %v", err)
-				continue
+		// Only transcribe audio after the wake word
+		if config.WakeWord.Engine != "" {
+			gate, err := initWakeWord(config, format, transcriber, router, player, jarvis)
+			if err != nil {
+				log.Fatalf("Failed to initialize wake word detection: %v", err)
 			}
+			go gate.Run(context.Background(), frames)
+			frames = gate.Frames()
+		} else {
+			log.Println("Wake word is not configured, transcribing all audio")
+		}
 
-			// Commit audio buffer every 500ms to trigger processing
-			if time.Since(lastCommit) > 500*time.Millisecond {
-				if err := claudeClient.CommitAudioBuffer(); err != nil {
-					log.Printf("Error committing audio buffer: %v", err)
-				}
-				lastCommit = time.Now()
+		segment := time.Duration(config.STT.SegmentSeconds * float64(time.Second))
+		pipeline := stt.NewPipeline(transcriber, format, segment)
+
+		// Split audio into utterances, or fall back to fixed-length segments
+		if config.VAD.Engine != "" {
+			detector, err := vad.New(vad.Config{
+				Engine:          config.VAD.Engine,
+				Mode:            config.VAD.Mode,
+				EnergyThreshold: config.VAD.EnergyThreshold,
+			}, config.Audio.SampleRate)
+			if err != nil {
+				log.Fatalf("Failed to create voice activity detector: %v", err)
 			}
+
+			segmenter := vad.NewSegmenter(detector, config.Audio.SampleRate, config.Audio.Channels, vad.SegmenterConfig{
+				PreRoll:      time.Duration(config.VAD.PreRollMs) * time.Millisecond,
+				Silence:      time.Duration(config.VAD.SilenceMs) * time.Millisecond,
+				MinSpeech:    time.Duration(config.VAD.MinSpeechMs) * time.Millisecond,
+				MaxUtterance: time.Duration(config.VAD.MaxUtteranceMs) * time.Millisecond,
+			})
+			go segmenter.Run(context.Background(), frames)
+			go pipeline.RunUtterances(context.Background(), segmenter.Utterances())
+		} else {
+			go pipeline.Run(context.Background(), frames)
 		}
-	}()
+
+		// Keep utterances with their transcript and outcome for debugging
+		var captures *capture.Store
+		if config.Capture.Enabled {
+			captures, err = newCaptureStore(config)
+			if err != nil {
+				log.Fatalf("Failed to initialize utterance capture: %v", err)
+			}
+			log.Printf("Capturing utterances to %s", captures.Dir())
+		}
+
+		go func() {
+			for transcript := range pipeline.Transcripts() {
+				var record *capture.Record
+				if captures != nil {
+					saved, err := captures.Save(transcript, format)
+					if err != nil {
+						log.Printf("Warning: Failed to capture utterance: %v", err)
+					}
+					record = saved
+				}
+
+				reply, err := jarvis.ask(transcript.Text)
+				if record != nil {
+					var text string
+					var results []*core.BatchResult
+					if reply != nil {
+						text, results = reply.Text, reply.Results
+					}
+					if err := captures.Complete(record, text, results, err); err != nil {
+						log.Printf("Warning: Failed to capture reply: %v", err)
+					}
+				}
+			}
+		}()
+	} else {
+		log.Println("Speech-to-text is not configured, voice input disabled")
+	}
 
 	// Display startup message
 	log.Println("\n============================================================")
 	log.Println("Jarvis AI Smart Home is running!")
-	log.Println("Speak into your microphone to control your smart home devices")
+	log.Println("Say 'Jarvis' and speak, or type a request and press Enter")
 	log.Println("Example: 'Turn on the living room light'")
 	log.Println("         'Set air conditioner to 26 degrees'")
 	log.Println("         'Start the vacuum robot'")
@@ -164,3 +315,326 @@ func main() {
 	router.Close()
 	log.Println("Goodbye!")
 }
+
+// initWakeWord creates the wake word gate and its acknowledgement hook
+func initWakeWord(config *core.Config, format stt.Format, transcriber stt.Transcriber, router *core.CommandRouter,
+	player *audio.Player, jarvis *assistant) (*wakeword.Gate, error) {
+	detector, err := wakeword.New(wakeword.Config{
+		Engine:          config.WakeWord.Engine,
+		Command:         config.WakeWord.Command,
+		Keywords:        config.WakeWord.Keywords,
+		EnergyThreshold: config.WakeWord.EnergyThreshold,
+	}, format, transcriber)
+	if err != nil {
+		return nil, err
+	}
+
+	window := time.Duration(config.WakeWord.WindowSeconds * float64(time.Second))
+	gate := wakeword.NewGate(detector, format, window)
+
+	beep := audio.Tone(880, 150*time.Millisecond, 16000)
+	gate.SetWakeHandler(func(detection *wakeword.Detection) {
+		// Barge-in: the wake word interrupts Jarvis mid-sentence
+		if player != nil && player.IsPlaying() {
+			player.Stop()
+			log.Println("Interrupted speech playback")
+		}
+
+		if config.WakeWord.AckSound && player != nil {
+			go func() {
+				if err := player.Play(context.Background(), beep, 16000, 1); err != nil {
+					log.Printf("Warning: Failed to play acknowledgement: %v", err)
+				}
+			}()
+		}
+
+		if config.WakeWord.AckTopic != "" {
+			if err := router.Publish(config.WakeWord.AckTopic, config.WakeWord.AckPayload); err != nil {
+				log.Printf("Warning: Failed to publish acknowledgement: %v", err)
+			}
+		}
+
+		// "Jarvis, bật đèn" carries the request in the same utterance
+		if detection.Text != "" {
+			go jarvis.ask(detection.Text)
+		}
+	})
+
+	log.Printf("Wake word detection enabled (%s)", config.WakeWord.Engine)
+	return gate, nil
+}
+
+// newTranscriber creates the configured speech-to-text engine
+func newTranscriber(config *core.Config) (stt.Transcriber, error) {
+	return stt.New(stt.Config{
+		Engine:   config.STT.Engine,
+		Binary:   config.STT.Binary,
+		Model:    config.STT.Model,
+		Language: config.STT.Language,
+		Threads:  config.STT.Threads,
+	})
+}
+
+// newCaptureStore creates the utterance capture store from the configuration
+func newCaptureStore(config *core.Config) (*capture.Store, error) {
+	return capture.NewStore(capture.Config{
+		Dir:      config.Capture.Dir,
+		MaxFiles: config.Capture.MaxFiles,
+		MaxAge:   time.Duration(config.Capture.MaxAgeDays) * 24 * time.Hour,
+	})
+}
+
+// newSecurityManager creates the security manager, asking before risky
+// commands when confirmation is enabled
+func newSecurityManager(config *core.Config) *core.SecurityManager {
+	security := core.NewSecurityManager()
+	if config.Confirm.Enabled {
+		security.SetConfirmPolicy(confirmPolicy(config))
+	}
+	return security
+}
+
+// confirmPolicy returns the configured confirmation policy, which is empty
+// when confirmation is disabled
+func confirmPolicy(config *core.Config) core.ConfirmPolicy {
+	if !config.Confirm.Enabled {
+		return core.ConfirmPolicy{}
+	}
+	return core.ConfirmPolicy{
+		Actions:       config.Confirm.Actions,
+		MinDevicesOff: config.Confirm.MinDevicesOff,
+		NightStart:    config.Confirm.NightStart,
+		NightEnd:      config.Confirm.NightEnd,
+		NightMinTemp:  config.Confirm.NightMinTemp,
+	}
+}
+
+// watchConfig applies changes to the config file, or reloads it on SIGHUP,
+// without restarting. Devices, their health checks, aliases, the
+// confirmation policy and the intent settings take effect immediately;
+// other sections need a restart.
+func watchConfig(config *core.Config, router *core.CommandRouter, security *core.SecurityManager, jarvis *assistant, monitor *core.HealthMonitor) *core.ConfigWatcher {
+	watcher := core.NewConfigWatcher(configFile(), core.DefaultReloadInterval, secretStore)
+	watcher.Start()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			log.Println("SIGHUP received, reloading config")
+			watcher.Reload()
+		}
+	}()
+
+	go func() {
+		current := config
+		for next := range watcher.Changes() {
+			diff := router.Reload(next)
+			if monitor != nil {
+				monitor.Reload()
+			}
+			security.SetConfirmPolicy(confirmPolicy(next))
+			jarvis.Reload(next, security)
+			log.Printf("Config reloaded: %s", diff)
+
+			for _, section := range core.RestartRequired(current, next) {
+				log.Printf("Warning: Changes to %s take effect after a restart", section)
+			}
+			current = next
+		}
+	}()
+
+	return watcher
+}
+
+// configFile returns the config file given with -config, or the default one found
+func configFile() string {
+	if *configFlag != "" {
+		return *configFlag
+	}
+	return core.FindConfig(".")
+}
+
+// loadConfig loads environment variables and the configuration file
+func loadConfig() *core.Config {
+	if err := godotenv.Load(envFile); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	config, err := core.LoadConfigWithSecrets(configFile(), secretStore)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	var problems core.ConfigErrors
+	if err := config.Validate(); errors.As(err, &problems) {
+		for _, problem := range problems {
+			log.Printf("Config error: %s", problem)
+		}
+		log.Fatalf("Invalid config %s (%d errors)", configFile(), len(problems))
+	}
+
+	return config
+}
+
+// initRouter creates the command router and initializes device connections
+func initRouter(config *core.Config) *core.CommandRouter {
+	router := core.NewCommandRouter(config)
+	if *dryRun {
+		router.SetDryRun(true)
+		log.Println("Dry-run mode: commands will be resolved and logged, not sent to devices")
+	}
+
+	tapoConfig := devices.TapoConfig{
+		Email:    configOrEnv(config.Tapo.Email, "TAPO_USER"),
+		Password: configOrEnv(config.Tapo.Password, "TAPO_PASS"),
+	}
+
+	mqttConfig := devices.MQTTConfig{
+		Host:     configOrEnv(config.MQTT.Host, "MQTT_HOST"),
+		Port:     config.MQTT.Port,
+		Username: configOrEnv(config.MQTT.Username, "MQTT_USER"),
+		Password: configOrEnv(config.MQTT.Password, "MQTT_PASS"),
+		ClientID: config.MQTT.ClientID,
+	}
+	if mqttConfig.Port == 0 {
+		mqttConfig.Port, _ = strconv.Atoi(os.Getenv("MQTT_PORT"))
+	}
+	if mqttConfig.Port == 0 {
+		mqttConfig.Port = 1883
+	}
+	if mqttConfig.ClientID == "" {
+		mqttConfig.ClientID = "jarvis-ai-" + time.Now().Format("20060102150405")
+	}
+
+	if err := router.Initialize(tapoConfig, mqttConfig); err != nil {
+		log.Printf("Warning: Some devices failed to initialize: %v", err)
+	}
+
+	return router
+}
+
+// configOrEnv returns a config value, or the environment variable if it is empty
+func configOrEnv(value, key string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// runStatus probes every configured device once and prints its availability
+func runStatus() {
+	config := loadConfig()
+	router := initRouter(config)
+	defer router.Close()
+
+	monitor := core.NewHealthMonitor(router, config.Health)
+	monitor.CheckOnce()
+
+	fmt.Printf("%-28s %-10s %-8s %-10s %s\n", "DEVICE", "PROTOCOL", "STATUS", "LATENCY", "ERROR")
+	for _, status := range monitor.GetAllStatus() {
+		state := "offline"
+		if !status.Checked {
+			state = "unknown"
+		} else if status.Online {
+			state = "online"
+		}
+		fmt.Printf("%-28s %-10s %-8s %-10s %s\n",
+			status.DeviceID, status.Protocol, state, fmt.Sprintf("%dms", status.LatencyMs), status.Error)
+	}
+}
+
+// runReplay runs a captured utterance through speech recognition and Claude
+// again, printing the transcript, reply and command results. Without
+// arguments it lists the captured utterances. Commands are only resolved
+// and logged unless -execute is given.
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	execute := flags.Bool("execute", false, "send the replayed commands to the devices instead of a dry run")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: jarvis replay [-execute] [id|file.wav]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	args = flags.Args()
+
+	config := loadConfig()
+
+	captures, err := newCaptureStore(config)
+	if err != nil {
+		log.Fatalf("Failed to open utterance captures: %v", err)
+	}
+
+	if len(args) == 0 {
+		ids, err := captures.List()
+		if err != nil {
+			log.Fatalf("Failed to list captures: %v", err)
+		}
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+		return
+	}
+
+	// Accept a capture ID or the path of any WAV file
+	path := args[0]
+	original, err := captures.Load(path)
+	if err == nil {
+		path = original.Audio
+	} else if _, statErr := os.Stat(path); statErr != nil {
+		log.Fatalf("Unknown capture %s: %v", path, err)
+	}
+
+	transcriber, err := newTranscriber(config)
+	if err != nil {
+		log.Fatalf("Failed to create speech-to-text engine: %v", err)
+	}
+
+	source, err := audio.NewFileSource(path, config.Audio.SampleRate, config.Audio.Channels, config.Audio.BufferSize, false)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer source.Close()
+	if err := source.Start(); err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	var pcm []byte
+	for frame := range source.GetAudioChannel() {
+		pcm = append(pcm, frame...)
+	}
+
+	// The capture is a complete utterance, so it is transcribed in one piece
+	format := stt.Format{SampleRate: config.Audio.SampleRate, Channels: config.Audio.Channels}
+	pipeline := stt.NewPipeline(transcriber, format, 0)
+	utterances := make(chan []byte, 1)
+	utterances <- pcm
+	close(utterances)
+	pipeline.RunUtterances(context.Background(), utterances)
+
+	transcript, ok := <-pipeline.Transcripts()
+	if !ok {
+		log.Fatalf("No speech recognized in %s", path)
+	}
+	fmt.Printf("Transcript: %s\n", transcript.Text)
+	if original != nil && original.Transcript != transcript.Text {
+		fmt.Printf("Originally: %s\n", original.Transcript)
+	}
+
+	router := initRouter(config)
+	defer router.Close()
+	if !*execute && !*dryRun {
+		router.SetDryRun(true)
+		log.Println("Replaying as a dry run: commands will be resolved and logged, not sent to devices (use -execute to send them)")
+	}
+	jarvis := newAssistant(config, newSecurityManager(config), router)
+
+	reply, err := jarvis.ask(transcript.Text)
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+	fmt.Printf("Response: %s\n", reply.Text)
+	for _, result := range reply.Results {
+		fmt.Printf("Result: %s\n", result.Summary())
+	}
+}
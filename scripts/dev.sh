@@ -5,11 +5,11 @@
 case "$1" in
     "build")
         echo "Building Jarvis AI..."
-        go build -o bin/jarvis main.go
+        go build -o bin/jarvis .
         ;;
     "run")
         echo "Running Jarvis AI..."
-        go run main.go
+        go run .
         ;;
     "test")
         echo "Running tests..."
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"github.com/truong-nautilus/smart-home-ai/secrets"
+	"golang.org/x/term"
+)
+
+// passphraseEnv holds the vault passphrase when Jarvis runs without a terminal
+const passphraseEnv = "JARVIS_SECRETS_PASSPHRASE"
+
+// runSecrets manages the encrypted secrets vault:
+// jarvis secrets set <name> [value], get <name>, list and delete <name>.
+// set reads the value from the terminal or stdin when it isn't given, so
+// it doesn't end up in the shell history.
+func runSecrets(args []string) {
+	usage := "Usage: jarvis secrets set <name> [value] | get <name> | list | delete <name>"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	// .env may set the passphrase
+	godotenv.Load(envFile)
+
+	switch command := args[0]; {
+	case command == "set" && (len(args) == 2 || len(args) == 3):
+		vault := openVault(true)
+		value := ""
+		if len(args) == 3 {
+			value = args[2]
+		} else {
+			value = readSecret(fmt.Sprintf("Value of %s: ", args[1]))
+		}
+		if err := vault.Set(args[1], value); err != nil {
+			log.Fatalf("Failed to set secret: %v", err)
+		}
+		fmt.Printf("Saved %s to %s\n", args[1], vault.Path())
+	case command == "get" && len(args) == 2:
+		value, err := openVault(false).Get(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(value)
+	case command == "list" && len(args) == 1:
+		for _, name := range openVault(false).Names() {
+			fmt.Println(name)
+		}
+	case command == "delete" && len(args) == 2:
+		if err := openVault(false).Delete(args[1]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted %s\n", args[1])
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// openVault opens the vault, asking for the passphrase. With create, a
+// missing vault is created.
+func openVault(create bool) *secrets.Vault {
+	if _, err := os.Stat(*secretsFile); errors.Is(err, os.ErrNotExist) {
+		if !create {
+			log.Fatalf("No secrets vault at %s, add a secret with: jarvis secrets set <name>", *secretsFile)
+		}
+
+		key, err := passphrase("New vault passphrase: ")
+		if err == nil && os.Getenv(passphraseEnv) == "" {
+			if confirm := readSecret("Repeat passphrase: "); confirm != key {
+				err = errors.New("passphrases don't match")
+			}
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		vault, err := secrets.Create(*secretsFile, key)
+		if err != nil {
+			log.Fatalf("Failed to create secrets vault: %v", err)
+		}
+		log.Printf("Created secrets vault %s", *secretsFile)
+		return vault
+	}
+
+	key, err := passphrase("Vault passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	vault, err := secrets.Open(*secretsFile, key)
+	if err != nil {
+		log.Fatalf("Failed to open secrets vault: %v", err)
+	}
+	return vault
+}
+
+// passphrase returns the vault passphrase from the environment, or asks
+// for it on the terminal
+func passphrase(prompt string) (string, error) {
+	if value := os.Getenv(passphraseEnv); value != "" {
+		return value, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("set %s to open the secrets vault without a terminal", passphraseEnv)
+	}
+	return readSecret(prompt), nil
+}
+
+// readSecret reads a line from the terminal without echoing it, or from stdin
+func readSecret(prompt string) string {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalf("Failed to read stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to read from terminal: %v", err)
+	}
+	return string(value)
+}
+
+// vaultStore opens the secrets vault the first time the configuration
+// refers to a secret, so Jarvis only asks for the passphrase when needed
+type vaultStore struct {
+	once  sync.Once
+	vault *secrets.Vault
+	err   error
+}
+
+// Secret returns a secret from the vault
+func (s *vaultStore) Secret(name string) (string, error) {
+	s.once.Do(func() {
+		if _, err := os.Stat(*secretsFile); err != nil {
+			s.err = fmt.Errorf("no secrets vault at %s, add secrets with: jarvis secrets set <name>", *secretsFile)
+			return
+		}
+		var key string
+		if key, s.err = passphrase(fmt.Sprintf("Passphrase for %s: ", *secretsFile)); s.err == nil {
+			s.vault, s.err = secrets.Open(*secretsFile, key)
+		}
+	})
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.vault.Secret(name)
+}
@@ -0,0 +1,341 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	formatVersion = 1
+	keyLength     = 32 // AES-256
+	saltLength    = 16
+)
+
+// defaultKDF is the scrypt cost of new vaults. It is stored in the vault
+// file, so it can be raised without breaking existing vaults.
+var defaultKDF = KDFParams{N: 1 << 15, R: 8, P: 1}
+
+// namePattern matches secret names, e.g. tapo_password or mqtt.pass
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var (
+	// ErrNotFound is returned for a secret that isn't in the vault
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrWrongPassphrase is returned when the vault can't be decrypted
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted vault")
+)
+
+// KDFParams are the scrypt parameters deriving the key from the passphrase
+type KDFParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// vaultFile is the vault's format on disk. The secrets are encrypted with
+// AES-GCM; the header is authenticated along with them.
+type vaultFile struct {
+	Version int       `json:"version"`
+	KDF     string    `json:"kdf"`
+	Params  KDFParams `json:"params"`
+	Salt    []byte    `json:"salt"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"`
+}
+
+// header returns the data authenticated with the secrets
+func (f *vaultFile) header() []byte {
+	return []byte(fmt.Sprintf("jarvis-vault:%d:%s:%d:%d:%d:%x", f.Version, f.KDF, f.Params.N, f.Params.R, f.Params.P, f.Salt))
+}
+
+// Vault is an encrypted file of named secrets, such as device passwords,
+// miIO tokens and API keys. The key is derived from a passphrase with scrypt.
+type Vault struct {
+	path    string
+	params  KDFParams
+	salt    []byte
+	key     []byte
+	secrets map[string]string
+	modTime time.Time // of the file when last read or written
+	mu      sync.Mutex
+}
+
+// Create creates an empty vault protected by a passphrase. It fails if the
+// file already exists.
+func Create(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("vault %s already exists", path)
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := deriveKey(passphrase, salt, defaultKDF)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{
+		path:    path,
+		params:  defaultKDF,
+		salt:    salt,
+		key:     key,
+		secrets: make(map[string]string),
+	}
+	if err := v.save(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Open decrypts an existing vault
+func Open(path, passphrase string) (*Vault, error) {
+	file, modTime, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, file.Salt, file.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{path: path, params: file.Params, salt: file.Salt, key: key}
+	if v.secrets, err = v.decrypt(file); err != nil {
+		return nil, err
+	}
+	v.modTime = modTime
+	return v, nil
+}
+
+// Path returns the vault file
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Get returns a secret
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Secret returns a secret, first rereading the vault if another process
+// changed it. It lets a running Jarvis see secrets set with
+// "jarvis secrets set" when its configuration is reloaded.
+func (v *Vault) Secret(name string) (string, error) {
+	if err := v.refresh(); err != nil {
+		return "", err
+	}
+	return v.Get(name)
+}
+
+// Set stores a secret and saves the vault
+func (v *Vault) Set(name, value string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q (use letters, digits, '_', '-' and '.')", name)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous, existed := v.secrets[name]
+	v.secrets[name] = value
+	if err := v.save(); err != nil {
+		if existed {
+			v.secrets[name] = previous
+		} else {
+			delete(v.secrets, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a secret and saves the vault
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	value, ok := v.secrets[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(v.secrets, name)
+	if err := v.save(); err != nil {
+		v.secrets[name] = value
+		return err
+	}
+	return nil
+}
+
+// Names returns the names of all secrets, sorted
+func (v *Vault) Names() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// refresh rereads the vault if the file changed since it was last read
+func (v *Vault) refresh() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return fmt.Errorf("failed to read vault: %w", err)
+	}
+	if info.ModTime().Equal(v.modTime) {
+		return nil
+	}
+
+	file, modTime, err := readFile(v.path)
+	if err != nil {
+		return err
+	}
+	if file.Params != v.params || string(file.Salt) != string(v.salt) {
+		return fmt.Errorf("vault %s was recreated, restart to open it again", v.path)
+	}
+	secrets, err := v.decrypt(file)
+	if err != nil {
+		return err
+	}
+	v.secrets, v.modTime = secrets, modTime
+	return nil
+}
+
+// decrypt decrypts the secrets of a vault file
+func (v *Vault) decrypt(file *vaultFile) (map[string]string, error) {
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, file.header())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode vault: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts the secrets with a fresh nonce and replaces the vault file.
+// The caller holds the lock, except while the vault is created.
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+
+	file := &vaultFile{
+		Version: formatVersion,
+		KDF:     "scrypt",
+		Params:  v.params,
+		Salt:    v.salt,
+		Nonce:   make([]byte, gcm.NonceSize()),
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plaintext, file.header())
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file and rename it, so the vault is never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+
+	if info, err := os.Stat(v.path); err == nil {
+		v.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// readFile reads and checks a vault file
+func readFile(path string) (*vaultFile, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read vault: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode vault %s: %w", path, err)
+	}
+	if file.Version != formatVersion || file.KDF != "scrypt" {
+		return nil, time.Time{}, fmt.Errorf("unsupported vault %s (version %d, kdf %q)", path, file.Version, file.KDF)
+	}
+	return &file, info.ModTime(), nil
+}
+
+// deriveKey derives the encryption key from the passphrase
+func deriveKey(passphrase string, salt []byte, params KDFParams) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// newGCM creates the AES-GCM cipher for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
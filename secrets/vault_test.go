@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// Keep key derivation fast in tests
+	defaultKDF = KDFParams{N: 1 << 10, R: 8, P: 1}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	vault, err := Create(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := vault.Set("tapo_password", "s3cret"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := vault.Set("xiaomi.token", "00112233445566778899aabbccddeeff"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "tapo_password") {
+		t.Error("Vault file contains a secret in plaintext")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("Vault file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if names := reopened.Names(); !reflect.DeepEqual(names, []string{"tapo_password", "xiaomi.token"}) {
+		t.Errorf("Names() = %v", names)
+	}
+	if value, err := reopened.Get("tapo_password"); err != nil || value != "s3cret" {
+		t.Errorf("Get() = %q, %v", value, err)
+	}
+
+	if err := reopened.Delete("tapo_password"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := reopened.Get("tapo_password"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a deleted secret error = %v", err)
+	}
+}
+
+func TestVaultErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	vault, err := Create(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := vault.Set("api_key", "sk-123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Create(path, "hunter2"); err == nil {
+		t.Error("Create() should not overwrite an existing vault")
+	}
+	if _, err := Open(path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() with the wrong passphrase error = %v", err)
+	}
+	if err := vault.Set("api key", "x"); err == nil {
+		t.Error("Set() should reject a name with spaces")
+	}
+	if _, err := Create(filepath.Join(t.TempDir(), "empty.vault"), ""); err == nil {
+		t.Error("Create() should reject an empty passphrase")
+	}
+
+	// Changing the authenticated header is detected
+	var file vaultFile
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Params.N = 1 << 11
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "hunter2"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() of a tampered vault error = %v", err)
+	}
+}
+
+func TestVaultSecretSeesOtherWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	running, err := Create(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Another process, e.g. "jarvis secrets set", adds a secret
+	cli, err := Open(path, "hunter2")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := cli.Set("mqtt_pass", "guest"); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := running.Secret("mqtt_pass"); err != nil || value != "guest" {
+		t.Errorf("Secret() = %q, %v", value, err)
+	}
+}
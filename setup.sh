@@ -31,7 +31,7 @@ go mod tidy
 # Build the project
 echo ""
 echo "Building project..."
-go build -o bin/jarvis main.go
+go build -o bin/jarvis .
 
 if [ $? -eq 0 ]; then
     echo ""
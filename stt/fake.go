@@ -0,0 +1,40 @@
+package stt
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake returns scripted transcripts, for tests and running without an STT engine
+type Fake struct {
+	texts []string
+	calls int
+	mu    sync.Mutex
+}
+
+// NewFake creates a fake transcriber that returns the given texts in order,
+// then empty transcripts
+func NewFake(texts ...string) *Fake {
+	return &Fake{texts: texts}
+}
+
+// Transcribe returns the next scripted text
+func (f *Fake) Transcribe(ctx context.Context, pcm []byte, format Format) (*Transcript, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	text := ""
+	if f.calls < len(f.texts) {
+		text = f.texts[f.calls]
+	}
+	f.calls++
+
+	return &Transcript{Text: text, Language: "vi"}, nil
+}
+
+// Calls returns how many utterances were transcribed
+func (f *Fake) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
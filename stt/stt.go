@@ -0,0 +1,223 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+// Format describes PCM16 audio fed to a transcriber
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// Transcript is the final text of one utterance
+type Transcript struct {
+	Text     string
+	Language string
+	Audio    []byte
+	Duration time.Duration
+}
+
+// Transcriber converts a complete PCM16 utterance to text
+type Transcriber interface {
+	Transcribe(ctx context.Context, pcm []byte, format Format) (*Transcript, error)
+}
+
+// Config holds speech-to-text configuration
+type Config struct {
+	Engine   string // whisper, vosk or fake
+	Binary   string
+	Model    string
+	Language string
+	Threads  int
+}
+
+// New creates a transcriber for the configured engine
+func New(config Config) (Transcriber, error) {
+	if config.Language == "" {
+		config.Language = "vi"
+	}
+
+	switch config.Engine {
+	case "whisper":
+		return NewWhisper(config), nil
+	case "vosk":
+		return NewVosk(config), nil
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown STT engine: %s", config.Engine)
+	}
+}
+
+// Duration returns the length of PCM16 audio
+func (f Format) Duration(pcm []byte) time.Duration {
+	if f.SampleRate == 0 || f.Channels == 0 {
+		return 0
+	}
+	samples := len(pcm) / 2 / f.Channels
+	return time.Duration(samples) * time.Second / time.Duration(f.SampleRate)
+}
+
+// Bytes returns the number of PCM16 bytes in a duration
+func (f Format) Bytes(d time.Duration) int {
+	return int(d.Seconds()*float64(f.SampleRate)) * f.Channels * 2
+}
+
+// nonSpeechMarkers are emitted by engines for silence or noise
+var nonSpeechMarkers = []string{
+	"[BLANK_AUDIO]", "[ Silence ]", "[SILENCE]", "(silence)", "[MUSIC]", "[Music]", "(music)", "[NOISE]", "[noise]",
+}
+
+// cleanTranscript removes non-speech markers and collapses whitespace
+func cleanTranscript(text string) string {
+	for _, marker := range nonSpeechMarkers {
+		text = strings.ReplaceAll(text, marker, " ")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// runCLI writes the utterance to a temporary WAV file and runs an engine CLI on it.
+// The file path replaces the "{input}" argument.
+func runCLI(ctx context.Context, binary string, args []string, pcm []byte, format Format) (string, error) {
+	f, err := os.CreateTemp("", "jarvis-stt-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = audio.WriteWAV(f, pcm, audio.WAVInfo{
+		SampleRate:    format.SampleRate,
+		Channels:      format.Channels,
+		BitsPerSample: 16,
+	})
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	cmdArgs := make([]string, len(args))
+	for i, arg := range args {
+		cmdArgs[i] = strings.ReplaceAll(arg, "{input}", f.Name())
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, cmdArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// Pipeline turns a stream of audio frames into transcripts
+type Pipeline struct {
+	transcriber Transcriber
+	format      Format
+	segment     time.Duration
+	transcripts chan *Transcript
+}
+
+// NewPipeline creates a pipeline. Run transcribes fixed-length segments of
+// audio; RunUtterances transcribes pre-segmented utterances.
+func NewPipeline(transcriber Transcriber, format Format, segment time.Duration) *Pipeline {
+	if segment <= 0 {
+		segment = 5 * time.Second
+	}
+
+	return &Pipeline{
+		transcriber: transcriber,
+		format:      format,
+		segment:     segment,
+		transcripts: make(chan *Transcript, 10),
+	}
+}
+
+// Transcripts returns the channel of final transcripts
+func (p *Pipeline) Transcripts() <-chan *Transcript {
+	return p.transcripts
+}
+
+// Run reads frames until the channel closes or the context is cancelled,
+// transcribing each full segment. The transcript channel is closed on return.
+func (p *Pipeline) Run(ctx context.Context, frames <-chan []byte) {
+	defer close(p.transcripts)
+
+	segmentBytes := p.format.Bytes(p.segment)
+	buffer := make([]byte, 0, segmentBytes)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				if len(buffer) > 0 {
+					p.transcribe(ctx, buffer)
+				}
+				return
+			}
+
+			buffer = append(buffer, frame...)
+			if len(buffer) >= segmentBytes {
+				p.transcribe(ctx, buffer)
+				buffer = make([]byte, 0, segmentBytes)
+			}
+		}
+	}
+}
+
+// RunUtterances transcribes complete utterances, e.g. from a VAD segmenter,
+// until the channel closes or the context is cancelled. The transcript
+// channel is closed on return.
+func (p *Pipeline) RunUtterances(ctx context.Context, utterances <-chan []byte) {
+	defer close(p.transcripts)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case utterance, ok := <-utterances:
+			if !ok {
+				return
+			}
+			p.transcribe(ctx, utterance)
+		}
+	}
+}
+
+// transcribe transcribes one segment and emits non-empty transcripts
+func (p *Pipeline) transcribe(ctx context.Context, pcm []byte) {
+	transcript, err := p.transcriber.Transcribe(ctx, pcm, p.format)
+	if err != nil {
+		log.Printf("Error transcribing audio: %v", err)
+		return
+	}
+
+	transcript.Text = cleanTranscript(transcript.Text)
+	if transcript.Text == "" {
+		return
+	}
+	transcript.Audio = pcm
+	transcript.Duration = p.format.Duration(pcm)
+
+	log.Printf("Heard: %s", transcript.Text)
+
+	// A spoken command is never dropped; a slow consumer holds up transcription
+	select {
+	case p.transcripts <- transcript:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,142 @@
+package stt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineSegments(t *testing.T) {
+	format := Format{SampleRate: 16000, Channels: 1}
+	fake := NewFake("bật đèn phòng khách", "[BLANK_AUDIO]", "tắt quạt")
+	pipeline := NewPipeline(fake, format, 100*time.Millisecond)
+
+	frames := make(chan []byte, 20)
+	frame := make([]byte, format.Bytes(20*time.Millisecond))
+	for i := 0; i < 13; i++ {
+		frames <- frame
+	}
+	close(frames)
+
+	pipeline.Run(context.Background(), frames)
+
+	var texts []string
+	var durations []time.Duration
+	for transcript := range pipeline.Transcripts() {
+		texts = append(texts, transcript.Text)
+		durations = append(durations, transcript.Duration)
+	}
+
+	// 13 frames of 20ms make two full segments and a 60ms remainder;
+	// the second segment is silence and produces no transcript
+	if fake.Calls() != 3 {
+		t.Errorf("Expected 3 transcriptions, got %d", fake.Calls())
+	}
+	if strings.Join(texts, "|") != "bật đèn phòng khách|tắt quạt" {
+		t.Errorf("Transcripts = %v", texts)
+	}
+	if len(durations) != 2 || durations[0] != 100*time.Millisecond || durations[1] != 60*time.Millisecond {
+		t.Errorf("Durations = %v, want [100ms 60ms]", durations)
+	}
+}
+
+func TestWhisperCLI(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "whisper-cli")
+	argsFile := filepath.Join(dir, "args")
+
+	// Fake whisper.cpp: record arguments, check the input is a WAV file, print a transcript
+	content := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+while [ $# -gt 0 ]; do
+  if [ "$1" = "-f" ]; then head -c 4 "$2" | grep -q RIFF || exit 1; fi
+  shift
+done
+echo " Bật điều hòa 26 độ"
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	transcriber, err := New(Config{Engine: "whisper", Binary: script, Model: "ggml-base.bin", Threads: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transcript, err := transcriber.Transcribe(context.Background(), make([]byte, 3200), Format{SampleRate: 16000, Channels: 1})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+
+	if cleanTranscript(transcript.Text) != "Bật điều hòa 26 độ" {
+		t.Errorf("Transcript = %q", transcript.Text)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	for _, want := range []string{"-m ggml-base.bin", "-l vi", "--no-timestamps", "-t 2"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("Arguments %q missing %q", args, want)
+		}
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := New(Config{Engine: "siri"}); err == nil {
+		t.Error("Expected error for unknown engine")
+	}
+}
+
+func TestPipelineUtterances(t *testing.T) {
+	format := Format{SampleRate: 16000, Channels: 1}
+	fake := NewFake("bật đèn phòng khách", "", "tắt quạt")
+	pipeline := NewPipeline(fake, format, 0)
+
+	utterances := make(chan []byte, 3)
+	utterances <- make([]byte, format.Bytes(1200*time.Millisecond))
+	utterances <- make([]byte, format.Bytes(300*time.Millisecond))
+	utterances <- make([]byte, format.Bytes(900*time.Millisecond))
+	close(utterances)
+
+	pipeline.RunUtterances(context.Background(), utterances)
+
+	var durations []time.Duration
+	for transcript := range pipeline.Transcripts() {
+		durations = append(durations, transcript.Duration)
+	}
+
+	if fake.Calls() != 3 {
+		t.Errorf("Expected 3 transcriptions, got %d", fake.Calls())
+	}
+	if len(durations) != 2 || durations[0] != 1200*time.Millisecond || durations[1] != 900*time.Millisecond {
+		t.Errorf("Durations = %v", durations)
+	}
+}
+
+func TestPipelineDoesNotDrop(t *testing.T) {
+	format := Format{SampleRate: 16000, Channels: 1}
+	texts := make([]string, 30)
+	for i := range texts {
+		texts[i] = "bật đèn"
+	}
+	pipeline := NewPipeline(NewFake(texts...), format, 0)
+
+	// More utterances than the transcript channel holds, read only after they are all sent
+	utterances := make(chan []byte, len(texts))
+	for range texts {
+		utterances <- make([]byte, format.Bytes(100*time.Millisecond))
+	}
+	close(utterances)
+	go pipeline.RunUtterances(context.Background(), utterances)
+
+	time.Sleep(20 * time.Millisecond)
+	received := 0
+	for range pipeline.Transcripts() {
+		received++
+	}
+	if received != len(texts) {
+		t.Errorf("Received %d transcripts, want %d", received, len(texts))
+	}
+}
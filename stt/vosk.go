@@ -0,0 +1,43 @@
+package stt
+
+import (
+	"context"
+)
+
+// Vosk transcribes audio with the vosk-transcriber command line tool
+type Vosk struct {
+	binary   string
+	model    string
+	language string
+}
+
+// NewVosk creates a Vosk transcriber
+func NewVosk(config Config) *Vosk {
+	binary := config.Binary
+	if binary == "" {
+		binary = "vosk-transcriber"
+	}
+
+	return &Vosk{
+		binary:   binary,
+		model:    config.Model,
+		language: config.Language,
+	}
+}
+
+// Transcribe runs vosk-transcriber on the utterance
+func (v *Vosk) Transcribe(ctx context.Context, pcm []byte, format Format) (*Transcript, error) {
+	args := []string{"-i", "{input}", "-t", "txt"}
+	if v.model != "" {
+		args = append(args, "-m", v.model)
+	} else {
+		args = append(args, "-l", v.language)
+	}
+
+	output, err := runCLI(ctx, v.binary, args, pcm, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transcript{Text: output, Language: v.language}, nil
+}
@@ -0,0 +1,54 @@
+package stt
+
+import (
+	"context"
+	"fmt"
+)
+
+// Whisper transcribes audio with the whisper.cpp command line tool
+type Whisper struct {
+	binary   string
+	model    string
+	language string
+	threads  int
+}
+
+// NewWhisper creates a whisper.cpp transcriber
+func NewWhisper(config Config) *Whisper {
+	binary := config.Binary
+	if binary == "" {
+		binary = "whisper-cli"
+	}
+
+	return &Whisper{
+		binary:   binary,
+		model:    config.Model,
+		language: config.Language,
+		threads:  config.Threads,
+	}
+}
+
+// Transcribe runs whisper.cpp on the utterance
+func (w *Whisper) Transcribe(ctx context.Context, pcm []byte, format Format) (*Transcript, error) {
+	if w.model == "" {
+		return nil, fmt.Errorf("whisper model path is not configured")
+	}
+
+	args := []string{
+		"-m", w.model,
+		"-l", w.language,
+		"-f", "{input}",
+		"--no-timestamps",
+		"--no-prints",
+	}
+	if w.threads > 0 {
+		args = append(args, "-t", fmt.Sprint(w.threads))
+	}
+
+	output, err := runCLI(ctx, w.binary, args, pcm, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transcript{Text: output, Language: w.language}, nil
+}
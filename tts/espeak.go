@@ -0,0 +1,67 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+)
+
+// Espeak synthesizes speech with the espeak-ng command line tool
+type Espeak struct {
+	binary string
+	voice  string
+	speed  float64
+}
+
+// NewEspeak creates an espeak-ng synthesizer
+func NewEspeak(config Config) *Espeak {
+	binary := config.Binary
+	if binary == "" {
+		binary = "espeak-ng"
+	}
+
+	voice := config.Voice
+	if voice == "" {
+		voice = "vi"
+	}
+
+	return &Espeak{
+		binary: binary,
+		voice:  voice,
+		speed:  config.Speed,
+	}
+}
+
+// Synthesize runs espeak-ng and decodes the WAV it writes to stdout
+func (e *Espeak) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	args := []string{"-v", e.voice, "--stdout"}
+	if e.speed > 0 {
+		// espeak-ng speaks 175 words per minute by default
+		args = append(args, "-s", fmt.Sprint(int(175*e.speed)))
+	}
+	args = append(args, text)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", e.binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	info, pcm, err := audio.ReadWAV(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid espeak-ng output: %w", err)
+	}
+
+	if info.BitsPerSample != 16 {
+		return nil, fmt.Errorf("unsupported espeak-ng sample size: %d bits", info.BitsPerSample)
+	}
+
+	return &Speech{PCM: pcm, SampleRate: info.SampleRate, Channels: info.Channels}, nil
+}
@@ -0,0 +1,50 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Fake records spoken texts to a file instead of synthesizing audio, for
+// tests and headless machines. Each text is returned as 100ms of silence.
+type Fake struct {
+	outputFile string
+	texts      []string
+	mu         sync.Mutex
+}
+
+// NewFake creates a fake synthesizer. If outputFile is set, each text is appended to it as a line.
+func NewFake(outputFile string) *Fake {
+	return &Fake{outputFile: outputFile}
+}
+
+// Synthesize records the text and returns silence
+func (f *Fake) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.texts = append(f.texts, text)
+
+	if f.outputFile != "" {
+		file, err := os.OpenFile(f.outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = fmt.Fprintln(file, text)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Speech{PCM: make([]byte, 3200), SampleRate: 16000, Channels: 1}, nil
+}
+
+// Texts returns everything that was spoken
+func (f *Fake) Texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
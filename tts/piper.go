@@ -0,0 +1,75 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Piper synthesizes speech with the Piper command line tool
+type Piper struct {
+	binary     string
+	model      string
+	sampleRate int
+	speed      float64
+}
+
+// NewPiper creates a Piper synthesizer. The sample rate is read from the
+// model's JSON config (<model>.json) unless configured.
+func NewPiper(config Config) (*Piper, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("piper model path is not configured")
+	}
+
+	binary := config.Binary
+	if binary == "" {
+		binary = "piper"
+	}
+
+	sampleRate := config.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 22050
+		if data, err := os.ReadFile(config.Model + ".json"); err == nil {
+			var modelConfig struct {
+				Audio struct {
+					SampleRate int `json:"sample_rate"`
+				} `json:"audio"`
+			}
+			if json.Unmarshal(data, &modelConfig) == nil && modelConfig.Audio.SampleRate > 0 {
+				sampleRate = modelConfig.Audio.SampleRate
+			}
+		}
+	}
+
+	return &Piper{
+		binary:     binary,
+		model:      config.Model,
+		sampleRate: sampleRate,
+		speed:      config.Speed,
+	}, nil
+}
+
+// Synthesize runs Piper and returns raw mono PCM16
+func (p *Piper) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	args := []string{"--model", p.model, "--output_raw"}
+	if p.speed > 0 {
+		// Piper's length scale is the inverse of speed
+		args = append(args, "--length_scale", fmt.Sprintf("%.2f", 1/p.speed))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.binary, args...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", p.binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return &Speech{PCM: stdout.Bytes(), SampleRate: p.sampleRate, Channels: 1}, nil
+}
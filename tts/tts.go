@@ -0,0 +1,80 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Speech is synthesized PCM16 audio
+type Speech struct {
+	PCM        []byte
+	SampleRate int
+	Channels   int
+}
+
+// Synthesizer converts text to speech
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (*Speech, error)
+}
+
+// Output plays synthesized speech
+type Output interface {
+	Play(ctx context.Context, pcm []byte, sampleRate, channels int) error
+}
+
+// Config holds text-to-speech configuration
+type Config struct {
+	Engine     string // piper, espeak or fake
+	Binary     string
+	Model      string // Piper model (.onnx)
+	Voice      string // espeak-ng voice
+	SampleRate int    // Piper model sample rate, read from <model>.json when 0
+	Speed      float64
+	OutputFile string // fake engine: file that receives spoken texts
+}
+
+// New creates a synthesizer for the configured engine
+func New(config Config) (Synthesizer, error) {
+	switch config.Engine {
+	case "piper":
+		return NewPiper(config)
+	case "espeak":
+		return NewEspeak(config), nil
+	case "fake":
+		return NewFake(config.OutputFile), nil
+	default:
+		return nil, fmt.Errorf("unknown TTS engine: %s", config.Engine)
+	}
+}
+
+// Speaker synthesizes text and plays it
+type Speaker struct {
+	synth Synthesizer
+	out   Output
+}
+
+// NewSpeaker creates a new speaker
+func NewSpeaker(synth Synthesizer, out Output) *Speaker {
+	return &Speaker{
+		synth: synth,
+		out:   out,
+	}
+}
+
+// Say speaks the text aloud and blocks until playback finishes
+func (s *Speaker) Say(ctx context.Context, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	speech, err := s.synth.Synthesize(ctx, text)
+	if err != nil {
+		return fmt.Errorf("failed to synthesize speech: %w", err)
+	}
+
+	log.Printf("Speaking: %s", text)
+	return s.out.Play(ctx, speech.PCM, speech.SampleRate, speech.Channels)
+}
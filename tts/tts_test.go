@@ -0,0 +1,107 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordingOutput struct {
+	played     int
+	sampleRate int
+}
+
+func (o *recordingOutput) Play(ctx context.Context, pcm []byte, sampleRate, channels int) error {
+	o.played += len(pcm)
+	o.sampleRate = sampleRate
+	return nil
+}
+
+func TestSpeakerWithFake(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "spoken.txt")
+	fake := NewFake(outputFile)
+	out := &recordingOutput{}
+	speaker := NewSpeaker(fake, out)
+
+	for _, text := range []string{"Đã bật đèn phòng khách", "   ", "Điều hòa đang ở 26 độ"} {
+		if err := speaker.Say(context.Background(), text); err != nil {
+			t.Fatalf("Say(%q) error = %v", text, err)
+		}
+	}
+
+	// Blank text is not synthesized
+	if len(fake.Texts()) != 2 {
+		t.Errorf("Expected 2 synthesized texts, got %v", fake.Texts())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Đã bật đèn phòng khách\nĐiều hòa đang ở 26 độ\n" {
+		t.Errorf("Output file = %q", data)
+	}
+
+	if out.played != 6400 || out.sampleRate != 16000 {
+		t.Errorf("Played %d bytes at %d Hz", out.played, out.sampleRate)
+	}
+}
+
+func TestPiperCLI(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "piper")
+	model := filepath.Join(dir, "voice.onnx")
+	argsFile := filepath.Join(dir, "args")
+	stdinFile := filepath.Join(dir, "stdin")
+
+	// Fake Piper: record arguments and input text, write 4 bytes of raw audio
+	content := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+cat > ` + stdinFile + `
+printf 'abcd'
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(model+".json", []byte(`{"audio": {"sample_rate": 16000}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	synth, err := New(Config{Engine: "piper", Binary: script, Model: model})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	speech, err := synth.Synthesize(context.Background(), "Xin chào")
+	if err != nil {
+		t.Fatalf("Synthesize() error = %v", err)
+	}
+
+	if string(speech.PCM) != "abcd" || speech.SampleRate != 16000 || speech.Channels != 1 {
+		t.Errorf("Speech = %+v", speech)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "--model "+model+" --output_raw") {
+		t.Errorf("Arguments = %q", args)
+	}
+
+	stdin, _ := os.ReadFile(stdinFile)
+	if string(stdin) != "Xin chào" {
+		t.Errorf("Input text = %q", stdin)
+	}
+}
+
+func TestPiperRequiresModel(t *testing.T) {
+	if _, err := New(Config{Engine: "piper"}); err == nil {
+		t.Error("Expected error without a model")
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := New(Config{Engine: "sapi"}); err == nil {
+		t.Error("Expected error for unknown engine")
+	}
+}
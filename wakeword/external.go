@@ -0,0 +1,127 @@
+package wakeword
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+// External runs a wake word model (openWakeWord, Porcupine, ...) in a local
+// runtime process. Raw PCM16 audio is written to its stdin and it prints a
+// line starting with the keyword name to stdout for every detection.
+type External struct {
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	detections chan *Detection
+	done       chan struct{}
+	err        error
+	mu         sync.Mutex
+}
+
+// NewExternal starts the wake word runtime. "{sample_rate}" and "{channels}"
+// in the command line are replaced with the audio format.
+func NewExternal(command []string, format stt.Format) (*External, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("wake word command is not configured")
+	}
+
+	args := make([]string, len(command))
+	for i, arg := range command {
+		arg = strings.ReplaceAll(arg, "{sample_rate}", strconv.Itoa(format.SampleRate))
+		args[i] = strings.ReplaceAll(arg, "{channels}", strconv.Itoa(format.Channels))
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start wake word runtime: %w", err)
+	}
+
+	e := &External{
+		cmd:        cmd,
+		stdin:      stdin,
+		detections: make(chan *Detection, 10),
+		done:       make(chan struct{}),
+	}
+	go e.readDetections(stdout)
+
+	log.Printf("Wake word runtime started: %s", strings.Join(args, " "))
+	return e, nil
+}
+
+// readDetections parses detection lines until the runtime exits
+func (e *External) readDetections(stdout io.Reader) {
+	defer close(e.done)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		select {
+		case e.detections <- &Detection{Keyword: fields[0]}:
+		default:
+			log.Println("Warning: Wake word detection channel is full, dropping detection")
+		}
+	}
+
+	err := e.cmd.Wait()
+	e.mu.Lock()
+	if err == nil {
+		err = fmt.Errorf("wake word runtime exited")
+	}
+	e.err = err
+	e.mu.Unlock()
+}
+
+// Process writes a frame to the runtime and returns any pending detection
+func (e *External) Process(ctx context.Context, frame []byte) (*Detection, error) {
+	select {
+	case detection := <-e.detections:
+		return detection, nil
+	default:
+	}
+
+	e.mu.Lock()
+	err := e.err
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := e.stdin.Write(frame); err != nil {
+		return nil, fmt.Errorf("failed to write to wake word runtime: %w", err)
+	}
+
+	select {
+	case detection := <-e.detections:
+		return detection, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Close stops the runtime
+func (e *External) Close() error {
+	e.stdin.Close()
+	<-e.done
+	return nil
+}
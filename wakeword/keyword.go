@@ -0,0 +1,109 @@
+package wakeword
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/truong-nautilus/smart-home-ai/audio"
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+// Keyword is a fallback detector that transcribes short bursts of loud audio
+// and looks for a keyword in the text
+type Keyword struct {
+	transcriber stt.Transcriber
+	format      stt.Format
+	keywords    [][]string
+	threshold   float64
+	silence     time.Duration
+	minBurst    time.Duration
+	maxBurst    time.Duration
+	burst       []byte
+	quiet       time.Duration
+}
+
+// NewKeyword creates an energy+keyword detector. Keywords default to "jarvis"
+// and the RMS threshold to 500.
+func NewKeyword(transcriber stt.Transcriber, format stt.Format, keywords []string, threshold float64) *Keyword {
+	if len(keywords) == 0 {
+		keywords = []string{"jarvis"}
+	}
+	if threshold <= 0 {
+		threshold = 500
+	}
+
+	k := &Keyword{
+		transcriber: transcriber,
+		format:      format,
+		threshold:   threshold,
+		silence:     400 * time.Millisecond,
+		minBurst:    200 * time.Millisecond,
+		maxBurst:    4 * time.Second,
+	}
+	for _, keyword := range keywords {
+		if fields := words(keyword); len(fields) > 0 {
+			k.keywords = append(k.keywords, fields)
+		}
+	}
+	return k
+}
+
+// Process collects a burst of speech and transcribes it once followed by silence
+func (k *Keyword) Process(ctx context.Context, frame []byte) (*Detection, error) {
+	if audio.RMS(frame) >= k.threshold {
+		k.burst = append(k.burst, frame...)
+		k.quiet = 0
+	} else if len(k.burst) > 0 {
+		k.burst = append(k.burst, frame...)
+		k.quiet += k.format.Duration(frame)
+	}
+
+	if len(k.burst) == 0 || (k.quiet < k.silence && k.format.Duration(k.burst) < k.maxBurst) {
+		return nil, nil
+	}
+
+	burst, quiet := k.burst, k.quiet
+	k.burst, k.quiet = nil, 0
+
+	if k.format.Duration(burst)-quiet < k.minBurst {
+		return nil, nil
+	}
+
+	transcript, err := k.transcriber.Transcribe(ctx, burst, k.format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transcribe wake word: %w", err)
+	}
+
+	return k.match(transcript.Text), nil
+}
+
+// match returns a detection if the text contains a keyword
+func (k *Keyword) match(text string) *Detection {
+	heard := words(text)
+	for _, keyword := range k.keywords {
+		for i := 0; i+len(keyword) <= len(heard); i++ {
+			if strings.Join(heard[i:i+len(keyword)], " ") == strings.Join(keyword, " ") {
+				return &Detection{
+					Keyword: strings.Join(keyword, " "),
+					Text:    strings.Join(heard[i+len(keyword):], " "),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close releases resources
+func (k *Keyword) Close() error {
+	return nil
+}
+
+// words splits text into lower case words without punctuation
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,183 @@
+package wakeword
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+// Detection is a detected wake word
+type Detection struct {
+	Keyword string
+
+	// Text is speech that followed the wake word in the same utterance,
+	// set by detectors that transcribe audio
+	Text string
+}
+
+// Detector finds the wake word in a stream of PCM16 frames
+type Detector interface {
+	// Process consumes one frame and returns a detection when the wake word was heard
+	Process(ctx context.Context, frame []byte) (*Detection, error)
+	Close() error
+}
+
+// Config holds wake word configuration
+type Config struct {
+	Engine          string   // external or keyword
+	Command         []string // external runtime command line
+	Keywords        []string
+	EnergyThreshold float64
+}
+
+// New creates a detector for the configured engine. The keyword engine
+// transcribes short bursts of speech with the given transcriber.
+func New(config Config, format stt.Format, transcriber stt.Transcriber) (Detector, error) {
+	switch config.Engine {
+	case "external":
+		return NewExternal(config.Command, format)
+	case "keyword":
+		if transcriber == nil {
+			return nil, fmt.Errorf("keyword wake word detection requires a speech-to-text engine")
+		}
+		return NewKeyword(transcriber, format, config.Keywords, config.EnergyThreshold), nil
+	default:
+		return nil, fmt.Errorf("unknown wake word engine: %s", config.Engine)
+	}
+}
+
+// Gate only passes audio through for a listening window after the wake word is heard
+type Gate struct {
+	detector  Detector
+	format    stt.Format
+	window    time.Duration
+	frames    chan []byte
+	onWake    func(*Detection)
+	listening bool
+	remaining time.Duration
+	mu        sync.Mutex
+}
+
+// NewGate creates a wake word gate with the given listening window
+func NewGate(detector Detector, format stt.Format, window time.Duration) *Gate {
+	if window <= 0 {
+		window = 8 * time.Second
+	}
+
+	return &Gate{
+		detector: detector,
+		format:   format,
+		window:   window,
+		frames:   make(chan []byte, 100),
+	}
+}
+
+// SetWakeHandler sets the callback run when the wake word is heard, e.g. to
+// play an acknowledgement beep or light an LED
+func (g *Gate) SetWakeHandler(handler func(*Detection)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onWake = handler
+}
+
+// Frames returns the channel of audio frames captured while listening
+func (g *Gate) Frames() <-chan []byte {
+	return g.frames
+}
+
+// IsListening returns whether the listening window is open
+func (g *Gate) IsListening() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.listening
+}
+
+// Listen opens the listening window without a wake word, e.g. for a follow-up question
+func (g *Gate) Listen() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.open()
+}
+
+// Run reads frames until the channel closes or the context is cancelled.
+// A slow consumer blocks Run instead of losing frames, and a frame the
+// detector fails on is skipped. The output channel is closed on return.
+func (g *Gate) Run(ctx context.Context, frames <-chan []byte) {
+	defer close(g.frames)
+
+	// Only the first of consecutive failures is logged, so a broken
+	// detector doesn't log every frame
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			err := g.process(ctx, frame)
+			switch {
+			case err != nil && failures == 0:
+				log.Printf("Warning: Wake word detector failed, skipping audio until it recovers: %v", err)
+				failures++
+			case err != nil:
+				failures++
+			case failures > 0:
+				log.Printf("Wake word detector recovered after %d failed frames", failures)
+				failures = 0
+			}
+		}
+	}
+}
+
+// process forwards a frame while listening, or runs it through the detector
+func (g *Gate) process(ctx context.Context, frame []byte) error {
+	g.mu.Lock()
+	if g.listening {
+		g.remaining -= g.format.Duration(frame)
+		if g.remaining <= 0 {
+			g.listening = false
+			log.Println("Listening window closed")
+		}
+		g.mu.Unlock()
+
+		select {
+		case g.frames <- frame:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	g.mu.Unlock()
+
+	detection, err := g.detector.Process(ctx, frame)
+	if err != nil || detection == nil {
+		return err
+	}
+
+	log.Printf("Wake word detected: %s", detection.Keyword)
+
+	g.mu.Lock()
+	// A command spoken together with the wake word is handled by the wake
+	// handler, so only open the window when the user has yet to speak
+	if detection.Text == "" {
+		g.open()
+	}
+	onWake := g.onWake
+	g.mu.Unlock()
+
+	if onWake != nil {
+		onWake(detection)
+	}
+	return nil
+}
+
+// open starts a new listening window. Caller holds g.mu.
+func (g *Gate) open() {
+	g.listening = true
+	g.remaining = g.window
+}
@@ -0,0 +1,234 @@
+package wakeword
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/truong-nautilus/smart-home-ai/stt"
+)
+
+var format = stt.Format{SampleRate: 16000, Channels: 1}
+
+// frame returns 20ms of PCM16 audio at a constant level
+func frame(level int16) []byte {
+	data := make([]byte, format.Bytes(20*time.Millisecond))
+	for i := 0; i < len(data); i += 2 {
+		binary.LittleEndian.PutUint16(data[i:], uint16(level))
+	}
+	return data
+}
+
+// scriptedDetector detects the wake word or fails on the given frame numbers
+type scriptedDetector struct {
+	frames  int
+	trigger map[int]*Detection
+	fail    map[int]error
+}
+
+func (d *scriptedDetector) Process(ctx context.Context, frame []byte) (*Detection, error) {
+	d.frames++
+	return d.trigger[d.frames], d.fail[d.frames]
+}
+
+func (d *scriptedDetector) Close() error {
+	return nil
+}
+
+func TestGateListeningWindow(t *testing.T) {
+	detector := &scriptedDetector{trigger: map[int]*Detection{3: {Keyword: "jarvis"}}}
+	gate := NewGate(detector, format, 100*time.Millisecond)
+
+	var wakes []*Detection
+	gate.SetWakeHandler(func(detection *Detection) {
+		wakes = append(wakes, detection)
+	})
+
+	frames := make(chan []byte, 20)
+	for i := 0; i < 12; i++ {
+		frames <- frame(0)
+	}
+	close(frames)
+
+	gate.Run(context.Background(), frames)
+
+	forwarded := 0
+	for range gate.Frames() {
+		forwarded++
+	}
+
+	// Frames 1-3 go to the detector, the next 100ms (5 frames) are forwarded,
+	// and the rest go back to the detector
+	if len(wakes) != 1 || wakes[0].Keyword != "jarvis" {
+		t.Errorf("Wakes = %v", wakes)
+	}
+	if forwarded != 5 {
+		t.Errorf("Expected 5 forwarded frames, got %d", forwarded)
+	}
+	if detector.frames != 7 {
+		t.Errorf("Expected detector to see 7 frames, got %d", detector.frames)
+	}
+	if gate.IsListening() {
+		t.Error("Expected listening window to be closed")
+	}
+}
+
+func TestGateDoesNotDrop(t *testing.T) {
+	gate := NewGate(&scriptedDetector{}, format, time.Minute)
+	gate.Listen()
+
+	frames := make(chan []byte, 300)
+	for i := 0; i < 300; i++ {
+		frames <- frame(0)
+	}
+	close(frames)
+	go gate.Run(context.Background(), frames)
+
+	time.Sleep(20 * time.Millisecond)
+	forwarded := 0
+	for range gate.Frames() {
+		forwarded++
+	}
+	if forwarded != 300 {
+		t.Errorf("Forwarded %d frames, want 300", forwarded)
+	}
+}
+
+func TestGateSkipsDetectorErrors(t *testing.T) {
+	failed := errors.New("model crashed")
+	detector := &scriptedDetector{
+		fail:    map[int]error{1: failed, 2: failed},
+		trigger: map[int]*Detection{4: {Keyword: "jarvis"}},
+	}
+	gate := NewGate(detector, format, 40*time.Millisecond)
+
+	frames := make(chan []byte, 10)
+	for i := 0; i < 6; i++ {
+		frames <- frame(0)
+	}
+	close(frames)
+	gate.Run(context.Background(), frames)
+
+	// The gate keeps running after the failed frames and still wakes up
+	forwarded := 0
+	for range gate.Frames() {
+		forwarded++
+	}
+	if detector.frames != 4 || forwarded != 2 {
+		t.Errorf("Detector saw %d frames and %d were forwarded, want 4 and 2", detector.frames, forwarded)
+	}
+}
+
+func TestGateCommandWithWakeWord(t *testing.T) {
+	detector := &scriptedDetector{trigger: map[int]*Detection{1: {Keyword: "jarvis", Text: "bật đèn"}}}
+	gate := NewGate(detector, format, time.Second)
+
+	var text string
+	gate.SetWakeHandler(func(detection *Detection) {
+		text = detection.Text
+	})
+
+	frames := make(chan []byte, 2)
+	frames <- frame(0)
+	frames <- frame(0)
+	close(frames)
+
+	gate.Run(context.Background(), frames)
+
+	if text != "bật đèn" {
+		t.Errorf("Text = %q", text)
+	}
+	if len(gate.Frames()) != 0 {
+		t.Error("Expected no window when the command came with the wake word")
+	}
+}
+
+func TestKeywordDetector(t *testing.T) {
+	fake := stt.NewFake("Hôm nay trời đẹp", "Jarvis, bật đèn phòng khách!")
+	detector := NewKeyword(fake, format, []string{"Jarvis"}, 500)
+
+	var detections []*Detection
+	feed := func(level int16, count int) {
+		for i := 0; i < count; i++ {
+			detection, err := detector.Process(context.Background(), frame(level))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detection != nil {
+				detections = append(detections, detection)
+			}
+		}
+	}
+
+	// A click shorter than the minimum burst is ignored
+	feed(2000, 2)
+	feed(0, 25)
+
+	// Two utterances, each followed by silence
+	feed(2000, 30)
+	feed(0, 25)
+	feed(2000, 30)
+	feed(0, 25)
+
+	if fake.Calls() != 2 {
+		t.Errorf("Expected 2 transcriptions, got %d", fake.Calls())
+	}
+	if len(detections) != 1 {
+		t.Fatalf("Expected 1 detection, got %d", len(detections))
+	}
+	if detections[0].Keyword != "jarvis" || detections[0].Text != "bật đèn phòng khách" {
+		t.Errorf("Detection = %+v", detections[0])
+	}
+}
+
+func TestExternalDetector(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "wakeword")
+	argsFile := filepath.Join(dir, "args")
+
+	// Fake runtime: detect after reading two 20ms frames
+	content := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+head -c 1280 > /dev/null
+echo "hey_jarvis 0.93"
+cat > /dev/null
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	detector, err := New(Config{Engine: "external", Command: []string{script, "--rate", "{sample_rate}"}}, format, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer detector.Close()
+
+	var detection *Detection
+	deadline := time.Now().Add(5 * time.Second)
+	for detection == nil && time.Now().Before(deadline) {
+		detection, err = detector.Process(context.Background(), frame(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if detection == nil || detection.Keyword != "hey_jarvis" {
+		t.Fatalf("Detection = %+v", detection)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if string(args) != "--rate 16000\n" {
+		t.Errorf("Arguments = %q", args)
+	}
+}
+
+func TestKeywordRequiresTranscriber(t *testing.T) {
+	if _, err := New(Config{Engine: "keyword"}, format, nil); err == nil {
+		t.Error("Expected error without a transcriber")
+	}
+}